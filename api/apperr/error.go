@@ -0,0 +1,177 @@
+// Package apperr provides the typed application error which was mapped to
+// the code and meta of the standard response envelope.
+package apperr
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	rsp "github.com/SyntSugar/ss-infra-go/api/response"
+)
+
+// FieldError was the structured error of the request field, it was shared with
+// the validation errors of the response bind helpers.
+type FieldError = rsp.FieldError
+
+// Error was the application error, the Message and Fields would be responded
+// to client, while the Cause was internal and only logged.
+type Error struct {
+	Status  int
+	SubCode int
+	Message string
+	Fields  []FieldError
+	Cause   error
+}
+
+// New creates the application error with http status, sub code and public message.
+func New(status, subCode int, message string) *Error {
+	return &Error{
+		Status:  status,
+		SubCode: subCode,
+		Message: message,
+	}
+}
+
+// Wrap creates the application error with the internal cause.
+func Wrap(cause error, status, subCode int, message string) *Error {
+	return &Error{
+		Status:  status,
+		SubCode: subCode,
+		Message: message,
+		Cause:   cause,
+	}
+}
+
+// Define registers the code description of status and sub code into the response
+// package, and returns the sentinel error which could be matched by errors.Is.
+func Define(status, subCode int, message string) (*Error, error) {
+	code := status*100 + subCode
+	if err := rsp.RegisterCustomCode(code, "", message); err != nil {
+		return nil, fmt.Errorf("register code %d err: %w", code, err)
+	}
+	return New(status, subCode, message), nil
+}
+
+// MustDefine was like Define but panics if failed to register the code.
+func MustDefine(status, subCode int, message string) *Error {
+	err, defineErr := Define(status, subCode, message)
+	if defineErr != nil {
+		panic(defineErr)
+	}
+	return err
+}
+
+// Error implements the error interface, the cause was included for logging.
+func (e *Error) Error() string {
+	msg := e.Message
+	if msg == "" {
+		msg = http.StatusText(e.Status)
+	}
+	if e.Cause != nil {
+		return fmt.Sprintf("%s(code: %d): %s", msg, e.Code(), e.Cause.Error())
+	}
+	return fmt.Sprintf("%s(code: %d)", msg, e.Code())
+}
+
+// Unwrap returns the internal cause.
+func (e *Error) Unwrap() error {
+	return e.Cause
+}
+
+// Is reports whether the target was the application error with the same code.
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	if !ok {
+		return false
+	}
+	return t.Status == e.Status && t.SubCode == e.SubCode
+}
+
+// Code returns the code of response meta.
+func (e *Error) Code() int {
+	return e.Status*100 + e.SubCode
+}
+
+// WithCause returns the copy of error with the internal cause,
+// it's used to attach the cause to the sentinel error.
+func (e *Error) WithCause(cause error) *Error {
+	c := e.clone()
+	c.Cause = cause
+	return c
+}
+
+// WithMessage returns the copy of error with the public message.
+func (e *Error) WithMessage(format string, args ...any) *Error {
+	c := e.clone()
+	c.Message = fmt.Sprintf(format, args...)
+	return c
+}
+
+// WithField returns the copy of error with the appended field error.
+func (e *Error) WithField(field, rule, message string) *Error {
+	c := e.clone()
+	c.Fields = append(c.Fields, FieldError{Field: field, Rule: rule, Message: message})
+	return c
+}
+
+// PublicErrors returns the errors of response meta, the cause would never be included.
+func (e *Error) PublicErrors() []any {
+	if len(e.Fields) > 0 {
+		errs := make([]any, 0, len(e.Fields))
+		for _, field := range e.Fields {
+			errs = append(errs, field)
+		}
+		return errs
+	}
+	if e.Message != "" {
+		return []any{e.Message}
+	}
+	return nil
+}
+
+func (e *Error) clone() *Error {
+	c := *e
+	if e.Fields != nil {
+		c.Fields = append(make([]FieldError, 0, len(e.Fields)+1), e.Fields...)
+	}
+	return &c
+}
+
+// From converts err into the application error, the unknown error would be
+// wrapped as the internal error to avoid leaking the internals.
+func From(err error) *Error {
+	if err == nil {
+		return nil
+	}
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		return appErr
+	}
+	return Wrap(err, http.StatusInternalServerError, 0, "")
+}
+
+// BadRequest creates the 400 application error.
+func BadRequest(message string) *Error {
+	return New(http.StatusBadRequest, 0, message)
+}
+
+// NotFound creates the 404 application error.
+func NotFound(message string) *Error {
+	return New(http.StatusNotFound, 0, message)
+}
+
+// Conflict creates the 409 application error.
+func Conflict(message string) *Error {
+	return New(http.StatusConflict, 0, message)
+}
+
+// Unprocessable creates the 422 application error.
+func Unprocessable(message string) *Error {
+	return New(http.StatusUnprocessableEntity, 0, message)
+}
+
+// Internal wraps the cause as the 500 application error.
+func Internal(cause error) *Error {
+	return Wrap(cause, http.StatusInternalServerError, 0, "")
+}
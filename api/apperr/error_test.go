@@ -0,0 +1,48 @@
+package apperr
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	rsp "github.com/SyntSugar/ss-infra-go/api/response"
+)
+
+func TestError(t *testing.T) {
+	errUserNotFound := MustDefine(http.StatusNotFound, 1, "The user was not found.")
+	assert.Equal(t, "The user was not found.", rsp.HttpCodeDescription(40401).Message)
+	assert.Equal(t, "NotFound", rsp.HttpCodeDescription(40401).Status)
+
+	cause := errors.New("sql: no rows in result set")
+	err := fmt.Errorf("get user: %w", errUserNotFound.WithCause(cause))
+	assert.True(t, errors.Is(err, errUserNotFound))
+	assert.True(t, errors.Is(err, cause))
+	assert.False(t, errors.Is(err, NotFound("")))
+
+	appErr := From(err)
+	require.NotNil(t, appErr)
+	assert.Equal(t, 40401, appErr.Code())
+	assert.Equal(t, []any{"The user was not found."}, appErr.PublicErrors())
+	assert.Contains(t, appErr.Error(), cause.Error())
+	assert.Nil(t, errUserNotFound.Cause, "sentinel error shouldn't be modified")
+}
+
+func TestFrom(t *testing.T) {
+	assert.Nil(t, From(nil))
+
+	appErr := From(errors.New("dial tcp: connection refused"))
+	assert.Equal(t, 50000, appErr.Code())
+	assert.Empty(t, appErr.PublicErrors())
+
+	appErr = Unprocessable("invalid user").
+		WithField("name", "required", "name is required").
+		WithField("age", "gte", "age should be greater than 0")
+	assert.Equal(t, []any{
+		FieldError{Field: "name", Rule: "required", Message: "name is required"},
+		FieldError{Field: "age", Rule: "gte", Message: "age should be greater than 0"},
+	}, appErr.PublicErrors())
+}
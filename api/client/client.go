@@ -0,0 +1,123 @@
+// Package client provides the typed helpers to call the api which responds
+// the standard {meta, data} envelope of the response package.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	rsp "github.com/SyntSugar/ss-infra-go/api/response"
+)
+
+const (
+	contentTypeJSON = "application/json"
+	// maxErrorBodySize limits the body kept in the error message when it's not the envelope.
+	maxErrorBodySize = 512
+)
+
+// Do sends the request and decodes the data of response envelope into T,
+// the *response.APIError would be returned when the meta was error.
+// The http.DefaultClient would be used if client was nil.
+func Do[T any](client *http.Client, req *http.Request) (T, error) {
+	var data T
+	if client == nil {
+		client = http.DefaultClient
+	}
+	if req.Header.Get("Accept") == "" {
+		req.Header.Set("Accept", contentTypeJSON)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return data, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return data, fmt.Errorf("read response body err: %w", err)
+	}
+	response, err := rsp.UnmarshalResponse(body)
+	if err != nil || response.Code() == 0 {
+		// the body was not the envelope, e.g. the error page of proxy
+		if resp.StatusCode >= http.StatusBadRequest {
+			return data, newStatusError(resp.StatusCode, body)
+		}
+		if err != nil {
+			return data, fmt.Errorf("unmarshal response err: %w", err)
+		}
+		return data, fmt.Errorf("unexpected response without meta, status: %d", resp.StatusCode)
+	}
+	if err := response.Err(resp.StatusCode); err != nil {
+		return data, err
+	}
+	if err := response.GetData(&data); err != nil {
+		return data, fmt.Errorf("unmarshal response data err: %w", err)
+	}
+	return data, nil
+}
+
+// Get sends the GET request to url and decodes the data into T.
+func Get[T any](ctx context.Context, client *http.Client, url string) (T, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		var data T
+		return data, err
+	}
+	return Do[T](client, req)
+}
+
+// Post sends the POST request with the JSON body to url and decodes the data into T.
+func Post[T any](ctx context.Context, client *http.Client, url string, body any) (T, error) {
+	return send[T](ctx, client, http.MethodPost, url, body)
+}
+
+// Put sends the PUT request with the JSON body to url and decodes the data into T.
+func Put[T any](ctx context.Context, client *http.Client, url string, body any) (T, error) {
+	return send[T](ctx, client, http.MethodPut, url, body)
+}
+
+// Delete sends the DELETE request to url and decodes the data into T.
+func Delete[T any](ctx context.Context, client *http.Client, url string) (T, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, url, nil)
+	if err != nil {
+		var data T
+		return data, err
+	}
+	return Do[T](client, req)
+}
+
+func send[T any](ctx context.Context, client *http.Client, method, url string, body any) (T, error) {
+	var data T
+	var reader io.Reader
+	if body != nil {
+		bs, err := json.Marshal(body)
+		if err != nil {
+			return data, fmt.Errorf("marshal request body err: %w", err)
+		}
+		reader = bytes.NewReader(bs)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, reader)
+	if err != nil {
+		return data, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", contentTypeJSON)
+	}
+	return Do[T](client, req)
+}
+
+func newStatusError(httpStatus int, body []byte) *rsp.APIError {
+	if len(body) > maxErrorBodySize {
+		body = body[:maxErrorBodySize]
+	}
+	return &rsp.APIError{
+		HTTPStatus: httpStatus,
+		Code:       httpStatus * 100,
+		Type:       http.StatusText(httpStatus),
+		Message:    string(body),
+	}
+}
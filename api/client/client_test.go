@@ -0,0 +1,90 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	rsp "github.com/SyntSugar/ss-infra-go/api/response"
+)
+
+type user struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+func setupServer() *httptest.Server {
+	gin.SetMode(gin.ReleaseMode)
+	_ = rsp.RegisterCustomCode(40401, "", "The user was not found.")
+	router := gin.New()
+	router.GET("/users/1", func(c *gin.Context) {
+		rsp.ResponseWithOK(c, user{ID: 1, Name: "foo"})
+	})
+	router.POST("/users", func(c *gin.Context) {
+		var u user
+		if err := c.ShouldBindJSON(&u); err != nil {
+			rsp.ResponseError(c, http.StatusBadRequest, err)
+			return
+		}
+		rsp.ResponseWithCreated(c, u)
+	})
+	router.GET("/users/2", func(c *gin.Context) {
+		rsp.ResponseWithErrors(c, http.StatusNotFound, 1, []any{"user not found"})
+	})
+	router.GET("/bad_gateway", func(c *gin.Context) {
+		c.String(http.StatusBadGateway, "<html>bad gateway</html>")
+	})
+	return httptest.NewServer(router)
+}
+
+func TestGet(t *testing.T) {
+	server := setupServer()
+	defer server.Close()
+
+	u, err := Get[user](context.Background(), server.Client(), server.URL+"/users/1")
+	require.Nil(t, err)
+	assert.Equal(t, user{ID: 1, Name: "foo"}, u)
+}
+
+func TestPost(t *testing.T) {
+	server := setupServer()
+	defer server.Close()
+
+	u, err := Post[*user](context.Background(), nil, server.URL+"/users", user{ID: 2, Name: "bar"})
+	require.Nil(t, err)
+	assert.Equal(t, &user{ID: 2, Name: "bar"}, u)
+
+	_, err = Post[user](context.Background(), nil, server.URL+"/users", "invalid")
+	var apiErr *rsp.APIError
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, 40000, apiErr.Code)
+	assert.Len(t, apiErr.Errors, 1)
+}
+
+func TestAPIError(t *testing.T) {
+	server := setupServer()
+	defer server.Close()
+
+	_, err := Get[user](context.Background(), nil, server.URL+"/users/2")
+	var apiErr *rsp.APIError
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusNotFound, apiErr.HTTPStatus)
+	assert.Equal(t, 40401, apiErr.Code)
+	assert.Equal(t, 1, apiErr.SubCode)
+	assert.Equal(t, "NotFound", apiErr.Type)
+	assert.Equal(t, []any{"user not found"}, apiErr.Errors)
+	assert.True(t, errors.Is(err, &rsp.APIError{Code: 40401}))
+	assert.True(t, errors.Is(err, &rsp.APIError{HTTPStatus: http.StatusNotFound}))
+	assert.False(t, errors.Is(err, &rsp.APIError{Code: 40400}))
+
+	_, err = Get[user](context.Background(), nil, server.URL+"/bad_gateway")
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, 50200, apiErr.Code)
+	assert.Contains(t, apiErr.Message, "bad gateway")
+}
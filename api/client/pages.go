@@ -0,0 +1,123 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	rsp "github.com/SyntSugar/ss-infra-go/api/response"
+)
+
+// PageIterator walks all pages of the paginated api, it follows the Link header
+// of the next page and falls back to the next cursor in pagination metadata.
+//
+// Usage example
+//
+//	it := client.NewPageIterator[User](httpClient, "http://users/api/v1/users?page_size=50")
+//	for it.Next(ctx) {
+//		for _, user := range it.Page() { ... }
+//	}
+//	if err := it.Err(); err != nil { ... }
+type PageIterator[T any] struct {
+	client     *http.Client
+	next       *url.URL
+	page       []T
+	pagination *rsp.Pagination
+	err        error
+}
+
+// NewPageIterator creates the page iterator from the url of the first page.
+func NewPageIterator[T any](client *http.Client, rawURL string) *PageIterator[T] {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	it := &PageIterator[T]{client: client}
+	it.next, it.err = url.Parse(rawURL)
+	return it
+}
+
+// Next fetches the next page, it returns false when no more pages or error occurred.
+func (it *PageIterator[T]) Next(ctx context.Context) bool {
+	if it.err != nil || it.next == nil {
+		return false
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, it.next.String(), nil)
+	if err != nil {
+		it.err = err
+		return false
+	}
+	req.Header.Set("Accept", contentTypeJSON)
+	resp, err := it.client.Do(req)
+	if err != nil {
+		it.err = err
+		return false
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		it.err = fmt.Errorf("read response body err: %w", err)
+		return false
+	}
+
+	response, err := rsp.UnmarshalResponse(body)
+	if err != nil || response.Code() == 0 {
+		it.err = newStatusError(resp.StatusCode, body)
+		return false
+	}
+	if err := response.Err(resp.StatusCode); err != nil {
+		it.err = err
+		return false
+	}
+	var page []T
+	if err := response.GetData(&page); err != nil {
+		it.err = fmt.Errorf("unmarshal response data err: %w", err)
+		return false
+	}
+	it.page = page
+	it.pagination = response.Pagination
+	it.next = nextPageURL(req.URL, resp.Header, response.Pagination)
+	return true
+}
+
+// Page returns the items of current page.
+func (it *PageIterator[T]) Page() []T {
+	return it.page
+}
+
+// Pagination returns the pagination metadata of current page.
+func (it *PageIterator[T]) Pagination() *rsp.Pagination {
+	return it.pagination
+}
+
+// Err returns the error which stopped the iteration.
+func (it *PageIterator[T]) Err() error {
+	return it.err
+}
+
+func nextPageURL(current *url.URL, header http.Header, pagination *rsp.Pagination) *url.URL {
+	for _, value := range header.Values("Link") {
+		for _, link := range strings.Split(value, ",") {
+			target, params, found := strings.Cut(strings.TrimSpace(link), ";")
+			if !found || !strings.Contains(params, `rel="next"`) {
+				continue
+			}
+			target = strings.Trim(strings.TrimSpace(target), "<>")
+			ref, err := url.Parse(target)
+			if err != nil {
+				continue
+			}
+			return current.ResolveReference(ref)
+		}
+	}
+	if pagination == nil || !pagination.HasMore || pagination.NextCursor == "" {
+		return nil
+	}
+	next := *current
+	query := next.Query()
+	query.Set(rsp.QueryCursor, pagination.NextCursor)
+	next.RawQuery = query.Encode()
+	return &next
+}
@@ -0,0 +1,81 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	rsp "github.com/SyntSugar/ss-infra-go/api/response"
+)
+
+func TestPageIterator(t *testing.T) {
+	items := make([]int, 25)
+	for i := range items {
+		items[i] = i
+	}
+	signer, err := rsp.NewCursorSigner([]byte(strings.Repeat("s", 32)))
+	require.Nil(t, err)
+
+	router := gin.New()
+	router.GET("/offset", func(c *gin.Context) {
+		page, err := rsp.ParseOffsetPage(c, nil)
+		if err != nil {
+			rsp.ResponseError(c, http.StatusBadRequest, err)
+			return
+		}
+		end := page.Offset() + page.PageSize
+		if end > len(items) {
+			end = len(items)
+		}
+		rsp.ResponseWithPage(c, items[page.Offset():end], page.Pagination(int64(len(items))))
+	})
+	router.GET("/cursor", func(c *gin.Context) {
+		var offset int
+		page, err := rsp.ParseCursorPage(c, nil, signer, &offset)
+		if err != nil {
+			rsp.ResponseError(c, http.StatusBadRequest, err)
+			return
+		}
+		end := offset + page.PageSize
+		if end > len(items) {
+			end = len(items)
+		}
+		pagination := rsp.Pagination{PageSize: page.PageSize, HasMore: end < len(items)}
+		pagination.NextCursor, _ = signer.Encode(end)
+		// emulate the api without the Link header
+		c.Header("Link", "")
+		c.JSON(http.StatusOK, &rsp.Response{
+			Meta:       rsp.Meta{Code: 20000},
+			Data:       items[offset:end],
+			Pagination: &pagination,
+		})
+	})
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	for _, path := range []string{"/offset", "/cursor"} {
+		t.Run(path, func(t *testing.T) {
+			it := NewPageIterator[int](nil, server.URL+path+"?page_size="+strconv.Itoa(10))
+			var got []int
+			pages := 0
+			for it.Next(context.Background()) {
+				got = append(got, it.Page()...)
+				pages++
+			}
+			require.Nil(t, it.Err())
+			assert.Equal(t, 3, pages)
+			assert.Equal(t, items, got)
+		})
+	}
+
+	it := NewPageIterator[int](nil, server.URL+"/offset?page=0")
+	assert.False(t, it.Next(context.Background()))
+	assert.NotNil(t, it.Err())
+}
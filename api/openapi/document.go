@@ -0,0 +1,72 @@
+// Package openapi builds the OpenAPI 3.1 document from the gin routes which were
+// registered with the request and response Go types, see Router.
+package openapi
+
+// Version was the OpenAPI specification version of the document.
+const Version = "3.1.0"
+
+// Document was the subset of OpenAPI 3.1 document which could be built from the routes.
+type Document struct {
+	OpenAPI    string               `json:"openapi"`
+	Info       Info                 `json:"info"`
+	Paths      map[string]*PathItem `json:"paths"`
+	Components Components           `json:"components"`
+}
+
+type Info struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+// PathItem maps the lower case http method to the operation.
+type PathItem map[string]*Operation
+
+type Operation struct {
+	OperationID string                        `json:"operationId,omitempty"`
+	Summary     string                        `json:"summary,omitempty"`
+	Description string                        `json:"description,omitempty"`
+	Tags        []string                      `json:"tags,omitempty"`
+	Parameters  []*Parameter                  `json:"parameters,omitempty"`
+	RequestBody *RequestBody                  `json:"requestBody,omitempty"`
+	Responses   map[string]*OperationResponse `json:"responses"`
+	Deprecated  bool                          `json:"deprecated,omitempty"`
+}
+
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                  `json:"required,omitempty"`
+	Content  map[string]*MediaType `json:"content"`
+}
+
+type OperationResponse struct {
+	Description string                `json:"description"`
+	Content     map[string]*MediaType `json:"content,omitempty"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+type Components struct {
+	Schemas map[string]*Schema `json:"schemas,omitempty"`
+}
+
+// Schema was the JSON schema(draft 2020-12) which OpenAPI 3.1 uses.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Enum                 []any              `json:"enum,omitempty"`
+}
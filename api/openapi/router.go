@@ -0,0 +1,440 @@
+package openapi
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	rsp "github.com/SyntSugar/ss-infra-go/api/response"
+	"github.com/SyntSugar/ss-infra-go/whoami"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Route was the registered route with its request and response Go types.
+type Route struct {
+	Method      string
+	Path        string
+	OperationID string
+	Summary     string
+	Description string
+	Tags        []string
+	Deprecated  bool
+	// Status was the http status of the success response, default was 200.
+	Status int
+	// Request, Query and Params were the types of JSON body, query and path parameters.
+	Request reflect.Type
+	Query   reflect.Type
+	Params  reflect.Type
+	// Response was the type of the data in the response envelope.
+	Response reflect.Type
+	// Errors was the codes of error responses, the common codes would be used when it's empty.
+	Errors []int
+}
+
+// Option configures the documentation of route.
+type Option func(route *Route)
+
+func OperationID(id string) Option {
+	return func(route *Route) { route.OperationID = id }
+}
+
+func Summary(summary string) Option {
+	return func(route *Route) { route.Summary = summary }
+}
+
+func Description(description string) Option {
+	return func(route *Route) { route.Description = description }
+}
+
+func Tags(tags ...string) Option {
+	return func(route *Route) { route.Tags = append(route.Tags, tags...) }
+}
+
+func Deprecated() Option {
+	return func(route *Route) { route.Deprecated = true }
+}
+
+// Status sets the http status of the success response.
+func Status(status int) Option {
+	return func(route *Route) { route.Status = status }
+}
+
+// Errors sets the codes(e.g. 40400, 40901) of the error responses.
+func Errors(codes ...int) Option {
+	return func(route *Route) { route.Errors = append(route.Errors, codes...) }
+}
+
+// Request sets the type of JSON request body.
+func Request[T any]() Option {
+	return func(route *Route) { route.Request = reflect.TypeOf((*T)(nil)).Elem() }
+}
+
+// Query sets the type of query parameters, the names were read from the form tags.
+func Query[T any]() Option {
+	return func(route *Route) { route.Query = reflect.TypeOf((*T)(nil)).Elem() }
+}
+
+// Params sets the type of path parameters, the names were read from the uri tags.
+func Params[T any]() Option {
+	return func(route *Route) { route.Params = reflect.TypeOf((*T)(nil)).Elem() }
+}
+
+// Response sets the type of the data in the response envelope.
+func Response[T any]() Option {
+	return func(route *Route) { route.Response = reflect.TypeOf((*T)(nil)).Elem() }
+}
+
+// Registry collects the documented routes to build the document.
+type Registry struct {
+	mu     sync.RWMutex
+	info   Info
+	routes []*Route
+}
+
+// NewRegistry creates the registry, the title and version were from whoami when they're empty.
+func NewRegistry(title, version string) *Registry {
+	if title == "" {
+		title = whoami.Name()
+	}
+	if title == "" {
+		title = "API"
+	}
+	if version == "" {
+		version = whoami.Version()
+	}
+	return &Registry{info: Info{Title: title, Version: version}}
+}
+
+func (reg *Registry) add(route *Route) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	reg.routes = append(reg.routes, route)
+}
+
+// Routes returns the documented routes.
+func (reg *Registry) Routes() []Route {
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+	routes := make([]Route, 0, len(reg.routes))
+	for _, route := range reg.routes {
+		routes = append(routes, *route)
+	}
+	return routes
+}
+
+// Router wraps the gin router group to register the handlers with the documentation.
+type Router struct {
+	group    *gin.RouterGroup
+	registry *Registry
+}
+
+func NewRouter(group *gin.RouterGroup, registry *Registry) *Router {
+	return &Router{group: group, registry: registry}
+}
+
+// Group creates the sub router with the relative path and middlewares.
+func (r *Router) Group(relativePath string, handlers ...gin.HandlerFunc) *Router {
+	return &Router{group: r.group.Group(relativePath, handlers...), registry: r.registry}
+}
+
+// Use adds the middlewares into the router group.
+func (r *Router) Use(middlewares ...gin.HandlerFunc) *Router {
+	r.group.Use(middlewares...)
+	return r
+}
+
+// RouterGroup returns the underlying gin router group.
+func (r *Router) RouterGroup() *gin.RouterGroup {
+	return r.group
+}
+
+// Handle registers the handler and its documentation.
+func (r *Router) Handle(method, relativePath string, handler gin.HandlerFunc, opts ...Option) {
+	route := &Route{
+		Method: method,
+		Path:   joinPath(r.group.BasePath(), relativePath),
+	}
+	for _, opt := range opts {
+		opt(route)
+	}
+	r.group.Handle(method, relativePath, handler)
+	r.registry.add(route)
+}
+
+func (r *Router) GET(relativePath string, handler gin.HandlerFunc, opts ...Option) {
+	r.Handle(http.MethodGet, relativePath, handler, opts...)
+}
+
+func (r *Router) POST(relativePath string, handler gin.HandlerFunc, opts ...Option) {
+	r.Handle(http.MethodPost, relativePath, handler, opts...)
+}
+
+func (r *Router) PUT(relativePath string, handler gin.HandlerFunc, opts ...Option) {
+	r.Handle(http.MethodPut, relativePath, handler, opts...)
+}
+
+func (r *Router) PATCH(relativePath string, handler gin.HandlerFunc, opts ...Option) {
+	r.Handle(http.MethodPatch, relativePath, handler, opts...)
+}
+
+func (r *Router) DELETE(relativePath string, handler gin.HandlerFunc, opts ...Option) {
+	r.Handle(http.MethodDelete, relativePath, handler, opts...)
+}
+
+func joinPath(basePath, relativePath string) string {
+	if relativePath == "" {
+		return basePath
+	}
+	joined := path.Join(basePath, relativePath)
+	if strings.HasSuffix(relativePath, "/") && !strings.HasSuffix(joined, "/") {
+		joined += "/"
+	}
+	return joined
+}
+
+// Document builds the OpenAPI document of the registered routes.
+func (reg *Registry) Document() *Document {
+	generator := newSchemaGenerator()
+	generator.schemas["Meta"] = metaSchema()
+	doc := &Document{
+		OpenAPI: Version,
+		Info:    reg.info,
+		Paths:   make(map[string]*PathItem),
+	}
+	descriptions := rsp.CodeDescriptions()
+	for _, route := range reg.Routes() {
+		openAPIPath, pathParams := convertPath(route.Path)
+		item, ok := doc.Paths[openAPIPath]
+		if !ok {
+			item = &PathItem{}
+			doc.Paths[openAPIPath] = item
+		}
+		(*item)[strings.ToLower(route.Method)] = newOperation(generator, &route, pathParams, descriptions)
+	}
+	doc.Components.Schemas = generator.schemas
+	return doc
+}
+
+func newOperation(g *schemaGenerator, route *Route, pathParams []string, descriptions map[int]rsp.Description) *Operation {
+	op := &Operation{
+		OperationID: route.OperationID,
+		Summary:     route.Summary,
+		Description: route.Description,
+		Tags:        route.Tags,
+		Deprecated:  route.Deprecated,
+		Responses:   make(map[string]*OperationResponse),
+	}
+	op.Parameters = append(op.Parameters, pathParameters(g, pathParams, route.Params)...)
+	if route.Query != nil {
+		op.Parameters = append(op.Parameters, queryParameters(g, route.Query)...)
+	}
+	if route.Request != nil {
+		op.RequestBody = &RequestBody{
+			Required: true,
+			Content:  map[string]*MediaType{"application/json": {Schema: g.schemaOf(route.Request)}},
+		}
+	}
+
+	status := route.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	dataSchema := &Schema{}
+	if route.Response != nil {
+		dataSchema = g.schemaOf(route.Response)
+	}
+	op.Responses[strconv.Itoa(status)] = &OperationResponse{
+		Description: http.StatusText(status),
+		Content:     map[string]*MediaType{"application/json": {Schema: envelopeSchema(dataSchema)}},
+	}
+
+	codes := route.Errors
+	if len(codes) == 0 {
+		codes = defaultErrorCodes(route)
+	}
+	for status, description := range errorDescriptions(codes, descriptions) {
+		op.Responses[strconv.Itoa(status)] = &OperationResponse{
+			Description: description,
+			Content:     map[string]*MediaType{"application/json": {Schema: envelopeSchema(&Schema{Type: "null"})}},
+		}
+	}
+	return op
+}
+
+func defaultErrorCodes(route *Route) []int {
+	var codes []int
+	if route.Request != nil || route.Query != nil || route.Params != nil {
+		codes = append(codes, 40000, 42200)
+	}
+	return append(codes, 50000)
+}
+
+// errorDescriptions groups the codes by http status, the description lists the codes and their messages.
+func errorDescriptions(codes []int, descriptions map[int]rsp.Description) map[int]string {
+	codes = append([]int(nil), codes...)
+	sort.Ints(codes)
+	lines := make(map[int][]string)
+	for _, code := range codes {
+		desc := descriptions[code]
+		lines[code/100] = append(lines[code/100], fmt.Sprintf("%d %s: %s", code, desc.Status, desc.Message))
+	}
+	result := make(map[int]string, len(lines))
+	for status, l := range lines {
+		result[status] = strings.Join(l, "\n")
+	}
+	return result
+}
+
+func envelopeSchema(data *Schema) *Schema {
+	return &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"meta": {Ref: schemaRefPrefix + "Meta"},
+			"data": data,
+		},
+		Required: []string{"meta"},
+	}
+}
+
+// metaSchema returns the schema of response meta, the code was the enum of registered codes.
+func metaSchema() *Schema {
+	descriptions := rsp.CodeDescriptions()
+	codes := make([]int, 0, len(descriptions))
+	for code := range descriptions {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+	enum := make([]any, 0, len(codes))
+	for _, code := range codes {
+		enum = append(enum, code)
+	}
+	return &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"code":    {Type: "integer", Description: "http status * 100 + sub code", Enum: enum},
+			"type":    {Type: "string"},
+			"message": {Type: "string"},
+			"errors":  {Type: "array", Items: &Schema{}},
+		},
+		Required: []string{"code"},
+	}
+}
+
+// convertPath converts the gin path(/users/:id/*path) into the OpenAPI path(/users/{id}/{path}).
+func convertPath(ginPath string) (string, []string) {
+	segments := strings.Split(ginPath, "/")
+	var params []string
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "*") {
+			params = append(params, segment[1:])
+			segments[i] = "{" + segment[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/"), params
+}
+
+func pathParameters(g *schemaGenerator, names []string, paramsType reflect.Type) []*Parameter {
+	fields := tagFields(g, paramsType, "uri")
+	params := make([]*Parameter, 0, len(names))
+	for _, name := range names {
+		schema, ok := fields[name]
+		if !ok {
+			schema = &Schema{Type: "string"}
+		}
+		params = append(params, &Parameter{Name: name, In: "path", Required: true, Schema: schema})
+	}
+	return params
+}
+
+func queryParameters(g *schemaGenerator, t reflect.Type) []*Parameter {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	var params []*Parameter
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("form"), ",")
+		if !field.IsExported() || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		schema := g.schemaOf(field.Type)
+		applyBindingRules(schema, field)
+		params = append(params, &Parameter{Name: name, In: "query", Required: isRequired(field), Schema: schema})
+	}
+	return params
+}
+
+// tagFields returns the schemas of the struct fields by their names in the tag.
+func tagFields(g *schemaGenerator, t reflect.Type, tag string) map[string]*Schema {
+	fields := make(map[string]*Schema)
+	if t == nil {
+		return fields
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get(tag), ",")
+		if field.IsExported() && name != "" && name != "-" {
+			fields[name] = g.schemaOf(field.Type)
+		}
+	}
+	return fields
+}
+
+// Handler serves the OpenAPI document in JSON.
+func (reg *Registry) Handler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, reg.Document())
+	}
+}
+
+// RouteInfo was the entry of the route listing.
+type RouteInfo struct {
+	Method     string `json:"method"`
+	Path       string `json:"path"`
+	Handler    string `json:"handler"`
+	Documented bool   `json:"documented"`
+	Summary    string `json:"summary,omitempty"`
+}
+
+// RoutesHandler lists all routes of the engine, and whether they were documented in the registry.
+func RoutesHandler(engine *gin.Engine, registry *Registry) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		documented := make(map[string]Route)
+		if registry != nil {
+			for _, route := range registry.Routes() {
+				documented[route.Method+" "+route.Path] = route
+			}
+		}
+		routes := engine.Routes()
+		infos := make([]RouteInfo, 0, len(routes))
+		for _, route := range routes {
+			doc, ok := documented[route.Method+" "+route.Path]
+			infos = append(infos, RouteInfo{
+				Method:     route.Method,
+				Path:       route.Path,
+				Handler:    route.Handler,
+				Documented: ok,
+				Summary:    doc.Summary,
+			})
+		}
+		sort.Slice(infos, func(i, j int) bool {
+			if infos[i].Path != infos[j].Path {
+				return infos[i].Path < infos[j].Path
+			}
+			return infos[i].Method < infos[j].Method
+		})
+		rsp.ResponseWithOK(c, infos)
+	}
+}
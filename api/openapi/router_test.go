@@ -0,0 +1,127 @@
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	rsp "github.com/SyntSugar/ss-infra-go/api/response"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testAddress struct {
+	City string `json:"city" binding:"required"`
+}
+
+type testUser struct {
+	ID        int64          `json:"id"`
+	Name      string         `json:"name" binding:"required"`
+	Role      string         `json:"role" binding:"oneof=admin member"`
+	Tags      []string       `json:"tags,omitempty"`
+	Address   *testAddress   `json:"address"`
+	Labels    map[string]int `json:"labels"`
+	Friends   []testUser     `json:"friends"`
+	CreatedAt time.Time      `json:"created_at"`
+	Secret    string         `json:"-"`
+}
+
+type testUserQuery struct {
+	Page int    `form:"page" binding:"required"`
+	Sort string `form:"sort" binding:"omitempty,oneof=asc desc"`
+}
+
+type testUserParams struct {
+	ID int64 `uri:"id"`
+}
+
+func TestDocument(t *testing.T) {
+	require.Nil(t, rsp.RegisterCustomCode(40401, "", "The user was not found."))
+
+	engine := gin.New()
+	registry := NewRegistry("ss-api", "v1.0.0")
+	router := NewRouter(engine.Group("/api"), registry).Group("/users")
+	handler := func(c *gin.Context) {}
+	router.POST("", handler, Summary("create user"), Tags("user"),
+		Request[testUser](), Response[testUser](), Status(http.StatusCreated))
+	router.GET("", handler, Query[testUserQuery](), Response[[]testUser]())
+	router.GET("/:id", handler, Params[testUserParams](), Response[testUser](), Errors(40400, 40401))
+	engine.GET("/undocumented", handler)
+
+	doc := registry.Document()
+	assert.Equal(t, "3.1.0", doc.OpenAPI)
+	assert.Equal(t, Info{Title: "ss-api", Version: "v1.0.0"}, doc.Info)
+	require.Contains(t, doc.Paths, "/api/users")
+	require.Contains(t, doc.Paths, "/api/users/{id}")
+
+	create := (*doc.Paths["/api/users"])["post"]
+	require.NotNil(t, create)
+	assert.Equal(t, "create user", create.Summary)
+	assert.Equal(t, schemaRefPrefix+"testUser", create.RequestBody.Content["application/json"].Schema.Ref)
+	created := create.Responses["201"].Content["application/json"].Schema
+	assert.Equal(t, schemaRefPrefix+"Meta", created.Properties["meta"].Ref)
+	assert.Equal(t, schemaRefPrefix+"testUser", created.Properties["data"].Ref)
+	assert.Contains(t, create.Responses, "400")
+	assert.Contains(t, create.Responses, "422")
+	assert.Contains(t, create.Responses, "500")
+
+	list := (*doc.Paths["/api/users"])["get"]
+	require.Len(t, list.Parameters, 2)
+	assert.Equal(t, &Parameter{Name: "page", In: "query", Required: true,
+		Schema: &Schema{Type: "integer", Format: "int64"}}, list.Parameters[0])
+	assert.Equal(t, []any{"asc", "desc"}, list.Parameters[1].Schema.Enum)
+	assert.Equal(t, "array", list.Responses["200"].Content["application/json"].Schema.Properties["data"].Type)
+
+	get := (*doc.Paths["/api/users/{id}"])["get"]
+	assert.Equal(t, &Parameter{Name: "id", In: "path", Required: true,
+		Schema: &Schema{Type: "integer", Format: "int64"}}, get.Parameters[0])
+	assert.Equal(t, "40400 NotFound: The requested resource was not found but could be available again in the future.\n"+
+		"40401 NotFound: The user was not found.", get.Responses["404"].Description)
+	assert.NotContains(t, get.Responses, "400")
+
+	user := doc.Components.Schemas["testUser"]
+	require.NotNil(t, user)
+	assert.Equal(t, []string{"name"}, user.Required)
+	assert.Equal(t, []any{"admin", "member"}, user.Properties["role"].Enum)
+	assert.Equal(t, schemaRefPrefix+"testAddress", user.Properties["address"].Ref)
+	assert.Equal(t, schemaRefPrefix+"testUser", user.Properties["friends"].Items.Ref)
+	assert.Equal(t, "date-time", user.Properties["created_at"].Format)
+	assert.Equal(t, "integer", user.Properties["labels"].AdditionalProperties.Type)
+	assert.NotContains(t, user.Properties, "Secret")
+	assert.Contains(t, doc.Components.Schemas["Meta"].Properties["code"].Enum, 40401)
+}
+
+func TestHandlers(t *testing.T) {
+	api := gin.New()
+	registry := NewRegistry("ss-api", "v1.0.0")
+	NewRouter(api.Group(""), registry).GET("/users/:id", func(c *gin.Context) {}, Summary("get user"))
+	api.GET("/undocumented", func(c *gin.Context) {})
+
+	admin := gin.New()
+	admin.GET("/openapi.json", registry.Handler())
+	admin.GET("/routes", RoutesHandler(api, registry))
+
+	w := httptest.NewRecorder()
+	admin.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	var doc Document
+	require.Nil(t, json.Unmarshal(w.Body.Bytes(), &doc))
+	assert.Contains(t, doc.Paths, "/users/{id}")
+
+	w = httptest.NewRecorder()
+	admin.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/routes", nil))
+	resp, err := rsp.UnmarshalResponse(w.Body.Bytes())
+	require.Nil(t, err)
+	var routes []RouteInfo
+	require.Nil(t, resp.GetData(&routes))
+	require.Len(t, routes, 2)
+	assert.Equal(t, "/undocumented", routes[0].Path)
+	assert.False(t, routes[0].Documented)
+	assert.Equal(t, "/users/:id", routes[1].Path)
+	assert.True(t, routes[1].Documented)
+	assert.Equal(t, "get user", routes[1].Summary)
+}
@@ -0,0 +1,190 @@
+package realtime
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	rsp "github.com/SyntSugar/ss-infra-go/api/response"
+	"github.com/SyntSugar/ss-infra-go/consts"
+	prome "github.com/SyntSugar/ss-infra-go/prometheus"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// SessionKind was the kind of the long-lived connections.
+type SessionKind string
+
+const (
+	KindSSE       SessionKind = "sse"
+	KindWebSocket SessionKind = "websocket"
+)
+
+// ErrShuttingDown was returned when the session was started after CloseSessions.
+var ErrShuttingDown = errors.New("the server was shutting down")
+
+var (
+	activeConnections = prome.NewGaugeHelper("infra", "http_api", "active_connections", "kind")
+	sessionDurations  = prome.NewHistogramHelper("infra", "http_api", "session_duration_seconds",
+		prometheus.ExponentialBuckets(1, 4, 10), "kind")
+)
+
+// session was the long-lived connection, it's notified by closing to close gracefully
+// and closed forcibly by forceClose when the shutdown timed out.
+type session struct {
+	registry   *Registry
+	kind       SessionKind
+	startTime  time.Time
+	closing    chan struct{}
+	closeOnce  sync.Once
+	forceClose func()
+	ended      chan struct{}
+	endOnce    sync.Once
+}
+
+// Registry tracks the active sessions to close them at shutdown, the server has its own registry
+// which was attached to the requests by Track, and the package functions use the default one.
+type Registry struct {
+	mu       sync.Mutex
+	sessions map[*session]struct{}
+	closing  bool
+}
+
+// NewRegistry creates the session registry.
+func NewRegistry() *Registry {
+	return &Registry{sessions: make(map[*session]struct{})}
+}
+
+var sessions = NewRegistry()
+
+// Track attaches the registry to the requests, the sessions of the requests were tracked by it
+// instead of the default registry.
+func (r *Registry) Track() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(string(consts.ContextKeySessionRegistry), r)
+		c.Next()
+	}
+}
+
+// registryOf returns the registry which was attached to the request, or the default one.
+func registryOf(c *gin.Context) *Registry {
+	if v, ok := c.Get(string(consts.ContextKeySessionRegistry)); ok {
+		if r, ok := v.(*Registry); ok {
+			return r
+		}
+	}
+	return sessions
+}
+
+// startSession registers the session, it responds 50300 and returns ErrShuttingDown if the server was shutting down.
+// The kind was set in the context to let the access log and metrics treat the request as a session.
+func startSession(c *gin.Context, kind SessionKind, forceClose func()) (*session, error) {
+	r := registryOf(c)
+	r.mu.Lock()
+	if r.closing {
+		r.mu.Unlock()
+		rsp.ResponseWithErrors(c, http.StatusServiceUnavailable, 0, []any{ErrShuttingDown.Error()})
+		return nil, ErrShuttingDown
+	}
+	s := &session{
+		registry:   r,
+		kind:       kind,
+		startTime:  time.Now(),
+		closing:    make(chan struct{}),
+		forceClose: forceClose,
+		ended:      make(chan struct{}),
+	}
+	r.sessions[s] = struct{}{}
+	r.mu.Unlock()
+
+	c.Set(string(consts.ContextKeySession), string(kind))
+	activeConnections.WithLabelValues(string(kind)).Inc()
+	return s, nil
+}
+
+// notifyClose notifies the session to close gracefully, it's safe to call multiple times.
+func (s *session) notifyClose() {
+	s.closeOnce.Do(func() {
+		close(s.closing)
+	})
+}
+
+// end unregisters the session and records its duration, it's safe to call multiple times.
+func (s *session) end() {
+	s.endOnce.Do(func() {
+		s.registry.mu.Lock()
+		delete(s.registry.sessions, s)
+		s.registry.mu.Unlock()
+		activeConnections.WithLabelValues(string(s.kind)).Dec()
+		sessionDurations.WithLabelValues(string(s.kind)).Observe(time.Since(s.startTime).Seconds())
+		close(s.ended)
+	})
+}
+
+// Active returns the number of active sessions in the registry.
+func (r *Registry) Active() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.sessions)
+}
+
+// Close rejects the new sessions and notifies the active ones to close gracefully, the SSE streams
+// would be done and the WebSocket connections would receive the going away close frame. The sessions
+// which weren't ended before ctx was done would be closed forcibly. It's safe to call multiple times,
+// and the registry accepts the new sessions again after Open.
+func (r *Registry) Close(ctx context.Context) error {
+	r.mu.Lock()
+	r.closing = true
+	active := make([]*session, 0, len(r.sessions))
+	for s := range r.sessions {
+		active = append(active, s)
+	}
+	r.mu.Unlock()
+	if len(active) == 0 {
+		return nil
+	}
+	for _, s := range active {
+		s.notifyClose()
+	}
+
+	for i, s := range active {
+		select {
+		case <-s.ended:
+		case <-ctx.Done():
+			for _, s := range active[i:] {
+				if s.forceClose != nil {
+					s.forceClose()
+				}
+			}
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// Open accepts the new sessions after Close, e.g. the server was restarted.
+func (r *Registry) Open() {
+	r.mu.Lock()
+	r.closing = false
+	r.mu.Unlock()
+}
+
+// ActiveSessions returns the number of active SSE and WebSocket sessions in the default registry.
+func ActiveSessions() int {
+	return sessions.Active()
+}
+
+// CloseSessions closes the sessions of the default registry like Registry.Close, the sessions of the
+// server were closed by its Shutdown. It should be called before http.Server.Shutdown, since the server
+// doesn't track the hijacked connections and would wait for the SSE handlers until the timeout.
+func CloseSessions(ctx context.Context) error {
+	return sessions.Close(ctx)
+}
+
+// OpenSessions accepts the new sessions of the default registry after CloseSessions.
+func OpenSessions() {
+	sessions.Open()
+}
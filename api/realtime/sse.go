@@ -0,0 +1,208 @@
+package realtime
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	ContentTypeEventStream = "text/event-stream"
+	HeaderLastEventID      = "Last-Event-ID"
+
+	defaultHeartbeat = 15 * time.Second
+)
+
+// ErrStreamClosed was returned by Send after the stream was closed.
+var ErrStreamClosed = errors.New("the event stream was closed")
+
+// Event was the Server-Sent Event, the Data would be encoded into JSON unless it's a string or []byte.
+type Event struct {
+	ID    string
+	Event string
+	Data  any
+	// Retry was the reconnection time of the client, it's omitted when it's zero.
+	Retry time.Duration
+}
+
+// SSEOptions was the options of StartSSE.
+type SSEOptions struct {
+	// Heartbeat was the interval of the comment lines to keep the connection alive
+	// through the proxies, default was 15 seconds and it's disabled when it's negative.
+	Heartbeat time.Duration
+	// Retry was the reconnection time sent to the client at the beginning of the stream.
+	Retry time.Duration
+}
+
+// SSEStream writes the Server-Sent Events, it's safe to Send concurrently.
+type SSEStream struct {
+	c           *gin.Context
+	session     *session
+	lastEventID string
+
+	mu     sync.Mutex
+	closed bool
+	done   chan struct{}
+	wg     sync.WaitGroup
+}
+
+// StartSSE starts the Server-Sent Events stream, the stream was tracked as the active session
+// which would be done when the client disconnected or the server was shutting down. The write
+// deadline of the server was cleared for the stream. It responds 50300 and returns ErrShuttingDown
+// if the server was shutting down. The stream must be closed by Close when the handler returns.
+// The route should be marked by middleware.SessionRoute to bypass the timeout, concurrency limit,
+// cache and compression.
+func StartSSE(c *gin.Context, opts *SSEOptions) (*SSEStream, error) {
+	if opts == nil {
+		opts = &SSEOptions{}
+	}
+	stream := &SSEStream{
+		c:           c,
+		lastEventID: c.GetHeader(HeaderLastEventID),
+		done:        make(chan struct{}),
+	}
+	s, err := startSession(c, KindSSE, stream.markClosed)
+	if err != nil {
+		return nil, err
+	}
+	stream.session = s
+	_ = http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{})
+
+	header := c.Writer.Header()
+	header.Set("Content-Type", ContentTypeEventStream)
+	header.Set("Cache-Control", "no-cache")
+	header.Set("Connection", "keep-alive")
+	// disable the response buffering of nginx
+	header.Set("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	var buf bytes.Buffer
+	if opts.Retry > 0 {
+		buf.WriteString("retry: " + strconv.FormatInt(opts.Retry.Milliseconds(), 10) + "\n\n")
+	}
+	if err := stream.write(buf.Bytes()); err != nil {
+		stream.Close()
+		return nil, err
+	}
+
+	heartbeat := opts.Heartbeat
+	if heartbeat == 0 {
+		heartbeat = defaultHeartbeat
+	}
+	stream.wg.Add(1)
+	go stream.run(heartbeat)
+	return stream, nil
+}
+
+// LastEventID returns the Last-Event-ID header of the reconnected client to resume the stream.
+func (stream *SSEStream) LastEventID() string {
+	return stream.lastEventID
+}
+
+// Done returns the channel which was closed when the client disconnected or the server was shutting down.
+func (stream *SSEStream) Done() <-chan struct{} {
+	return stream.done
+}
+
+// Send writes the event and flushes it to the client.
+func (stream *SSEStream) Send(event Event) error {
+	var buf bytes.Buffer
+	if event.ID != "" {
+		buf.WriteString("id: " + singleLine(event.ID) + "\n")
+	}
+	if event.Event != "" {
+		buf.WriteString("event: " + singleLine(event.Event) + "\n")
+	}
+	if event.Retry > 0 {
+		buf.WriteString("retry: " + strconv.FormatInt(event.Retry.Milliseconds(), 10) + "\n")
+	}
+	var data string
+	switch v := event.Data.(type) {
+	case nil:
+	case string:
+		data = v
+	case []byte:
+		data = string(v)
+	default:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		data = string(b)
+	}
+	for _, line := range strings.Split(strings.ReplaceAll(data, "\r\n", "\n"), "\n") {
+		buf.WriteString("data: " + line + "\n")
+	}
+	buf.WriteString("\n")
+	return stream.write(buf.Bytes())
+}
+
+// Close stops the heartbeat and ends the session, it's safe to call multiple times.
+func (stream *SSEStream) Close() {
+	stream.markClosed()
+	stream.wg.Wait()
+	stream.session.end()
+}
+
+func (stream *SSEStream) write(data []byte) error {
+	stream.mu.Lock()
+	defer stream.mu.Unlock()
+	if stream.closed {
+		return ErrStreamClosed
+	}
+	if len(data) > 0 {
+		if _, err := stream.c.Writer.Write(data); err != nil {
+			return err
+		}
+	}
+	stream.c.Writer.Flush()
+	return nil
+}
+
+func (stream *SSEStream) markClosed() {
+	stream.mu.Lock()
+	defer stream.mu.Unlock()
+	if !stream.closed {
+		stream.closed = true
+		close(stream.done)
+	}
+}
+
+// run writes the heartbeats and marks the stream done when the client disconnected or the server was shutting down.
+func (stream *SSEStream) run(heartbeat time.Duration) {
+	defer stream.wg.Done()
+	var ticks <-chan time.Time
+	if heartbeat > 0 {
+		ticker := time.NewTicker(heartbeat)
+		defer ticker.Stop()
+		ticks = ticker.C
+	}
+	for {
+		select {
+		case <-stream.done:
+			return
+		case <-stream.c.Request.Context().Done():
+			stream.markClosed()
+			return
+		case <-stream.session.closing:
+			stream.markClosed()
+			return
+		case <-ticks:
+			if err := stream.write([]byte(": heartbeat\n\n")); err != nil {
+				stream.markClosed()
+				return
+			}
+		}
+	}
+}
+
+func singleLine(s string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(s)
+}
@@ -0,0 +1,185 @@
+package realtime
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	rsp "github.com/SyntSugar/ss-infra-go/api/response"
+	"github.com/SyntSugar/ss-infra-go/consts"
+)
+
+func resetSessions() {
+	sessions = NewRegistry()
+}
+
+// readEvents reads the events of stream until n blank lines were read.
+func readEvents(t *testing.T, reader *bufio.Reader, n int) []string {
+	var lines []string
+	for n > 0 {
+		line, err := reader.ReadString('\n')
+		require.Nil(t, err)
+		line = strings.TrimSuffix(line, "\n")
+		if line == "" {
+			n--
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func TestSSE(t *testing.T) {
+	resetSessions()
+	gin.SetMode(gin.ReleaseMode)
+	var kind string
+	engine := gin.New()
+	engine.GET("/events", func(c *gin.Context) {
+		stream, err := StartSSE(c, &SSEOptions{Heartbeat: 50 * time.Millisecond, Retry: 3 * time.Second})
+		require.Nil(t, err)
+		defer stream.Close()
+		kind = c.GetString(string(consts.ContextKeySession))
+		assert.Equal(t, 1, ActiveSessions())
+
+		assert.Nil(t, stream.Send(Event{ID: "7", Event: "message", Data: "line1\nline2"}))
+		assert.Nil(t, stream.Send(Event{ID: stream.LastEventID() + "+1", Data: map[string]int{"n": 1}}))
+		<-stream.Done()
+		assert.Equal(t, ErrStreamClosed, stream.Send(Event{Data: "closed"}))
+	})
+	server := httptest.NewServer(engine)
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/events", nil)
+	req.Header.Set("Accept", ContentTypeEventStream)
+	req.Header.Set(HeaderLastEventID, "6")
+	resp, err := http.DefaultClient.Do(req)
+	require.Nil(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, ContentTypeEventStream, resp.Header.Get("Content-Type"))
+	assert.Equal(t, "no-cache", resp.Header.Get("Cache-Control"))
+
+	reader := bufio.NewReader(resp.Body)
+	assert.Equal(t, []string{
+		"retry: 3000", "",
+		"id: 7", "event: message", "data: line1", "data: line2", "",
+		"id: 6+1", `data: {"n":1}`, "",
+		": heartbeat", "",
+	}, readEvents(t, reader, 4))
+
+	// the stream would be done after the client disconnected
+	cancel()
+	assert.Eventually(t, func() bool { return ActiveSessions() == 0 }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, string(KindSSE), kind)
+}
+
+func TestSSECloseSessions(t *testing.T) {
+	resetSessions()
+	defer resetSessions()
+	gin.SetMode(gin.ReleaseMode)
+	started := make(chan struct{}, 1)
+	engine := gin.New()
+	engine.GET("/events", func(c *gin.Context) {
+		stream, err := StartSSE(c, nil)
+		if err != nil {
+			return
+		}
+		defer stream.Close()
+		started <- struct{}{}
+		<-stream.Done()
+	})
+	server := httptest.NewServer(engine)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/events")
+	require.Nil(t, err)
+	defer resp.Body.Close()
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assert.Nil(t, CloseSessions(ctx))
+	assert.Equal(t, 0, ActiveSessions())
+
+	// the new sessions would be rejected
+	resp, err = http.Get(server.URL + "/events")
+	require.Nil(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	body := bufio.NewReader(resp.Body)
+	line, _ := body.ReadString('\n')
+	r, err := rsp.UnmarshalResponse([]byte(line))
+	require.Nil(t, err)
+	assert.Equal(t, 50300, r.Code())
+}
+
+func TestRegistry(t *testing.T) {
+	resetSessions()
+	defer resetSessions()
+	gin.SetMode(gin.ReleaseMode)
+	registry := NewRegistry()
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	handler := func(c *gin.Context) {
+		stream, err := StartSSE(c, nil)
+		if err != nil {
+			return
+		}
+		defer stream.Close()
+		started <- struct{}{}
+		// the session ignores the notification until it's released
+		<-release
+	}
+	engine := gin.New()
+	engine.Use(registry.Track())
+	engine.GET("/events", handler)
+	server := httptest.NewServer(engine)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/events")
+	require.Nil(t, err)
+	defer resp.Body.Close()
+	<-started
+	assert.Equal(t, 1, registry.Active())
+	assert.Equal(t, 0, ActiveSessions(), "the session was tracked by the registry of the engine")
+
+	// it's safe to close multiple times
+	for i := 0; i < 2; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		assert.ErrorIs(t, registry.Close(ctx), context.DeadlineExceeded)
+		cancel()
+	}
+	close(release)
+	assert.Eventually(t, func() bool { return registry.Active() == 0 }, time.Second, 10*time.Millisecond)
+
+	// the default registry wasn't closed by the registry of the engine
+	other := gin.New()
+	other.GET("/events", func(c *gin.Context) {
+		stream, err := StartSSE(c, nil)
+		if err != nil {
+			return
+		}
+		stream.Close()
+	})
+	w := httptest.NewRecorder()
+	other.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/events", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	resp, err = http.Get(server.URL + "/events")
+	require.Nil(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	registry.Open()
+	resp, err = http.Get(server.URL + "/events")
+	require.Nil(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
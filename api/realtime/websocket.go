@@ -0,0 +1,138 @@
+package realtime
+
+import (
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	defaultPingInterval   = 30 * time.Second
+	defaultControlTimeout = 5 * time.Second
+)
+
+// WebSocketOptions was the options of UpgradeWebSocket.
+type WebSocketOptions struct {
+	// CheckOrigin returns whether the origin was allowed, the same origin was allowed only when it's nil.
+	CheckOrigin func(r *http.Request) bool
+	// Subprotocols was the supported protocols in the order of preference.
+	Subprotocols []string
+	// EnableCompression negotiates the per message compression.
+	EnableCompression bool
+	// ReadLimit was the max size of the message read from the client, it's unlimited when it's zero.
+	ReadLimit int64
+	// PingInterval was the interval of the pings, the connection would be closed if the pong wasn't
+	// received in 2 intervals. Default was 30 seconds and it's disabled when it's negative.
+	PingInterval time.Duration
+}
+
+// WebSocketConn was the WebSocket connection which was tracked as the active session.
+type WebSocketConn struct {
+	*websocket.Conn
+	session   *session
+	raw       atomic.Pointer[websocket.Conn]
+	stop      chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+// UpgradeWebSocket upgrades the request to the WebSocket connection, the connection was tracked as the
+// active session which would receive the going away close frame when the server was shutting down.
+// It responds 50300 and returns ErrShuttingDown if the server was shutting down, and the failure of
+// upgrade was responded by the upgrader. The connection must be closed by Close when the handler returns.
+// The route should be marked by middleware.SessionRoute to bypass the timeout and concurrency limit.
+func UpgradeWebSocket(c *gin.Context, opts *WebSocketOptions) (*WebSocketConn, error) {
+	if opts == nil {
+		opts = &WebSocketOptions{}
+	}
+	wsConn := &WebSocketConn{stop: make(chan struct{})}
+	s, err := startSession(c, KindWebSocket, func() {
+		// the handler would fail to read or write and return
+		if raw := wsConn.raw.Load(); raw != nil {
+			_ = raw.Close()
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	upgrader := websocket.Upgrader{
+		CheckOrigin:       opts.CheckOrigin,
+		Subprotocols:      opts.Subprotocols,
+		EnableCompression: opts.EnableCompression,
+	}
+	// the status would be written into the hijacked connection, record it for the access log and metrics
+	// before upgrading since it couldn't be changed after hijacking, the failure would overwrite it.
+	c.Status(http.StatusSwitchingProtocols)
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		s.end()
+		return nil, err
+	}
+	wsConn.Conn = conn
+	wsConn.raw.Store(conn)
+	wsConn.session = s
+	if opts.ReadLimit > 0 {
+		conn.SetReadLimit(opts.ReadLimit)
+	}
+
+	pingInterval := opts.PingInterval
+	if pingInterval == 0 {
+		pingInterval = defaultPingInterval
+	}
+	if pingInterval > 0 {
+		_ = conn.SetReadDeadline(time.Now().Add(2 * pingInterval))
+		conn.SetPongHandler(func(string) error {
+			return conn.SetReadDeadline(time.Now().Add(2 * pingInterval))
+		})
+	}
+	wsConn.wg.Add(1)
+	go wsConn.run(pingInterval)
+	return wsConn, nil
+}
+
+// Done returns the channel which was closed when the server was shutting down.
+func (conn *WebSocketConn) Done() <-chan struct{} {
+	return conn.session.closing
+}
+
+// Close closes the connection and ends the session, it's safe to call multiple times.
+func (conn *WebSocketConn) Close() error {
+	var err error
+	conn.closeOnce.Do(func() {
+		close(conn.stop)
+		conn.wg.Wait()
+		err = conn.Conn.Close()
+		conn.session.end()
+	})
+	return err
+}
+
+// run sends the pings and the going away close frame when the server was shutting down.
+func (conn *WebSocketConn) run(pingInterval time.Duration) {
+	defer conn.wg.Done()
+	var ticks <-chan time.Time
+	if pingInterval > 0 {
+		ticker := time.NewTicker(pingInterval)
+		defer ticker.Stop()
+		ticks = ticker.C
+	}
+	for {
+		select {
+		case <-conn.stop:
+			return
+		case <-conn.session.closing:
+			// the client would reply the close frame, and then the read of handler would fail
+			message := websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down")
+			_ = conn.WriteControl(websocket.CloseMessage, message, time.Now().Add(defaultControlTimeout))
+			return
+		case <-ticks:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(defaultControlTimeout)); err != nil {
+				return
+			}
+		}
+	}
+}
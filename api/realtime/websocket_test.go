@@ -0,0 +1,87 @@
+package realtime
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebSocket(t *testing.T) {
+	resetSessions()
+	defer resetSessions()
+	gin.SetMode(gin.ReleaseMode)
+	statuses := make(chan int, 2)
+	engine := gin.New()
+	engine.Use(func(c *gin.Context) {
+		c.Next()
+		statuses <- c.Writer.Status()
+	})
+	engine.GET("/ws", func(c *gin.Context) {
+		conn, err := UpgradeWebSocket(c, &WebSocketOptions{PingInterval: 50 * time.Millisecond})
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			messageType, message, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if err := conn.WriteMessage(messageType, message); err != nil {
+				return
+			}
+		}
+	})
+	server := httptest.NewServer(engine)
+	defer server.Close()
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws"
+
+	pings := make(chan struct{}, 10)
+	client, _, err := websocket.DefaultDialer.Dial(url, nil)
+	require.Nil(t, err)
+	defer client.Close()
+	client.SetPingHandler(func(data string) error {
+		select {
+		case pings <- struct{}{}:
+		default:
+		}
+		return client.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(time.Second))
+	})
+	messages := make(chan string, 1)
+	closed := make(chan error, 1)
+	go func() {
+		for {
+			_, message, err := client.ReadMessage()
+			if err != nil {
+				closed <- err
+				return
+			}
+			messages <- string(message)
+		}
+	}()
+	require.Nil(t, client.WriteMessage(websocket.TextMessage, []byte("hello")))
+	assert.Equal(t, "hello", <-messages)
+	assert.Equal(t, 1, ActiveSessions())
+	assert.Eventually(t, func() bool { return len(pings) > 0 }, time.Second, 10*time.Millisecond)
+
+	// the client would receive the going away close frame and reply it
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assert.Nil(t, CloseSessions(ctx))
+	err = <-closed
+	assert.True(t, websocket.IsCloseError(err, websocket.CloseGoingAway), err)
+	assert.Equal(t, 0, ActiveSessions())
+	assert.Equal(t, http.StatusSwitchingProtocols, <-statuses)
+
+	_, resp, err := websocket.DefaultDialer.Dial(url, nil)
+	require.NotNil(t, err)
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+}
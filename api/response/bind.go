@@ -0,0 +1,247 @@
+package response
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// FieldError was the structured error of the request field.
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule,omitempty"`
+	Message string `json:"message"`
+}
+
+const defaultMaxBindBodySize = 4 << 20
+
+var (
+	maxBindBodySize int64 = defaultMaxBindBodySize
+
+	ruleMessagesMu sync.RWMutex
+	// ruleMessages was the message templates of validation rules, the {param} would be
+	// replaced by the rule parameter, e.g. min=3.
+	ruleMessages = map[string]string{
+		"required":    "is required",
+		"required_if": "is required",
+		"min":         "should be at least {param}",
+		"max":         "should be at most {param}",
+		"len":         "should have the length of {param}",
+		"gt":          "should be greater than {param}",
+		"gte":         "should be greater than or equal to {param}",
+		"lt":          "should be less than {param}",
+		"lte":         "should be less than or equal to {param}",
+		"oneof":       "should be one of [{param}]",
+		"email":       "should be a valid email address",
+		"url":         "should be a valid URL",
+		"uuid":        "should be a valid UUID",
+		"numeric":     "should be numeric",
+		"alphanum":    "should be alphanumeric",
+		"datetime":    "should be a datetime in the format {param}",
+	}
+)
+
+// SetMaxBindBodySize sets the max body size of BindJSON, the request with larger body
+// would be responded with 41300. The default was 4MiB and it's unlimited when size <= 0.
+func SetMaxBindBodySize(size int64) {
+	maxBindBodySize = size
+}
+
+// RegisterValidation registers the custom validation rule into the gin's validator,
+// the message would be used as the field error message when the rule failed.
+// The tag name func of the validator wasn't changed, the field names of the errors
+// were resolved from the bound type by the Bind helpers.
+func RegisterValidation(rule string, fn validator.Func, message string) error {
+	v, err := validatorEngine()
+	if err != nil {
+		return err
+	}
+	if err := v.RegisterValidation(rule, fn); err != nil {
+		return err
+	}
+	if message != "" {
+		ruleMessagesMu.Lock()
+		ruleMessages[rule] = message
+		ruleMessagesMu.Unlock()
+	}
+	return nil
+}
+
+// BindJSON decodes the JSON body into T and validates it. The malformed body would be
+// responded with 40000 and the validation failures with 42200, and ok was false then.
+func BindJSON[T any](ctx *gin.Context) (T, bool) {
+	if maxBindBodySize > 0 && ctx.Request.Body != nil {
+		ctx.Request.Body = http.MaxBytesReader(ctx.Writer, ctx.Request.Body, maxBindBodySize)
+	}
+	return bind[T](ctx, binding.JSON)
+}
+
+// BindQuery decodes the query parameters into T with form tags and validates it,
+// the failures were responded like BindJSON.
+func BindQuery[T any](ctx *gin.Context) (T, bool) {
+	return bind[T](ctx, binding.Query)
+}
+
+// BindURI decodes the path parameters into T with uri tags and validates it,
+// the failures were responded like BindJSON.
+func BindURI[T any](ctx *gin.Context) (T, bool) {
+	var obj T
+	m := make(map[string][]string, len(ctx.Params))
+	for _, param := range ctx.Params {
+		m[param.Key] = []string{param.Value}
+	}
+	if err := binding.Uri.BindUri(m, &obj); err != nil {
+		responseWithBindError(ctx, err, reflect.TypeOf(obj))
+		return obj, false
+	}
+	return obj, true
+}
+
+func bind[T any](ctx *gin.Context, b binding.Binding) (T, bool) {
+	var obj T
+	if err := ctx.ShouldBindWith(&obj, b); err != nil {
+		responseWithBindError(ctx, err, reflect.TypeOf(obj))
+		return obj, false
+	}
+	return obj, true
+}
+
+func responseWithBindError(ctx *gin.Context, err error, typ reflect.Type) {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		ResponseWithErrors(ctx, http.StatusRequestEntityTooLarge, 0,
+			[]any{fmt.Sprintf("request body was larger than %d bytes", maxBytesErr.Limit)})
+		return
+	}
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		ResponseWithErrors(ctx, http.StatusRequestTimeout, 0, []any{err.Error()})
+		return
+	}
+	if fieldErrors := validationFieldErrors(err, typ); len(fieldErrors) > 0 {
+		ResponseWithErrors(ctx, http.StatusUnprocessableEntity, 0, fieldErrors)
+		return
+	}
+	ResponseWithErrors(ctx, http.StatusBadRequest, 0, []any{err.Error()})
+}
+
+// validationFieldErrors translates the validation errors of the bound type into the field errors,
+// it returns nil if err wasn't a validation error.
+func validationFieldErrors(err error, typ reflect.Type) []any {
+	var sliceErrs binding.SliceValidationError
+	if errors.As(err, &sliceErrs) {
+		elemType := indirectType(typ)
+		if elemType != nil && (elemType.Kind() == reflect.Slice || elemType.Kind() == reflect.Array) {
+			elemType = elemType.Elem()
+		}
+		var fieldErrors []any
+		for i, err := range sliceErrs {
+			for _, fieldError := range validationFieldErrors(err, elemType) {
+				fe := fieldError.(FieldError)
+				fe.Field = fmt.Sprintf("[%d].%s", i, fe.Field)
+				fieldErrors = append(fieldErrors, fe)
+			}
+		}
+		return fieldErrors
+	}
+	var validationErrs validator.ValidationErrors
+	if !errors.As(err, &validationErrs) {
+		return nil
+	}
+	fieldErrors := make([]any, 0, len(validationErrs))
+	for _, fe := range validationErrs {
+		fieldErrors = append(fieldErrors, FieldError{
+			Field:   fieldPath(typ, fe.StructNamespace()),
+			Rule:    fe.Tag(),
+			Message: ruleMessage(fe),
+		})
+	}
+	return fieldErrors
+}
+
+// fieldPath translates the struct namespace of the validation error into the path of
+// the json, form or uri tag names, e.g. CreateUserRequest.Address.City => address.city.
+// The Go field name was kept if the field wasn't found in the type.
+func fieldPath(typ reflect.Type, namespace string) string {
+	// trim the struct name of namespace
+	_, namespace, _ = strings.Cut(namespace, ".")
+	segments := strings.Split(namespace, ".")
+	for i, segment := range segments {
+		name, index, _ := strings.Cut(segment, "[")
+		typ = indirectType(typ)
+		if typ == nil || typ.Kind() != reflect.Struct {
+			typ = nil
+			continue
+		}
+		field, ok := typ.FieldByName(name)
+		if !ok {
+			typ = nil
+			continue
+		}
+		if tag := tagName(field); tag != "" {
+			name = tag
+		}
+		typ = field.Type
+		if index != "" {
+			// the element type of slice, array or map, e.g. Items[0] or Labels[key]
+			for n := strings.Count(index, "[") + 1; n > 0 && typ != nil; n-- {
+				switch typ = indirectType(typ); typ.Kind() {
+				case reflect.Slice, reflect.Array, reflect.Map:
+					typ = typ.Elem()
+				default:
+					typ = nil
+				}
+			}
+			name += "[" + index
+		}
+		segments[i] = name
+	}
+	return strings.Join(segments, ".")
+}
+
+func indirectType(typ reflect.Type) reflect.Type {
+	for typ != nil && typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	return typ
+}
+
+func ruleMessage(fe validator.FieldError) string {
+	ruleMessagesMu.RLock()
+	message, ok := ruleMessages[fe.Tag()]
+	ruleMessagesMu.RUnlock()
+	if !ok {
+		return fmt.Sprintf("failed on the '%s' rule", fe.Tag())
+	}
+	return strings.ReplaceAll(message, "{param}", fe.Param())
+}
+
+// validatorEngine returns the go-playground validator of gin.
+func validatorEngine() (*validator.Validate, error) {
+	v, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return nil, errors.New("the validator of gin wasn't go-playground validator")
+	}
+	return v, nil
+}
+
+// tagName returns the field name in the json, form or uri tag, it's empty if the field was ignored.
+func tagName(field reflect.StructField) string {
+	for _, key := range []string{"json", "form", "uri"} {
+		name, _, _ := strings.Cut(field.Tag.Get(key), ",")
+		if name == "-" {
+			return ""
+		}
+		if name != "" {
+			return name
+		}
+	}
+	return field.Name
+}
@@ -0,0 +1,156 @@
+package response
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type bindAddress struct {
+	City string `json:"city" binding:"required"`
+}
+
+type bindUser struct {
+	Name    string      `json:"name" binding:"required,min=3"`
+	Age     int         `json:"age" binding:"gte=0,lte=150"`
+	Role    string      `json:"role" binding:"omitempty,ss_role"`
+	Address bindAddress `json:"address"`
+}
+
+type bindUserQuery struct {
+	Page int    `form:"page" binding:"gte=1"`
+	Sort string `form:"sort" binding:"omitempty,oneof=asc desc"`
+}
+
+type bindUserURI struct {
+	ID int `uri:"id" binding:"required,gt=0"`
+}
+
+func TestBind(t *testing.T) {
+	require.Nil(t, RegisterValidation("ss_role", func(fl validator.FieldLevel) bool {
+		return fl.Field().String() == "admin" || fl.Field().String() == "member"
+	}, "should be admin or member"))
+	SetMaxBindBodySize(64)
+	defer SetMaxBindBodySize(defaultMaxBindBodySize)
+
+	router := gin.New()
+	router.POST("/users", func(c *gin.Context) {
+		user, ok := BindJSON[bindUser](c)
+		if !ok {
+			return
+		}
+		ResponseWithCreated(c, user)
+	})
+	router.GET("/users", func(c *gin.Context) {
+		query, ok := BindQuery[bindUserQuery](c)
+		if !ok {
+			return
+		}
+		ResponseWithOK(c, query)
+	})
+	router.GET("/users/:id", func(c *gin.Context) {
+		uri, ok := BindURI[bindUserURI](c)
+		if !ok {
+			return
+		}
+		ResponseWithOK(c, uri)
+	})
+
+	testData := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		code   int
+		errors []any
+	}{
+		{"JSON", http.MethodPost, "/users", `{"name":"ss","age":200,"role":"root"}`, 42200, []any{
+			map[string]any{"field": "name", "rule": "min", "message": "should be at least 3"},
+			map[string]any{"field": "age", "rule": "lte", "message": "should be less than or equal to 150"},
+			map[string]any{"field": "role", "rule": "ss_role", "message": "should be admin or member"},
+			map[string]any{"field": "address.city", "rule": "required", "message": "is required"},
+		}},
+		{"MalformedJSON", http.MethodPost, "/users", `{"name":`, 40000, []any{"unexpected EOF"}},
+		{"TooLarge", http.MethodPost, "/users", `{"name":"` + strings.Repeat("s", 64) + `"}`, 41300,
+			[]any{"request body was larger than 64 bytes"}},
+		{"ValidJSON", http.MethodPost, "/users", `{"name":"ss-go","address":{"city":"sg"}}`, 20100, nil},
+		{"Query", http.MethodGet, "/users?page=0&sort=up", "", 42200, []any{
+			map[string]any{"field": "page", "rule": "gte", "message": "should be greater than or equal to 1"},
+			map[string]any{"field": "sort", "rule": "oneof", "message": "should be one of [asc desc]"},
+		}},
+		{"MalformedQuery", http.MethodGet, "/users?page=abc", "", 40000, nil},
+		{"URI", http.MethodGet, "/users/0", "", 42200, []any{
+			map[string]any{"field": "id", "rule": "required", "message": "is required"},
+		}},
+		{"ValidURI", http.MethodGet, "/users/1", "", 20000, nil},
+	}
+	for _, tt := range testData {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			rsp, err := UnmarshalResponse(w.Body.Bytes())
+			require.Nil(t, err)
+			assert.Equal(t, tt.code, rsp.Code())
+			assert.Equal(t, tt.code/100, w.Code)
+			if tt.errors != nil {
+				assert.Equal(t, tt.errors, rsp.Errors())
+			}
+		})
+	}
+}
+
+type bindItem struct {
+	SKU string `json:"sku" binding:"required"`
+}
+
+type bindOrder struct {
+	Items   []*bindItem       `json:"items" binding:"required,dive"`
+	Labels  map[string]string `json:"labels" binding:"dive,max=3"`
+	Address *bindAddress
+}
+
+func TestBindFieldPath(t *testing.T) {
+	// the tag name func of gin's validator should be left as it was
+	err := binding.Validator.ValidateStruct(&bindUser{})
+	var validationErrs validator.ValidationErrors
+	require.True(t, errors.As(err, &validationErrs))
+	assert.Equal(t, "Name", validationErrs[0].Field())
+
+	router := gin.New()
+	router.POST("/orders", func(c *gin.Context) {
+		if _, ok := BindJSON[bindOrder](c); ok {
+			ResponseWithCreated(c, nil)
+		}
+	})
+	for _, tt := range []struct {
+		path   string
+		body   string
+		fields []string
+	}{
+		{"/orders", `{"items":[{"sku":"a"},{}],"labels":{"k":"long"},"Address":{}}`,
+			[]string{"items[1].sku", "labels[k]", "Address.city"}},
+	} {
+		req := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.body))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		rsp, err := UnmarshalResponse(w.Body.Bytes())
+		require.Nil(t, err)
+		require.Equal(t, 42200, rsp.Code(), w.Body.String())
+		var fields []string
+		for _, fe := range rsp.Errors() {
+			fields = append(fields, fe.(map[string]any)["field"].(string))
+		}
+		assert.Equal(t, tt.fields, fields)
+	}
+}
@@ -0,0 +1,64 @@
+package response
+
+import "fmt"
+
+// APIError was the error meta of response which was converted into the go error.
+type APIError struct {
+	// HTTPStatus was the status code of http response, it may be different
+	// from the status code in meta when the response was rewritten by proxy.
+	HTTPStatus int
+	Code       int
+	SubCode    int
+	Type       string
+	Message    string
+	Errors     []any
+}
+
+// NewAPIError creates the APIError from response meta.
+func NewAPIError(httpStatus int, meta Meta) *APIError {
+	return &APIError{
+		HTTPStatus: httpStatus,
+		Code:       meta.Code,
+		SubCode:    meta.SubCode(),
+		Type:       meta.Type,
+		Message:    meta.Message,
+		Errors:     meta.Errors,
+	}
+}
+
+// Error implements the error interface.
+func (e *APIError) Error() string {
+	if len(e.Errors) > 0 {
+		return fmt.Sprintf("api error(code: %d, type: %s): %s %v", e.Code, e.Type, e.Message, e.Errors)
+	}
+	return fmt.Sprintf("api error(code: %d, type: %s): %s", e.Code, e.Type, e.Message)
+}
+
+// StatusCode return http status code in error's code
+func (e *APIError) StatusCode() int {
+	if e.Code == 0 {
+		return e.HTTPStatus
+	}
+	return e.Code / 100
+}
+
+// Is reports whether target was the APIError with the same code,
+// the zero code of target matches any http status in its status code.
+func (e *APIError) Is(target error) bool {
+	t, ok := target.(*APIError)
+	if !ok {
+		return false
+	}
+	if t.Code != 0 {
+		return t.Code == e.Code
+	}
+	return t.HTTPStatus != 0 && t.HTTPStatus == e.StatusCode()
+}
+
+// Err return the APIError if the response was error, otherwise nil
+func (r *Response) Err(httpStatus int) error {
+	if !r.IsError() {
+		return nil
+	}
+	return NewAPIError(httpStatus, r.Meta)
+}
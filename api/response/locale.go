@@ -0,0 +1,225 @@
+package response
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/SyntSugar/ss-infra-go/consts"
+	prome "github.com/SyntSugar/ss-infra-go/prometheus"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/valyala/fasttemplate"
+	"golang.org/x/text/language"
+)
+
+// The message catalogs of the built-in codes, each file was named by the locale(e.g. zh.json)
+// and maps the code to the translated message. English was kept in code2Description.
+//
+//go:embed locales/*.json
+var localeFS embed.FS
+
+// Translation was the message of the code in the locale, e.g. {Locale: "zh", Message: "..."}
+type Translation struct {
+	Locale  string
+	Message string
+}
+
+type messageOverride struct {
+	template string
+	params   map[string]any
+}
+
+type catalogs struct {
+	mu       sync.RWMutex
+	messages map[language.Tag]map[int]string
+	// supported was the tags of matcher in order, they were rebuilt when a new locale was added.
+	supported []language.Tag
+	matcher   language.Matcher
+}
+
+var (
+	localeCatalogs = &catalogs{messages: make(map[language.Tag]map[int]string)}
+
+	missingTranslations *prometheus.CounterVec
+)
+
+func init() {
+	missingTranslations = prome.NewCounterHelper("infra", "response", "missing_translation", "locale", "code")
+	if err := loadEmbeddedCatalogs(); err != nil {
+		panic(err)
+	}
+}
+
+func loadEmbeddedCatalogs() error {
+	entries, err := localeFS.ReadDir("locales")
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		file, err := localeFS.Open(path.Join("locales", name))
+		if err != nil {
+			return err
+		}
+		err = LoadCatalog(strings.TrimSuffix(name, path.Ext(name)), file)
+		file.Close()
+		if err != nil {
+			return fmt.Errorf("load locale catalog(%s) err: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// LoadCatalog loads the JSON catalog which maps the code to the message in the locale,
+// the messages would overwrite the previous ones of the same codes.
+func LoadCatalog(locale string, r io.Reader) error {
+	tag, err := language.Parse(locale)
+	if err != nil {
+		return err
+	}
+	var catalog map[string]string
+	if err := json.NewDecoder(r).Decode(&catalog); err != nil {
+		return err
+	}
+	messages := make(map[int]string, len(catalog))
+	for key, message := range catalog {
+		code, err := strconv.Atoi(key)
+		if err != nil {
+			return fmt.Errorf("invalid code %q", key)
+		}
+		messages[code] = message
+	}
+	localeCatalogs.add(tag, messages)
+	return nil
+}
+
+// RegisterTranslation was used to register the message of the code in the locale,
+// it would overwrite the previous one when conflicted.
+func RegisterTranslation(locale string, code int, message string) error {
+	if message == "" {
+		return errors.New("message shouldn't be empty")
+	}
+	tag, err := language.Parse(locale)
+	if err != nil {
+		return err
+	}
+	localeCatalogs.add(tag, map[int]string{code: message})
+	return nil
+}
+
+func (c *catalogs) add(tag language.Tag, messages map[int]string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.messages[tag]; !ok {
+		c.messages[tag] = make(map[int]string, len(messages))
+		// the matcher was rebuilt here so that the matching only needs the read lock
+		c.supported = make([]language.Tag, 0, len(c.messages)+1)
+		c.supported = append(c.supported, language.English)
+		for tag := range c.messages {
+			c.supported = append(c.supported, tag)
+		}
+		c.matcher = language.NewMatcher(c.supported)
+	}
+	for code, message := range messages {
+		c.messages[tag][code] = message
+	}
+}
+
+// match returns the supported locale of the Accept-Language header, English was the fallback.
+func (c *catalogs) match(acceptLanguage string) language.Tag {
+	desired, _, err := language.ParseAcceptLanguage(acceptLanguage)
+	if err != nil || len(desired) == 0 {
+		return language.English
+	}
+	c.mu.RLock()
+	if c.matcher == nil {
+		c.mu.RUnlock()
+		return language.English
+	}
+	_, index, confidence := c.matcher.Match(desired...)
+	tag := c.supported[index]
+	c.mu.RUnlock()
+	if confidence == language.No {
+		return language.English
+	}
+	return tag
+}
+
+func (c *catalogs) message(tag language.Tag, code int) (string, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	message, ok := c.messages[tag][code]
+	return message, ok
+}
+
+// WithLocale sets the locale of the response messages, it would take precedence over
+// the locale in the request context and the Accept-Language header.
+func WithLocale(ctx *gin.Context, locale string) {
+	ctx.Set(string(consts.ContextKeyLocale), locale)
+}
+
+// Locale returns the locale of the response messages, it was chosen from the value set by WithLocale,
+// the consts.ContextKeyLocale value of the request context and the Accept-Language header in order.
+func Locale(ctx *gin.Context) language.Tag {
+	if locale := ctx.GetString(string(consts.ContextKeyLocale)); locale != "" {
+		return localeCatalogs.match(locale)
+	}
+	if ctx.Request == nil {
+		return language.English
+	}
+	if locale, ok := ctx.Request.Context().Value(consts.ContextKeyLocale).(string); ok && locale != "" {
+		return localeCatalogs.match(locale)
+	}
+	return localeCatalogs.match(ctx.Request.Header.Get("Accept-Language"))
+}
+
+// OverrideMessage overrides the message of the response in this request, the {name} placeholders
+// of the template would be replaced by the params. The localized message of the code would be used
+// as the template when the template was empty, e.g. the registered message "The {name} was not found."
+func OverrideMessage(ctx *gin.Context, template string, params map[string]any) {
+	ctx.Set(string(consts.ContextKeyResponseMessage), &messageOverride{template: template, params: params})
+}
+
+// localizedMessage returns the message of the code in the locale of request, it would
+// fall back to English and count the missing translation when it's not found.
+func localizedMessage(ctx *gin.Context, code int) string {
+	message := HttpCodeDescription(code).Message
+	if tag := Locale(ctx); tag != language.English {
+		if translated, ok := localeCatalogs.message(tag, code); ok {
+			message = translated
+			ctx.Header("Content-Language", tag.String())
+		} else if message != "" {
+			missingTranslations.WithLabelValues(tag.String(), strconv.Itoa(code)).Inc()
+		}
+	}
+
+	v, ok := ctx.Get(string(consts.ContextKeyResponseMessage))
+	if !ok {
+		return message
+	}
+	override, ok := v.(*messageOverride)
+	if !ok {
+		return message
+	}
+	if override.template != "" {
+		message = override.template
+	}
+	if len(override.params) == 0 {
+		return message
+	}
+	return fasttemplate.ExecuteFuncString(message, "{", "}", func(w io.Writer, tag string) (int, error) {
+		param, ok := override.params[tag]
+		if !ok {
+			return w.Write([]byte("{" + tag + "}"))
+		}
+		return fmt.Fprint(w, param)
+	})
+}
@@ -0,0 +1,127 @@
+package response
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/SyntSugar/ss-infra-go/consts"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/text/language"
+)
+
+func TestLocalizedMessage(t *testing.T) {
+	require.Nil(t, RegisterCustomCode(40410, "", "The {name} was not found.",
+		Translation{Locale: "zh", Message: "未找到{name}。"}))
+	defer delete(code2Description, 40410)
+
+	router := gin.New()
+	router.GET("/ok", func(c *gin.Context) {
+		ResponseWithOK(c, nil)
+	})
+	router.GET("/users/:id", func(c *gin.Context) {
+		OverrideMessage(c, "", map[string]any{"name": "user " + c.Param("id")})
+		ResponseWithErrors(c, http.StatusNotFound, 10, nil)
+	})
+	router.GET("/override", func(c *gin.Context) {
+		OverrideMessage(c, "Only {limit} requests per second are allowed.", map[string]any{"limit": 10})
+		ResponseWithErrors(c, http.StatusTooManyRequests, 0, nil)
+	})
+	router.GET("/locale", func(c *gin.Context) {
+		WithLocale(c, "ja")
+		ResponseWithOK(c, nil)
+	})
+
+	testData := []struct {
+		name           string
+		path           string
+		acceptLanguage string
+		message        string
+		contentLang    string
+	}{
+		{"English", "/ok", "", "The request was successfully processed.", ""},
+		{"Chinese", "/ok", "zh-CN,zh;q=0.9,en;q=0.8", "请求已成功处理。", "zh"},
+		{"Japanese", "/ok", "ja-JP", "リクエストは正常に処理されました。", "ja"},
+		{"Unsupported", "/ok", "fr-FR", "The request was successfully processed.", ""},
+		{"TemplateEnglish", "/users/1", "", "The user 1 was not found.", ""},
+		{"TemplateChinese", "/users/1", "zh-TW", "未找到user 1。", "zh"},
+		{"Override", "/override", "zh", "Only 10 requests per second are allowed.", "zh"},
+		{"WithLocale", "/locale", "zh", "リクエストは正常に処理されました。", "ja"},
+	}
+	for _, tt := range testData {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.acceptLanguage != "" {
+				req.Header.Set("Accept-Language", tt.acceptLanguage)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			rsp, err := UnmarshalResponse(w.Body.Bytes())
+			require.Nil(t, err)
+			assert.Equal(t, tt.message, rsp.Message())
+			assert.Equal(t, tt.contentLang, w.Header().Get("Content-Language"))
+		})
+	}
+}
+
+func TestLocaleFromContext(t *testing.T) {
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	ctx.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+	ctx.Request.Header.Set("Accept-Language", "ja")
+	ctx.Request = ctx.Request.WithContext(context.WithValue(ctx.Request.Context(), consts.ContextKeyLocale, "zh-Hans"))
+	assert.Equal(t, "zh", Locale(ctx).String())
+}
+
+func TestMissingTranslation(t *testing.T) {
+	require.Nil(t, RegisterCustomCode(40911, "", "The resource was locked."))
+	defer delete(code2Description, 40911)
+
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	ctx.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+	ctx.Request.Header.Set("Accept-Language", "ja")
+	before := testutil.ToFloat64(missingTranslations.WithLabelValues("ja", "40911"))
+	assert.Equal(t, "The resource was locked.", localizedMessage(ctx, 40911))
+	assert.Equal(t, before+1, testutil.ToFloat64(missingTranslations.WithLabelValues("ja", "40911")))
+}
+
+func TestLoadCatalog(t *testing.T) {
+	assert.NotNil(t, LoadCatalog("zh", strings.NewReader(`{"abc": "message"}`)))
+	assert.NotNil(t, LoadCatalog("!invalid", strings.NewReader(`{}`)))
+	assert.NotNil(t, RegisterCustomCode(40912, "", "message", Translation{Locale: "zh"}))
+
+	require.Nil(t, LoadCatalog("de", strings.NewReader(`{"20000": "Die Anfrage wurde erfolgreich verarbeitet."}`)))
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	ctx.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+	ctx.Request.Header.Set("Accept-Language", "de-AT")
+	assert.Equal(t, "Die Anfrage wurde erfolgreich verarbeitet.", localizedMessage(ctx, 20000))
+}
+
+func TestCatalogsConcurrentMatch(t *testing.T) {
+	c := &catalogs{messages: make(map[language.Tag]map[int]string)}
+	assert.Equal(t, language.English, c.match("zh"))
+	c.add(language.Chinese, map[int]string{40000: "错误"})
+
+	locales := []string{"fr", "es", "it", "ko", "pt", "ru", "nl", "sv", "pl", "tr"}
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				if i == 0 && j%10 == 0 {
+					c.add(language.Make(locales[j/10]), map[int]string{40000: "erreur"})
+				}
+				assert.Equal(t, language.Chinese, c.match("zh-CN,zh;q=0.9"))
+			}
+		}(i)
+	}
+	wg.Wait()
+	assert.Equal(t, language.English, c.match("de"))
+}
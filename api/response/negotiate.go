@@ -0,0 +1,96 @@
+package response
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/gin-gonic/gin/render"
+	"google.golang.org/protobuf/proto"
+)
+
+// HeaderResponseCode carries the code of meta when the data was written in protobuf,
+// since the protobuf body was the data message itself without the envelope.
+const HeaderResponseCode = "X-Response-Code"
+
+// renderResponse would write the response in the format negotiated by the Accept header,
+// MessagePack would encode the whole envelope and protobuf was only offered when the data
+// was a proto.Message. It would fall back to JSON when no format was acceptable.
+func renderResponse(ctx *gin.Context, statusCode int, rsp *Response) {
+	offered := []string{binding.MIMEJSON, binding.MIMEMSGPACK, binding.MIMEMSGPACK2}
+	if _, ok := rsp.Data.(proto.Message); ok {
+		offered = append(offered, binding.MIMEPROTOBUF)
+	}
+	var format string
+	if ctx.Request != nil && ctx.Request.Header.Get("Accept") != "" {
+		format = negotiate(ctx.Request.Header.Get("Accept"), offered...)
+	}
+	switch format {
+	case binding.MIMEMSGPACK, binding.MIMEMSGPACK2:
+		ctx.Render(statusCode, render.MsgPack{Data: rsp})
+	case binding.MIMEPROTOBUF:
+		ctx.Header(HeaderResponseCode, strconv.Itoa(rsp.Meta.Code))
+		ctx.Render(statusCode, render.ProtoBuf{Data: rsp.Data})
+	default:
+		ctx.JSON(statusCode, rsp)
+	}
+}
+
+type acceptRange struct {
+	mediaType string
+	quality   float64
+}
+
+// parseAccept parses the Accept header into the media ranges in the preference order of client,
+// the ranges were sorted by the quality and the ranges with q=0 were dropped.
+func parseAccept(accept string) []acceptRange {
+	ranges := make([]acceptRange, 0, strings.Count(accept, ",")+1)
+	for _, part := range strings.Split(accept, ",") {
+		params := strings.Split(part, ";")
+		mediaType := strings.ToLower(strings.TrimSpace(params[0]))
+		if mediaType == "" {
+			continue
+		}
+		quality := 1.0
+		for _, param := range params[1:] {
+			key, value, found := strings.Cut(strings.TrimSpace(param), "=")
+			if !found || !strings.EqualFold(strings.TrimSpace(key), "q") {
+				continue
+			}
+			if q, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil && q >= 0 && q <= 1 {
+				quality = q
+			}
+		}
+		if quality > 0 {
+			ranges = append(ranges, acceptRange{mediaType: mediaType, quality: quality})
+		}
+	}
+	sort.SliceStable(ranges, func(i, j int) bool {
+		return ranges[i].quality > ranges[j].quality
+	})
+	return ranges
+}
+
+// match returns true if the media type was covered by the range, e.g. */* or application/*.
+func (r acceptRange) match(mediaType string) bool {
+	if r.mediaType == "*/*" || r.mediaType == mediaType {
+		return true
+	}
+	prefix, found := strings.CutSuffix(r.mediaType, "/*")
+	return found && strings.HasPrefix(mediaType, prefix+"/")
+}
+
+// negotiate returns the first offered media type of the most preferred range in the Accept header,
+// or empty string if no offered media type was acceptable.
+func negotiate(accept string, offered ...string) string {
+	for _, r := range parseAccept(accept) {
+		for _, mediaType := range offered {
+			if r.match(mediaType) {
+				return mediaType
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,79 @@
+package response
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/ugorji/go/codec"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestResponseNegotiation(t *testing.T) {
+	router := gin.New()
+	router.GET("/user", func(c *gin.Context) {
+		ResponseWithOK(c, map[string]any{"name": "ss"})
+	})
+	router.GET("/proto", func(c *gin.Context) {
+		ResponseWithOK(c, wrapperspb.String("ss"))
+	})
+
+	testData := []struct {
+		name        string
+		path        string
+		accept      string
+		contentType string
+	}{
+		{"Default", "/user", "", "application/json; charset=utf-8"},
+		{"Browser", "/user", "text/html", "application/json; charset=utf-8"},
+		{"MsgPack", "/user", "application/msgpack", "application/msgpack; charset=utf-8"},
+		{"ProtobufNotOffered", "/user", "application/x-protobuf", "application/json; charset=utf-8"},
+		{"Protobuf", "/proto", "application/x-protobuf", "application/x-protobuf"},
+		{"Quality", "/user", "application/json;q=0.5, application/msgpack", "application/msgpack; charset=utf-8"},
+		{"RejectMsgPack", "/user", "application/msgpack;q=0, application/*", "application/json; charset=utf-8"},
+	}
+	for _, tt := range testData {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, tt.contentType, w.Header().Get("Content-Type"))
+		})
+	}
+
+	t.Run("DecodeMsgPack", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/user", nil)
+		req.Header.Set("Accept", "application/x-msgpack")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		var rsp struct {
+			Meta Meta              `codec:"meta"`
+			Data map[string]string `codec:"data"`
+		}
+		require.Nil(t, codec.NewDecoderBytes(w.Body.Bytes(), &codec.MsgpackHandle{}).Decode(&rsp))
+		assert.Equal(t, 20000, rsp.Meta.Code)
+		assert.Equal(t, "OK", rsp.Meta.Type)
+		assert.Equal(t, "ss", rsp.Data["name"])
+	})
+
+	t.Run("DecodeProtobuf", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/proto", nil)
+		req.Header.Set("Accept", "application/x-protobuf")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		var value wrapperspb.StringValue
+		require.Nil(t, proto.Unmarshal(w.Body.Bytes(), &value))
+		assert.Equal(t, "ss", value.GetValue())
+		assert.Equal(t, "20000", w.Header().Get(HeaderResponseCode))
+	})
+}
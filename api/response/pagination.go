@@ -0,0 +1,258 @@
+package response
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	QueryPage     = "page"
+	QueryPageSize = "page_size"
+	QueryCursor   = "cursor"
+
+	defaultPageSize = 20
+	maxPageSize     = 100
+
+	// minCursorKeyLen was the minimum length of the cursor signing key, which was the block size of SHA-256
+	// HMAC recommended by RFC 2104.
+	minCursorKeyLen = 32
+)
+
+var (
+	ErrInvalidPage       = errors.New("page should be a positive integer")
+	ErrInvalidPageSize   = errors.New("page_size should be a positive integer")
+	ErrInvalidCursor     = errors.New("cursor was invalid")
+	ErrCursorKeyTooShort = fmt.Errorf("cursor key should be at least %d bytes", minCursorKeyLen)
+)
+
+// PageOptions limits the page size of the pagination query.
+type PageOptions struct {
+	DefaultPageSize int
+	MaxPageSize     int
+}
+
+// DefaultPageOptions was used when the options was nil.
+var DefaultPageOptions = &PageOptions{
+	DefaultPageSize: defaultPageSize,
+	MaxPageSize:     maxPageSize,
+}
+
+// Pagination was the pagination metadata of the paginated response.
+type Pagination struct {
+	Page       int    `json:"page,omitempty"`
+	PageSize   int    `json:"page_size"`
+	Total      *int64 `json:"total,omitempty"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	PrevCursor string `json:"prev_cursor,omitempty"`
+	HasMore    bool   `json:"has_more"`
+}
+
+// OffsetPage was the offset pagination query.
+type OffsetPage struct {
+	Page     int
+	PageSize int
+}
+
+// Offset returns the offset of the first item in page.
+func (p OffsetPage) Offset() int {
+	return (p.Page - 1) * p.PageSize
+}
+
+// Pagination returns the pagination metadata of the page with the total items.
+func (p OffsetPage) Pagination(total int64) Pagination {
+	return Pagination{
+		Page:     p.Page,
+		PageSize: p.PageSize,
+		Total:    &total,
+		HasMore:  int64(p.Page*p.PageSize) < total,
+	}
+}
+
+// CursorPage was the cursor pagination query, the Cursor was empty for the first page.
+type CursorPage struct {
+	Cursor   string
+	PageSize int
+}
+
+// ParseOffsetPage parses and validates the page and page_size query params.
+func ParseOffsetPage(ctx *gin.Context, opts *PageOptions) (OffsetPage, error) {
+	pageSize, err := parsePageSize(ctx, opts)
+	if err != nil {
+		return OffsetPage{}, err
+	}
+	page := 1
+	if v := ctx.Query(QueryPage); v != "" {
+		page, err = strconv.Atoi(v)
+		if err != nil || page <= 0 {
+			return OffsetPage{}, ErrInvalidPage
+		}
+		// the offset of page should not overflow
+		if maxPage := math.MaxInt / pageSize; page > maxPage {
+			return OffsetPage{}, fmt.Errorf("page should not be greater than %d", maxPage)
+		}
+	}
+	return OffsetPage{Page: page, PageSize: pageSize}, nil
+}
+
+// ParseCursorPage parses the cursor and page_size query params, the cursor would be
+// verified and decoded into v by signer if it's not empty.
+func ParseCursorPage(ctx *gin.Context, opts *PageOptions, signer *CursorSigner, v any) (CursorPage, error) {
+	pageSize, err := parsePageSize(ctx, opts)
+	if err != nil {
+		return CursorPage{}, err
+	}
+	cursor := ctx.Query(QueryCursor)
+	if cursor != "" {
+		if err := signer.Decode(cursor, v); err != nil {
+			return CursorPage{}, err
+		}
+	}
+	return CursorPage{Cursor: cursor, PageSize: pageSize}, nil
+}
+
+func parsePageSize(ctx *gin.Context, opts *PageOptions) (int, error) {
+	if opts == nil {
+		opts = DefaultPageOptions
+	}
+	v := ctx.Query(QueryPageSize)
+	if v == "" {
+		return opts.DefaultPageSize, nil
+	}
+	pageSize, err := strconv.Atoi(v)
+	if err != nil || pageSize <= 0 {
+		return 0, ErrInvalidPageSize
+	}
+	if opts.MaxPageSize > 0 && pageSize > opts.MaxPageSize {
+		return 0, fmt.Errorf("page_size should not be greater than %d", opts.MaxPageSize)
+	}
+	return pageSize, nil
+}
+
+// CursorSigner encodes the cursor state into the opaque cursor with the HMAC
+// signature, so the clients can't forge the cursor.
+type CursorSigner struct {
+	key []byte
+}
+
+// NewCursorSigner creates the cursor signer with the secret key, it returns ErrCursorKeyTooShort
+// if the key was shorter than 32 bytes.
+func NewCursorSigner(key []byte) (*CursorSigner, error) {
+	if len(key) < minCursorKeyLen {
+		return nil, ErrCursorKeyTooShort
+	}
+	return &CursorSigner{key: key}, nil
+}
+
+// Encode marshals v into the signed opaque cursor.
+func (s *CursorSigner) Encode(v any) (string, error) {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	encoding := base64.RawURLEncoding
+	return encoding.EncodeToString(payload) + "." + encoding.EncodeToString(s.sign(payload)), nil
+}
+
+// Decode verifies the signature of cursor and unmarshals it into v.
+func (s *CursorSigner) Decode(cursor string, v any) error {
+	encodedPayload, encodedSig, found := strings.Cut(cursor, ".")
+	if !found {
+		return ErrInvalidCursor
+	}
+	encoding := base64.RawURLEncoding
+	payload, err := encoding.DecodeString(encodedPayload)
+	if err != nil {
+		return ErrInvalidCursor
+	}
+	sig, err := encoding.DecodeString(encodedSig)
+	if err != nil || !hmac.Equal(sig, s.sign(payload)) {
+		return ErrInvalidCursor
+	}
+	if v == nil {
+		return nil
+	}
+	if err := json.Unmarshal(payload, v); err != nil {
+		return ErrInvalidCursor
+	}
+	return nil
+}
+
+func (s *CursorSigner) sign(payload []byte) []byte {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
+
+// ResponseWithPage would write the data with pagination metadata in status ok,
+// and the RFC 8288 Link headers of the first, prev, next and last pages.
+func ResponseWithPage(ctx *gin.Context, data any, pagination Pagination) {
+	if links := pageLinks(ctx, pagination); len(links) > 0 {
+		ctx.Header("Link", strings.Join(links, ", "))
+	}
+	code := buildAPICode(http.StatusOK, 0)
+	desc := HttpCodeDescription(code)
+	renderResponse(ctx, http.StatusOK, &Response{
+		Meta: Meta{
+			Code:    code,
+			Type:    desc.Status,
+			Message: localizedMessage(ctx, code),
+		},
+		Data:       data,
+		Pagination: &pagination,
+	})
+}
+
+func pageLinks(ctx *gin.Context, pagination Pagination) []string {
+	if ctx.Request == nil || ctx.Request.URL == nil {
+		return nil
+	}
+	link := func(rel string, params map[string]string) string {
+		u := *ctx.Request.URL
+		query := u.Query()
+		for k, v := range params {
+			query.Set(k, v)
+		}
+		u.RawQuery = query.Encode()
+		return fmt.Sprintf("<%s>; rel=\"%s\"", u.RequestURI(), rel)
+	}
+	pageSize := strconv.Itoa(pagination.PageSize)
+
+	var links []string
+	if pagination.Page > 0 {
+		page := func(p int) map[string]string {
+			return map[string]string{QueryPage: strconv.Itoa(p), QueryPageSize: pageSize}
+		}
+		links = append(links, link("first", page(1)))
+		if pagination.Page > 1 {
+			links = append(links, link("prev", page(pagination.Page-1)))
+		}
+		if pagination.HasMore {
+			links = append(links, link("next", page(pagination.Page+1)))
+		}
+		if pagination.Total != nil && pagination.PageSize > 0 {
+			last := int((*pagination.Total + int64(pagination.PageSize) - 1) / int64(pagination.PageSize))
+			if last < 1 {
+				last = 1
+			}
+			links = append(links, link("last", page(last)))
+		}
+		return links
+	}
+	if pagination.PrevCursor != "" {
+		links = append(links, link("prev", map[string]string{QueryCursor: pagination.PrevCursor, QueryPageSize: pageSize}))
+	}
+	if pagination.HasMore && pagination.NextCursor != "" {
+		links = append(links, link("next", map[string]string{QueryCursor: pagination.NextCursor, QueryPageSize: pageSize}))
+	}
+	return links
+}
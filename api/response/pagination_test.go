@@ -0,0 +1,93 @@
+package response
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseOffsetPage(t *testing.T) {
+	testData := []struct {
+		query    string
+		page     OffsetPage
+		hasError bool
+	}{
+		{"", OffsetPage{Page: 1, PageSize: defaultPageSize}, false},
+		{"page=3&page_size=50", OffsetPage{Page: 3, PageSize: 50}, false},
+		{"page=0", OffsetPage{}, true},
+		{"page=abc", OffsetPage{}, true},
+		{"page=" + strconv.Itoa(math.MaxInt/50) + "&page_size=50", OffsetPage{Page: math.MaxInt / 50, PageSize: 50}, false},
+		{"page=" + strconv.Itoa(math.MaxInt/50+1) + "&page_size=50", OffsetPage{}, true},
+		{"page=9223372036854775808", OffsetPage{}, true},
+		{"page_size=-1", OffsetPage{}, true},
+		{"page_size=101", OffsetPage{}, true},
+	}
+	for _, tt := range testData {
+		ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+		ctx.Request = httptest.NewRequest(http.MethodGet, "/users?"+tt.query, nil)
+		page, err := ParseOffsetPage(ctx, nil)
+		assert.Equal(t, tt.hasError, err != nil, tt.query)
+		assert.Equal(t, tt.page, page, tt.query)
+	}
+	assert.Equal(t, 100, OffsetPage{Page: 3, PageSize: 50}.Offset())
+}
+
+func TestCursorSigner(t *testing.T) {
+	type state struct {
+		LastID int64 `json:"last_id"`
+	}
+	_, err := NewCursorSigner([]byte("secret"))
+	assert.Equal(t, ErrCursorKeyTooShort, err)
+	signer, err := NewCursorSigner([]byte(strings.Repeat("s", 32)))
+	require.Nil(t, err)
+	cursor, err := signer.Encode(state{LastID: 42})
+	require.Nil(t, err)
+
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	ctx.Request = httptest.NewRequest(http.MethodGet, "/users?page_size=10&cursor="+cursor, nil)
+	var s state
+	page, err := ParseCursorPage(ctx, nil, signer, &s)
+	require.Nil(t, err)
+	assert.Equal(t, CursorPage{Cursor: cursor, PageSize: 10}, page)
+	assert.Equal(t, int64(42), s.LastID)
+
+	forger, err := NewCursorSigner([]byte(strings.Repeat("f", 32)))
+	require.Nil(t, err)
+	forged, err := forger.Encode(state{LastID: 1})
+	require.Nil(t, err)
+	assert.Equal(t, ErrInvalidCursor, signer.Decode(forged, &s))
+	assert.Equal(t, ErrInvalidCursor, signer.Decode("garbage", &s))
+}
+
+func TestResponseWithPage(t *testing.T) {
+	router := gin.New()
+	router.GET("/users", func(c *gin.Context) {
+		page, err := ParseOffsetPage(c, nil)
+		if err != nil {
+			ResponseError(c, http.StatusBadRequest, err)
+			return
+		}
+		ResponseWithPage(c, []string{"foo", "bar"}, page.Pagination(45))
+	})
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users?page=2&page_size=20&sort=name", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `</users?page=1&page_size=20&sort=name>; rel="first", `+
+		`</users?page=1&page_size=20&sort=name>; rel="prev", `+
+		`</users?page=3&page_size=20&sort=name>; rel="next", `+
+		`</users?page=3&page_size=20&sort=name>; rel="last"`, w.Header().Get("Link"))
+
+	rsp, err := UnmarshalResponse(w.Body.Bytes())
+	require.Nil(t, err)
+	require.NotNil(t, rsp.Pagination)
+	assert.Equal(t, 2, rsp.Pagination.Page)
+	assert.Equal(t, int64(45), *rsp.Pagination.Total)
+	assert.True(t, rsp.Pagination.HasMore)
+}
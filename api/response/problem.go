@@ -0,0 +1,143 @@
+package response
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/SyntSugar/ss-infra-go/consts"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+// Format was the output format of the error response.
+type Format string
+
+const (
+	// FormatEnvelope was the default {meta, data} envelope.
+	FormatEnvelope Format = "envelope"
+	// FormatProblem was the RFC 7807 problem details.
+	FormatProblem Format = "problem"
+
+	ContentTypeProblemJSON = "application/problem+json"
+)
+
+// problemTypeBaseURI was the base of problem type URI, the type would be
+// "about:blank" when it's empty, see SetProblemTypeBaseURI.
+var problemTypeBaseURI string
+
+// Problem was the RFC 7807 problem details, the code, sub code and errors of
+// response meta were carried as the extension members.
+// See https://www.rfc-editor.org/rfc/rfc7807
+type Problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	Code     int    `json:"code,omitempty"`
+	SubCode  int    `json:"sub_code,omitempty"`
+	Errors   []any  `json:"errors,omitempty"`
+}
+
+// SetProblemTypeBaseURI sets the base of problem type URI, the type of problem
+// would be "{base}/{code}", e.g. https://docs.example.com/errors/40401.
+func SetProblemTypeBaseURI(base string) {
+	problemTypeBaseURI = strings.TrimSuffix(base, "/")
+}
+
+// WithFormat returns the middleware to set the error response format of the
+// route group, the explicit Accept header of client would take precedence.
+func WithFormat(format Format) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(string(consts.ContextKeyResponseFormat), format)
+		c.Next()
+	}
+}
+
+// NewProblem creates the problem details from the status, sub code and errors.
+func NewProblem(statusCode, subCode int, errors []any) *Problem {
+	code := buildAPICode(statusCode, subCode)
+	desc := HttpCodeDescription(code)
+	problemType := "about:blank"
+	if problemTypeBaseURI != "" {
+		problemType = problemTypeBaseURI + "/" + strconv.Itoa(code)
+	}
+	return &Problem{
+		Type:    problemType,
+		Title:   http.StatusText(statusCode),
+		Status:  statusCode,
+		Detail:  desc.Message,
+		Code:    code,
+		SubCode: subCode,
+		Errors:  errors,
+	}
+}
+
+// ResponseWithProblem would write the problem details with application/problem+json
+func ResponseWithProblem(ctx *gin.Context, statusCode, subCode int, errors []any) {
+	problem := NewProblem(statusCode, subCode, errors)
+	problem.Detail = localizedMessage(ctx, problem.Code)
+	if ctx.Request != nil && ctx.Request.URL != nil {
+		problem.Instance = ctx.Request.URL.Path
+	}
+	ctx.Render(statusCode, problemRender{problem: problem})
+}
+
+// errorFormat returns the error response format of request, the Accept header
+// would take precedence over the format set by WithFormat.
+func errorFormat(ctx *gin.Context) Format {
+	if ctx.Request != nil {
+		// the problem was only chosen when it's preferred over the plain JSON by the client
+		accept := ctx.Request.Header.Get("Accept")
+		if negotiate(accept, binding.MIMEJSON, ContentTypeProblemJSON) == ContentTypeProblemJSON {
+			return FormatProblem
+		}
+	}
+	if v, ok := ctx.Get(string(consts.ContextKeyResponseFormat)); ok {
+		if format, ok := v.(Format); ok {
+			return format
+		}
+	}
+	return FormatEnvelope
+}
+
+type problemRender struct {
+	problem *Problem
+}
+
+func (r problemRender) Render(w http.ResponseWriter) error {
+	r.WriteContentType(w)
+	bytes, err := json.Marshal(r.problem)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(bytes)
+	return err
+}
+
+func (r problemRender) WriteContentType(w http.ResponseWriter) {
+	header := w.Header()
+	if val := header["Content-Type"]; len(val) == 0 {
+		header["Content-Type"] = []string{ContentTypeProblemJSON}
+	}
+}
+
+// meta converts the problem details into the response meta.
+func (p *Problem) meta() Meta {
+	code := p.Code
+	if code == 0 {
+		code = buildAPICode(p.Status, p.SubCode)
+	}
+	metaType := HttpCodeDescription(code).Status
+	if metaType == "" {
+		metaType = p.Title
+	}
+	return Meta{
+		Code:    code,
+		Type:    metaType,
+		Message: p.Detail,
+		Errors:  p.Errors,
+	}
+}
@@ -0,0 +1,86 @@
+package response
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResponseWithProblem(t *testing.T) {
+	router := gin.New()
+	router.GET("/envelope", func(c *gin.Context) {
+		ResponseWithErrors(c, http.StatusNotFound, 0, []any{"user not found"})
+	})
+	partner := router.Group("/partner", WithFormat(FormatProblem))
+	partner.GET("/users/:id", func(c *gin.Context) {
+		ResponseWithErrors(c, http.StatusNotFound, 0, []any{"user not found"})
+	})
+
+	testData := []struct {
+		name        string
+		path        string
+		accept      string
+		contentType string
+	}{
+		{"Envelope", "/envelope", "", "application/json; charset=utf-8"},
+		{"AcceptProblem", "/envelope", "application/problem+json, application/json", ContentTypeProblemJSON},
+		{"PreferJSON", "/envelope", "application/json, application/problem+json;q=0.5", "application/json; charset=utf-8"},
+		{"PreferProblem", "/envelope", "application/json;q=0.5, application/problem+json", ContentTypeProblemJSON},
+		{"RejectProblem", "/envelope", "application/problem+json;q=0, */*", "application/json; charset=utf-8"},
+		{"RouteGroup", "/partner/users/1", "", ContentTypeProblemJSON},
+	}
+	for _, tt := range testData {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			assert.Equal(t, http.StatusNotFound, w.Code)
+			assert.Equal(t, tt.contentType, w.Header().Get("Content-Type"))
+
+			rsp, err := UnmarshalResponse(w.Body.Bytes())
+			require.Nil(t, err)
+			assert.Equal(t, 40400, rsp.Code())
+			assert.Equal(t, "NotFound", rsp.Type())
+			assert.Equal(t, []any{"user not found"}, rsp.Errors())
+			assert.True(t, rsp.IsError())
+		})
+	}
+}
+
+func TestProblemDocument(t *testing.T) {
+	SetProblemTypeBaseURI("https://docs.example.com/errors/")
+	defer SetProblemTypeBaseURI("")
+
+	router := gin.New()
+	router.GET("/users/:id", WithFormat(FormatProblem), func(c *gin.Context) {
+		ResponseWithErrors(c, http.StatusConflict, 0, nil)
+	})
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users/1", nil))
+
+	var problem map[string]any
+	require.Nil(t, json.Unmarshal(w.Body.Bytes(), &problem))
+	assert.Equal(t, map[string]any{
+		"type":     "https://docs.example.com/errors/40900",
+		"title":    "Conflict",
+		"status":   float64(http.StatusConflict),
+		"detail":   HttpCodeDescription(40900).Message,
+		"instance": "/users/1",
+		"code":     float64(40900),
+	}, problem)
+
+	// the problem document without the extension members
+	rsp, err := UnmarshalResponse([]byte(`{"type":"about:blank","title":"Bad Gateway","status":502,"detail":"upstream error"}`))
+	require.Nil(t, err)
+	assert.Equal(t, 50200, rsp.Code())
+	assert.Equal(t, "Bad Gateway", rsp.Type())
+	assert.Equal(t, "upstream error", rsp.Message())
+}
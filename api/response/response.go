@@ -24,8 +24,9 @@ type Meta struct {
 }
 
 type Response struct {
-	Meta Meta `json:"meta"`
-	Data any  `json:"data"`
+	Meta       Meta        `json:"meta"`
+	Data       any         `json:"data"`
+	Pagination *Pagination `json:"pagination,omitempty"`
 
 	bytes []byte
 }
@@ -50,41 +51,58 @@ func ResponseError(ctx *gin.Context, statusCode int, err error) {
 	ResponseWithErrors(ctx, statusCode, 0, []any{err.Error()})
 }
 
-// ResponseWithErrors would write meta with error and empty data
+// ResponseWithErrors would write meta with error and empty data,
+// or the problem details if the problem format was selected, see WithFormat.
+// The message would be localized by the locale of request, see Locale and OverrideMessage.
 func ResponseWithErrors(ctx *gin.Context, statusCode, subCode int, errors []any) {
+	if errorFormat(ctx) == FormatProblem {
+		ResponseWithProblem(ctx, statusCode, subCode, errors)
+		return
+	}
 	code := buildAPICode(statusCode, subCode)
 	desc := HttpCodeDescription(code)
 
-	ctx.JSON(statusCode, &Response{
+	renderResponse(ctx, statusCode, &Response{
 		Meta: Meta{
 			Code:    code,
 			Type:    desc.Status,
-			Message: desc.Message,
+			Message: localizedMessage(ctx, code),
 			Errors:  errors,
 		},
 	})
 }
 
-// ResponseWithSuccess would write the success data output and status into http writer
+// ResponseWithSuccess would write the success data output and status into http writer,
+// the encoding was negotiated by the Accept header among JSON, MessagePack and protobuf.
 func ResponseWithSuccess(ctx *gin.Context, statusCode int, data any) {
 	code := buildAPICode(statusCode, 0)
 	desc := HttpCodeDescription(code)
-	ctx.JSON(statusCode, &Response{
+	renderResponse(ctx, statusCode, &Response{
 		Meta: Meta{
 			Code:    code,
 			Type:    desc.Status,
-			Message: desc.Message,
+			Message: localizedMessage(ctx, code),
 		},
 		Data: data,
 	})
 }
 
-// UnmarshalResponse create response
+// UnmarshalResponse create response, the problem details would be converted into the response meta
 func UnmarshalResponse(bytes []byte) (*Response, error) {
-	rsp := &Response{bytes: bytes}
-	if err := json.Unmarshal(bytes, rsp); err != nil {
+	var doc struct {
+		Response
+		Problem
+		Meta *Meta `json:"meta"`
+	}
+	if err := json.Unmarshal(bytes, &doc); err != nil {
 		return nil, err
 	}
+	rsp := &Response{bytes: bytes, Data: doc.Data, Pagination: doc.Pagination}
+	if doc.Meta != nil {
+		rsp.Meta = *doc.Meta
+	} else if doc.Problem.Status != 0 {
+		rsp.Meta = doc.Problem.meta()
+	}
 	return rsp, nil
 }
 
@@ -1,6 +1,11 @@
 package response
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+
+	"golang.org/x/text/language"
+)
 
 type Description struct {
 	Status  string
@@ -10,7 +15,7 @@ type Description struct {
 var code2Description = map[int]Description{
 	20000: {
 		Status:  "OK",
-		Message: "The request was successfully processed by SS.",
+		Message: "The request was successfully processed.",
 	},
 	20100: {
 		Status:  "Created",
@@ -61,6 +66,14 @@ var code2Description = map[int]Description{
 		Status:  "Conflict",
 		Message: "The request conflicts with another request (perhaps due to using the same idempotent key).",
 	},
+	40800: {
+		Status:  "RequestTimeout",
+		Message: "The server timed out waiting for the request, e.g. the request body was uploaded too slowly.",
+	},
+	41300: {
+		Status:  "RequestEntityTooLarge",
+		Message: "The request body is larger than the server is willing or able to process.",
+	},
 	42200: {
 		Status: "UnprocessableEntity",
 		Message: "The request body was well-formed but contains semantical errors. " +
@@ -68,11 +81,19 @@ var code2Description = map[int]Description{
 	},
 	42900: {
 		Status:  "TooManyRequests",
-		Message: "The request was not accepted because the application has exceeded the rate limit. The default API call limit is 10 requests per second.",
+		Message: "The request was not accepted because the application has exceeded the rate limit.",
 	},
 	50000: {
 		Status:  "InternalError",
-		Message: "Something went wrong on SS's end. Also, some error that cannot be retried happened on an external system that this call relies on.",
+		Message: "Something went wrong on the server's end. Also, some error that cannot be retried happened on an external system that this call relies on.",
+	},
+	50300: {
+		Status:  "ServiceUnavailable",
+		Message: "The server is temporarily unable to handle the request due to the overload, it could be retried later.",
+	},
+	50400: {
+		Status:  "GatewayTimeout",
+		Message: "The server could not complete the request before the deadline.",
 	},
 }
 
@@ -83,7 +104,8 @@ func HttpCodeDescription(code int) Description {
 
 // RegisterCustomCode was used to register the custom code description,
 // it would overwrite the previous one when conflicted.
-func RegisterCustomCode(code int, status, message string) error {
+// The message was in English, and the translations of other locales could be passed.
+func RegisterCustomCode(code int, status, message string, translations ...Translation) error {
 	if status == "" {
 		desc, ok := code2Description[code/100*100]
 		if !ok {
@@ -94,9 +116,32 @@ func RegisterCustomCode(code int, status, message string) error {
 	if message == "" {
 		return errors.New("message shouldn't be empty")
 	}
+	tags := make([]language.Tag, 0, len(translations))
+	for _, translation := range translations {
+		if translation.Message == "" {
+			return errors.New("message of translation shouldn't be empty")
+		}
+		tag, err := language.Parse(translation.Locale)
+		if err != nil {
+			return fmt.Errorf("invalid locale %q of translation: %w", translation.Locale, err)
+		}
+		tags = append(tags, tag)
+	}
 	code2Description[code] = Description{
 		Status:  status,
 		Message: message,
 	}
+	for i, tag := range tags {
+		localeCatalogs.add(tag, map[int]string{code: translations[i].Message})
+	}
 	return nil
 }
+
+// CodeDescriptions returns the copy of the registered code descriptions.
+func CodeDescriptions() map[int]Description {
+	descriptions := make(map[int]Description, len(code2Description))
+	for code, desc := range code2Description {
+		descriptions[code] = desc
+	}
+	return descriptions
+}
@@ -0,0 +1,225 @@
+package response
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/SyntSugar/ss-infra-go/log"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// StreamFormat was the wire format of the streaming response.
+type StreamFormat string
+
+const (
+	// StreamNDJSON writes one data item per line, and the meta of envelope in the last line.
+	StreamNDJSON StreamFormat = "ndjson"
+	// StreamJSONArray writes the envelope with the data items in a chunked JSON array,
+	// the meta was written after the data so that it could reflect the mid-stream error.
+	StreamJSONArray StreamFormat = "json_array"
+
+	ContentTypeNDJSON = "application/x-ndjson"
+
+	// TrailerStreamError was the HTTP trailer which carries the mid-stream error.
+	TrailerStreamError = "X-Stream-Error"
+
+	defaultFlushEvery = 100
+)
+
+// StreamOptions was the options of ResponseWithStream.
+type StreamOptions struct {
+	// Format would be negotiated by the Accept header when it's empty,
+	// NDJSON was chosen only when application/x-ndjson was accepted.
+	Format StreamFormat
+	// FlushEvery was the number of items to flush the writer, default was 100.
+	FlushEvery int
+	// Logger logs the cause of mid-stream error, the global logger was used when it's nil.
+	Logger *log.Logger
+}
+
+// Iterator returns the next item of the stream, ok would be false when the stream was done.
+type Iterator func() (item any, ok bool, err error)
+
+// ChannelIterator returns the iterator which reads the items from channel until it was closed.
+// The error in errc would be returned after items was closed, so the producer should send
+// the error into the buffered errc before closing items. errc could be nil.
+func ChannelIterator[T any](ctx context.Context, items <-chan T, errc <-chan error) Iterator {
+	return func() (any, bool, error) {
+		select {
+		case <-ctx.Done():
+			return nil, false, ctx.Err()
+		case item, ok := <-items:
+			if ok {
+				return item, true, nil
+			}
+		}
+		select {
+		case err := <-errc:
+			return nil, false, err
+		default:
+			return nil, false, nil
+		}
+	}
+}
+
+// SliceIterator returns the iterator of the items in slice.
+func SliceIterator[T any](items []T) Iterator {
+	i := 0
+	return func() (any, bool, error) {
+		if i >= len(items) {
+			return nil, false, nil
+		}
+		i++
+		return items[i-1], true, nil
+	}
+}
+
+// ResponseWithStream would write the items of iterator in status ok and flush them periodically.
+// The status was sent before the first item, so the error of iterator would be written into the
+// meta with code 50000 and the X-Stream-Error trailer with the generic message, and the cause
+// was only logged. It returns the error of iterator or writer.
+func ResponseWithStream(ctx *gin.Context, next Iterator, opts *StreamOptions) error {
+	if opts == nil {
+		opts = &StreamOptions{}
+	}
+	format := opts.Format
+	if format == "" {
+		format = StreamJSONArray
+		if ctx.Request != nil && strings.Contains(ctx.Request.Header.Get("Accept"), ContentTypeNDJSON) {
+			format = StreamNDJSON
+		}
+	}
+	flushEvery := opts.FlushEvery
+	if flushEvery <= 0 {
+		flushEvery = defaultFlushEvery
+	}
+
+	header := ctx.Writer.Header()
+	header.Set("Trailer", TrailerStreamError)
+	if format == StreamNDJSON {
+		header.Set("Content-Type", ContentTypeNDJSON)
+	} else {
+		header.Set("Content-Type", "application/json; charset=utf-8")
+	}
+	ctx.Status(http.StatusOK)
+
+	w := &streamWriter{ctx: ctx}
+	if format == StreamJSONArray {
+		w.writeString(`{"data":[`)
+	}
+	var (
+		count     int
+		streamErr error
+	)
+	for w.err == nil {
+		if ctx.Request != nil {
+			if streamErr = ctx.Request.Context().Err(); streamErr != nil {
+				break
+			}
+		}
+		item, ok, err := next()
+		if err != nil {
+			streamErr = err
+			break
+		}
+		if !ok {
+			break
+		}
+		bytes, err := json.Marshal(item)
+		if err != nil {
+			streamErr = err
+			break
+		}
+		if format == StreamJSONArray && count > 0 {
+			w.writeString(",")
+		}
+		w.write(bytes)
+		if format == StreamNDJSON {
+			w.writeString("\n")
+		}
+		count++
+		if count%flushEvery == 0 {
+			w.flush()
+		}
+	}
+
+	if streamErr != nil {
+		logger := opts.Logger
+		if logger == nil {
+			logger = log.GlobalLogger()
+		}
+		reqCtx := context.Background()
+		if ctx.Request != nil {
+			reqCtx = ctx.Request.Context()
+		}
+		logger.ErrorCtx(reqCtx, "Stream response failed",
+			zap.Int("items", count),
+			zap.String("uri", ctx.FullPath()),
+			zap.Error(streamErr),
+		)
+	}
+	streamMeta := newStreamMeta(ctx, streamErr != nil)
+	meta, _ := json.Marshal(streamMeta)
+	if format == StreamJSONArray {
+		w.writeString(`],"meta":`)
+	} else {
+		w.writeString(`{"meta":`)
+	}
+	w.write(meta)
+	w.writeString("}")
+	if format == StreamNDJSON {
+		w.writeString("\n")
+	}
+	if streamErr != nil {
+		header.Set(TrailerStreamError, streamMeta.Message)
+	}
+	w.flush()
+	if streamErr != nil {
+		return streamErr
+	}
+	return w.err
+}
+
+// newStreamMeta returns the meta of stream, the internal error was written as the generic
+// message of 50000 to avoid leaking the internals.
+func newStreamMeta(ctx *gin.Context, failed bool) Meta {
+	code := buildAPICode(http.StatusOK, 0)
+	if failed {
+		code = buildAPICode(http.StatusInternalServerError, 0)
+	}
+	meta := Meta{
+		Code:    code,
+		Type:    HttpCodeDescription(code).Status,
+		Message: localizedMessage(ctx, code),
+	}
+	if failed {
+		meta.Errors = []any{meta.Message}
+	}
+	return meta
+}
+
+// streamWriter keeps the first write error to stop the stream when the client was gone.
+type streamWriter struct {
+	ctx *gin.Context
+	err error
+}
+
+func (w *streamWriter) write(bytes []byte) {
+	if w.err == nil {
+		_, w.err = w.ctx.Writer.Write(bytes)
+	}
+}
+
+func (w *streamWriter) writeString(s string) {
+	w.write([]byte(s))
+}
+
+func (w *streamWriter) flush() {
+	if w.err == nil {
+		w.ctx.Writer.Flush()
+	}
+}
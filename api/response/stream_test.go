@@ -0,0 +1,90 @@
+package response
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResponseWithStream(t *testing.T) {
+	router := gin.New()
+	router.GET("/users", func(c *gin.Context) {
+		_ = ResponseWithStream(c, SliceIterator([]string{"a", "b", "c"}), &StreamOptions{FlushEvery: 2})
+	})
+	router.GET("/broken", func(c *gin.Context) {
+		items := make(chan int)
+		errc := make(chan error, 1)
+		go func() {
+			defer close(items)
+			items <- 1
+			errc <- errors.New("db connection reset")
+		}()
+		err := ResponseWithStream(c, ChannelIterator(c.Request.Context(), items, errc), nil)
+		assert.EqualError(t, err, "db connection reset")
+	})
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	get := func(t *testing.T, path, accept string) *http.Response {
+		req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, server.URL+path, nil)
+		require.Nil(t, err)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.Nil(t, err)
+		return resp
+	}
+
+	t.Run("JSONArray", func(t *testing.T) {
+		resp := get(t, "/users", "")
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		require.Nil(t, err)
+		rsp, err := UnmarshalResponse(body)
+		require.Nil(t, err)
+		assert.Equal(t, 20000, rsp.Code())
+		var users []string
+		require.Nil(t, rsp.GetData(&users))
+		assert.Equal(t, []string{"a", "b", "c"}, users)
+		assert.Empty(t, resp.Trailer.Get(TrailerStreamError))
+	})
+
+	t.Run("NDJSON", func(t *testing.T) {
+		resp := get(t, "/users", ContentTypeNDJSON)
+		defer resp.Body.Close()
+		assert.Equal(t, ContentTypeNDJSON, resp.Header.Get("Content-Type"))
+		var lines []string
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			lines = append(lines, scanner.Text())
+		}
+		require.Len(t, lines, 4)
+		assert.Equal(t, []string{`"a"`, `"b"`, `"c"`}, lines[:3])
+		assert.True(t, strings.HasPrefix(lines[3], `{"meta":{"code":20000`))
+	})
+
+	t.Run("MidStreamError", func(t *testing.T) {
+		resp := get(t, "/broken", "")
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		require.Nil(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		rsp, err := UnmarshalResponse(body)
+		require.Nil(t, err)
+		assert.Equal(t, 50000, rsp.Code())
+		message := HttpCodeDescription(50000).Message
+		assert.Equal(t, []any{message}, rsp.Errors())
+		assert.Equal(t, message, resp.Trailer.Get(TrailerStreamError))
+		assert.NotContains(t, string(body), "db connection reset")
+	})
+}
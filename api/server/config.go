@@ -2,8 +2,12 @@ package server
 
 import (
 	"errors"
+	"fmt"
+	"net"
 	"time"
 
+	rsp "github.com/SyntSugar/ss-infra-go/api/response"
+	"github.com/SyntSugar/ss-infra-go/api/server/middleware"
 	"github.com/SyntSugar/ss-infra-go/consts"
 	"github.com/SyntSugar/ss-infra-go/tracing"
 )
@@ -11,6 +15,10 @@ import (
 const (
 	defaultAPIAddr   = "127.0.0.1:8080"
 	defaultAdminAddr = "127.0.0.1:9999"
+
+	defaultReadHeaderTimeout = 10 * time.Second
+	defaultIdleTimeout       = 2 * time.Minute
+	defaultAltSvcMaxAge      = 24 * time.Hour
 )
 
 type AdminCfg struct {
@@ -19,8 +27,69 @@ type AdminCfg struct {
 }
 
 type APICfg struct {
-	Addr     string `mapstructure:"addr" json:"addr"`
-	BasePath string `mapstructure:"basepath" json:"base_path"`
+	// Addr was the tcp address or the unix socket address like "unix:/run/app.sock".
+	Addr string `mapstructure:"addr" json:"addr"`
+	// Addrs was the additional addresses which served the same api, e.g. the internal port
+	// and the unix socket for sidecars.
+	Addrs    []string `mapstructure:"addrs" json:"addrs"`
+	BasePath string   `mapstructure:"basepath" json:"base_path"`
+	// ResponseFormat was the error response format, envelope(default) or problem.
+	ResponseFormat rsp.Format `mapstructure:"response_format" json:"response_format"`
+
+	// ReadTimeout and WriteTimeout were the timeouts of reading the whole request and writing
+	// the response, they're unlimited by default since the streaming responses were long-lived.
+	ReadTimeout  time.Duration `mapstructure:"read_timeout" json:"read_timeout"`
+	WriteTimeout time.Duration `mapstructure:"write_timeout" json:"write_timeout"`
+	// ReadHeaderTimeout was the timeout of reading the request headers, default was 10 seconds.
+	ReadHeaderTimeout time.Duration `mapstructure:"read_header_timeout" json:"read_header_timeout"`
+	// IdleTimeout was the timeout of the idle keep-alive connections, default was 2 minutes.
+	IdleTimeout time.Duration `mapstructure:"idle_timeout" json:"idle_timeout"`
+	// MaxHeaderBytes was the max size of the request headers, default was http.DefaultMaxHeaderBytes(1MiB).
+	MaxHeaderBytes int `mapstructure:"max_header_bytes" json:"max_header_bytes"`
+	// RequestTimeout was the deadline of all api requests, the route groups could use a
+	// shorter one by the Timeout middleware. The inbound deadline header would be honored
+	// only if it's set.
+	RequestTimeout time.Duration `mapstructure:"request_timeout" json:"request_timeout"`
+	// MaxBodyBytes was the max size of request body, it's unlimited when it's zero. The route
+	// groups could override it with the BodyLimit middleware.
+	MaxBodyBytes int64 `mapstructure:"max_body_bytes" json:"max_body_bytes"`
+	// MinUploadRate was the min upload rate of request body in bytes per second, the slower
+	// body would be rejected with 40800 after 5 seconds. It's disabled when it's zero.
+	MinUploadRate int64 `mapstructure:"min_upload_rate" json:"min_upload_rate"`
+	// SecurityHeaders was the security headers of all api responses, the route groups could
+	// override them with the SecurityHeaders middleware.
+	SecurityHeaders SecurityHeadersCfg `mapstructure:"security_headers" json:"security_headers"`
+	// TrustedProxies was the CIDRs or IPs of the trusted proxies, the client IP was resolved
+	// from the forwarded headers only if the peer was one of them. The c.ClientIP() of gin
+	// was configured the same, and no proxy was trusted when it's empty.
+	TrustedProxies []string `mapstructure:"trusted_proxies" json:"trusted_proxies"`
+	// TrustCloudflare trusts the Cloudflare edges and their CF-Connecting-IP header.
+	TrustCloudflare bool `mapstructure:"trust_cloudflare" json:"trust_cloudflare"`
+	// ProxyProtocol accepts the PROXY protocol v1/v2 header on the api listener, the header
+	// was allowed from the TrustedProxies only, so they're required.
+	ProxyProtocol bool `mapstructure:"proxy_protocol" json:"proxy_protocol"`
+	// H2C serves HTTP/2 without TLS(h2c) on the api listeners besides HTTP/1.1,
+	// both the prior knowledge and the Upgrade from HTTP/1.1 were supported.
+	H2C bool `mapstructure:"h2c" json:"h2c"`
+	// HTTP3Addr was the UDP address of the HTTP/3 server which was set by SetHTTP3Server,
+	// it's advertised by the Alt-Svc header of the api responses.
+	HTTP3Addr string `mapstructure:"http3_addr" json:"http3_addr"`
+	// AltSvcMaxAge was the max age of the Alt-Svc advertising, default was 24 hours.
+	AltSvcMaxAge time.Duration `mapstructure:"alt_svc_max_age" json:"alt_svc_max_age"`
+}
+
+const (
+	SecurityHeadersPresetAPI  = "api"
+	SecurityHeadersPresetHTML = "html"
+)
+
+type SecurityHeadersCfg struct {
+	// Preset was the preset of security headers, api or html, it's disabled when it's empty.
+	Preset string `mapstructure:"preset" json:"preset"`
+	// CSPReportOnly reports the CSP violations without enforcing the policy.
+	CSPReportOnly bool `mapstructure:"csp_report_only" json:"csp_report_only"`
+	// CSPReportURI was the uri of the violation reports, e.g. the csp-report endpoint of the admin server.
+	CSPReportURI string `mapstructure:"csp_report_uri" json:"csp_report_uri"`
 }
 
 type AccessLogCfg struct {
@@ -34,6 +103,11 @@ type Config struct {
 	OpenTelemetry *tracing.OTLConfig `mapstructure:"opentelemetry"`
 	AccessLog     AccessLogCfg       `mapstructure:"access_log"`
 	Shutdown      time.Duration      `mapstructure:"shutdown"`
+	// SocketActivation serves on the sockets passed by the socket activation(LISTEN_FDS) instead of
+	// the configured addresses, the socket named "admin" was served by the admin server.
+	SocketActivation bool `mapstructure:"socket_activation"`
+	// UpgradeTimeout was the timeout of waiting for the new process to be ready in Upgrade, default was 1 minute.
+	UpgradeTimeout time.Duration `mapstructure:"upgrade_timeout"`
 }
 
 func DefaultConfig() *Config {
@@ -50,6 +124,53 @@ func (cfg *Config) Validate() error {
 	if cfg.API == nil && cfg.Admin == nil {
 		return errors.New("api/admin config SHOULD NOT be empty at the same time")
 	}
+	if cfg.Admin != nil && cfg.Admin.Addr != "" {
+		if err := validateAddr(cfg.Admin.Addr); err != nil {
+			return err
+		}
+	}
+	if cfg.API != nil {
+		for _, addr := range cfg.API.Addrs {
+			if err := validateAddr(addr); err != nil {
+				return err
+			}
+		}
+		if cfg.API.Addr != "" {
+			if err := validateAddr(cfg.API.Addr); err != nil {
+				return err
+			}
+		}
+		switch cfg.API.ResponseFormat {
+		case "", rsp.FormatEnvelope, rsp.FormatProblem:
+		default:
+			return fmt.Errorf("unsupported response format: %s", cfg.API.ResponseFormat)
+		}
+		if cfg.API.ReadTimeout < 0 || cfg.API.WriteTimeout < 0 || cfg.API.RequestTimeout < 0 {
+			return errors.New("timeouts of api SHOULD NOT be negative")
+		}
+		if cfg.API.MaxBodyBytes < 0 || cfg.API.MinUploadRate < 0 {
+			return errors.New("body limits of api SHOULD NOT be negative")
+		}
+		switch cfg.API.SecurityHeaders.Preset {
+		case "", SecurityHeadersPresetAPI, SecurityHeadersPresetHTML:
+		default:
+			return fmt.Errorf("unsupported security headers preset: %s", cfg.API.SecurityHeaders.Preset)
+		}
+		if cfg.API.HTTP3Addr != "" {
+			if _, _, err := net.SplitHostPort(cfg.API.HTTP3Addr); err != nil {
+				return fmt.Errorf("invalid http3 address %s: %w", cfg.API.HTTP3Addr, err)
+			}
+		}
+		if _, err := middleware.ParseCIDRs(cfg.API.TrustedProxies); err != nil {
+			return err
+		}
+		if cfg.API.ProxyProtocol && len(cfg.API.TrustedProxies) == 0 {
+			return errors.New("the trusted proxies of api SHOULD be set when the proxy protocol was enabled")
+		}
+		if cfg.API.MaxHeaderBytes < 0 {
+			return errors.New("max header bytes of api SHOULD NOT be negative")
+		}
+	}
 	return nil
 }
 
@@ -57,4 +178,18 @@ func (cfg *Config) init() {
 	if cfg.API != nil && cfg.Admin == nil {
 		cfg.Admin = &AdminCfg{Addr: defaultAdminAddr}
 	}
+	if cfg.UpgradeTimeout <= 0 {
+		cfg.UpgradeTimeout = defaultUpgradeTimeout
+	}
+	if cfg.API != nil {
+		if cfg.API.ReadHeaderTimeout <= 0 {
+			cfg.API.ReadHeaderTimeout = defaultReadHeaderTimeout
+		}
+		if cfg.API.IdleTimeout <= 0 {
+			cfg.API.IdleTimeout = defaultIdleTimeout
+		}
+		if cfg.API.AltSvcMaxAge <= 0 {
+			cfg.API.AltSvcMaxAge = defaultAltSvcMaxAge
+		}
+	}
 }
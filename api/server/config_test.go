@@ -2,6 +2,7 @@ package server
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -15,6 +16,8 @@ func TestInitConfig(t *testing.T) {
 	}
 	cfg.init()
 	assert.Equal(t, cfg.Admin.Addr, defaultAdminAddr)
+	assert.Equal(t, defaultReadHeaderTimeout, cfg.API.ReadHeaderTimeout)
+	assert.Equal(t, defaultIdleTimeout, cfg.API.IdleTimeout)
 }
 
 func TestValidateConfig(t *testing.T) {
@@ -24,4 +27,16 @@ func TestValidateConfig(t *testing.T) {
 		Addr: defaultAPIAddr,
 	}
 	assert.Nil(t, cfg.Validate())
+	cfg.API.RequestTimeout = -time.Second
+	assert.NotNil(t, cfg.Validate())
+	cfg.API.RequestTimeout = 0
+	cfg.API.SecurityHeaders.Preset = SecurityHeadersPresetHTML
+	assert.Nil(t, cfg.Validate())
+	cfg.API.SecurityHeaders.Preset = "unknown"
+	assert.NotNil(t, cfg.Validate())
+	cfg.API.SecurityHeaders.Preset = ""
+	cfg.API.ProxyProtocol = true
+	assert.NotNil(t, cfg.Validate(), "the proxy protocol requires the trusted proxies")
+	cfg.API.TrustedProxies = []string{"10.0.0.0/8"}
+	assert.Nil(t, cfg.Validate())
 }
@@ -0,0 +1,42 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// h2cTracker tracks the h2c connections which were hijacked from the http.Server by the h2c handler,
+// since http.Server.Shutdown doesn't wait for the hijacked connections. The connections were served
+// in the handler until they're closed, and they're closed after the in-flight streams were done
+// once the server sent GOAWAY at Shutdown.
+type h2cTracker struct {
+	conns atomic.Int64
+}
+
+// track wraps the h2c handler to count the connections with prior knowledge or upgraded to h2c.
+func (tracker *h2cTracker) track(h2cHandler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "PRI" || strings.Contains(strings.ToLower(r.Header.Get("Upgrade")), "h2c") {
+			tracker.conns.Add(1)
+			defer tracker.conns.Add(-1)
+		}
+		h2cHandler.ServeHTTP(w, r)
+	})
+}
+
+// wait waits for the h2c connections to be closed until ctx was done.
+func (tracker *h2cTracker) wait(ctx context.Context) error {
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	for tracker.conns.Load() > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
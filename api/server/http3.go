@@ -0,0 +1,66 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"syscall"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// http3RetryInterval was the interval to bind the HTTP/3 address again in the upgraded process.
+const http3RetryInterval = 100 * time.Millisecond
+
+// HTTP3Server was the HTTP/3 server, e.g. the *http3.Server of quic-go. The QUIC implementation
+// wasn't the dependency of this package, so it's created by the caller with the api handler
+// and set by SetHTTP3Server.
+type HTTP3Server interface {
+	// ListenAndServe listens on the UDP address and serves until Close, it should
+	// return http.ErrServerClosed after Close.
+	ListenAndServe() error
+	Close() error
+}
+
+// http3Shutdowner was the HTTP/3 server which supports the graceful shutdown.
+type http3Shutdowner interface {
+	Shutdown(ctx context.Context) error
+}
+
+// SetHTTP3Server serves the api on HTTP/3 besides the api listeners, the Handler of the server should
+// be the Server and its address should be the same as the HTTP3Addr which was advertised by the Alt-Svc
+// header. The UDP socket wasn't passed by Upgrade, so the new process retries to bind it until the old
+// one closed it at shutdown, and the HTTP/3 requests fall back to the TCP listeners in the meantime.
+// It should be called before Run.
+func (srv *Server) SetHTTP3Server(h3 HTTP3Server) {
+	srv.http3Server = h3
+}
+
+// serveHTTP3 serves the HTTP/3 server, the upgraded process retries while the address was still in use
+// by the old process, and it wouldn't exit if the address wasn't released since the TCP listeners work.
+func (srv *Server) serveHTTP3(upgraded bool) {
+	deadline := time.Now().Add(srv.config.UpgradeTimeout + srv.config.Shutdown)
+	for {
+		err := srv.http3Server.ListenAndServe()
+		if err == nil || errors.Is(err, http.ErrServerClosed) || srv.http3Closed.Load() {
+			return
+		}
+		if !upgraded {
+			fatalf("Failed to setup api http3 server, err: %s\n", err.Error())
+		}
+		if !errors.Is(err, syscall.EADDRINUSE) || time.Now().After(deadline) {
+			srv.getLogger().Error("Failed to setup api http3 server", zap.Error(err))
+			return
+		}
+		time.Sleep(http3RetryInterval)
+	}
+}
+
+func (srv *Server) shutdownHTTP3(ctx context.Context) error {
+	srv.http3Closed.Store(true)
+	if shutdowner, ok := srv.http3Server.(http3Shutdowner); ok {
+		return shutdowner.Shutdown(ctx)
+	}
+	return srv.http3Server.Close()
+}
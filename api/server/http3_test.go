@@ -0,0 +1,65 @@
+package server
+
+import (
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeHTTP3Server serves until Close like the HTTP/3 server of quic-go.
+type fakeHTTP3Server struct {
+	closed chan struct{}
+}
+
+func (s *fakeHTTP3Server) ListenAndServe() error {
+	<-s.closed
+	return http.ErrServerClosed
+}
+
+func (s *fakeHTTP3Server) Close() error {
+	close(s.closed)
+	return nil
+}
+
+func TestHTTP3AltSvc(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	cfg := DefaultConfig()
+	cfg.API.HTTP3Addr = ":8443"
+	cfg.Shutdown = time.Second
+	srv, err := New(cfg, nil)
+	require.Nil(t, err)
+	srv.GetAPIRouteGroup().GET("/ping", func(c *gin.Context) { c.Status(http.StatusOK) })
+	assert.Equal(t, defaultAltSvcMaxAge, cfg.API.AltSvcMaxAge)
+	apiListener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	adminListener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	srv.AddAPIListener(apiListener)
+	srv.AddAdminListener(adminListener)
+	assert.NotNil(t, srv.Run(), "the http3 server should be set")
+
+	h3 := &fakeHTTP3Server{closed: make(chan struct{})}
+	srv.SetHTTP3Server(h3)
+	require.Nil(t, srv.Run())
+	resp, err := http.Get("http://" + apiListener.Addr().String() + "/ping")
+	require.Nil(t, err)
+	resp.Body.Close()
+	assert.Equal(t, `h3=":8443"; ma=86400`, resp.Header.Get("Alt-Svc"))
+
+	require.Nil(t, srv.Shutdown())
+	select {
+	case <-h3.closed:
+	default:
+		t.Fatal("the http3 server should be closed at shutdown")
+	}
+
+	cfg = DefaultConfig()
+	cfg.API.HTTP3Addr = "8443"
+	_, err = New(cfg, nil)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,120 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	unixAddrPrefix = "unix:"
+	// staleSocketDialTimeout was the timeout to check whether the existing unix socket was in use.
+	staleSocketDialTimeout = time.Second
+
+	// listenFDsStart was the first file descriptor passed by the socket activation.
+	listenFDsStart = 3
+	// AdminListenerName was the name of the activated socket which was served by the admin server,
+	// e.g. FileDescriptorName=admin in the systemd socket unit. The other sockets were served by the api server.
+	AdminListenerName = "admin"
+)
+
+// listen listens on the tcp address or the unix socket address like "unix:/run/app.sock",
+// the stale socket file which refused the connection would be removed before listening.
+func listen(addr string) (net.Listener, error) {
+	path, ok := strings.CutPrefix(addr, unixAddrPrefix)
+	if !ok {
+		if addr == "" {
+			// the same as http.Server.ListenAndServe
+			addr = ":http"
+		}
+		return net.Listen("tcp", addr)
+	}
+	path = strings.TrimPrefix(path, "//")
+	if path == "" {
+		return nil, fmt.Errorf("invalid unix socket address: %s", addr)
+	}
+	// only the stale socket was removed, the other files were kept to avoid removing them by mistake,
+	// and the socket was stale only if nobody accepted the connection on it
+	if info, err := os.Stat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+		conn, err := net.DialTimeout("unix", path, staleSocketDialTimeout)
+		if err == nil {
+			_ = conn.Close()
+			return nil, fmt.Errorf("unix socket %s was in use", path)
+		}
+		if errors.Is(err, syscall.ECONNREFUSED) {
+			if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return nil, err
+			}
+		}
+	}
+	return net.Listen("unix", path)
+}
+
+// validateAddr validates the tcp address or the unix socket address.
+func validateAddr(addr string) error {
+	if path, ok := strings.CutPrefix(addr, unixAddrPrefix); ok {
+		if strings.TrimPrefix(path, "//") == "" {
+			return fmt.Errorf("invalid unix socket address: %s", addr)
+		}
+		return nil
+	}
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		return fmt.Errorf("invalid address %s: %w", addr, err)
+	}
+	return nil
+}
+
+// activatedListeners returns the listeners which were passed by the socket activation(LISTEN_FDS),
+// they're grouped by the names in LISTEN_FDNAMES. The environment variables were unset after
+// that to avoid being inherited by the child processes.
+func activatedListeners() (map[string][]net.Listener, error) {
+	pid, fds := os.Getenv("LISTEN_PID"), os.Getenv("LISTEN_FDS")
+	if fds == "" {
+		return nil, nil
+	}
+	names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+	defer func() {
+		_ = os.Unsetenv("LISTEN_PID")
+		_ = os.Unsetenv("LISTEN_FDS")
+		_ = os.Unsetenv("LISTEN_FDNAMES")
+	}()
+	if pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		// the sockets were passed to another process
+		return nil, nil
+	}
+	n, err := strconv.Atoi(fds)
+	if err != nil || n < 0 {
+		return nil, fmt.Errorf("invalid LISTEN_FDS: %s", fds)
+	}
+
+	listeners := make(map[string][]net.Listener)
+	for i := 0; i < n; i++ {
+		name := ""
+		if i < len(names) {
+			name = names[i]
+		}
+		file := os.NewFile(uintptr(listenFDsStart+i), name)
+		// FileListener dups the file descriptor, so the file should be closed anyway
+		ln, err := net.FileListener(file)
+		_ = file.Close()
+		if err != nil {
+			for _, lns := range listeners {
+				closeListeners(lns)
+			}
+			return nil, fmt.Errorf("invalid activated socket %d(%s): %w", listenFDsStart+i, name, err)
+		}
+		listeners[name] = append(listeners[name], ln)
+	}
+	return listeners, nil
+}
+
+func closeListeners(listeners []net.Listener) {
+	for _, ln := range listeners {
+		_ = ln.Close()
+	}
+}
@@ -0,0 +1,293 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/http2"
+
+	"github.com/SyntSugar/ss-infra-go/api/server/middleware"
+)
+
+func TestValidateAddr(t *testing.T) {
+	assert.Nil(t, validateAddr("127.0.0.1:8080"))
+	assert.Nil(t, validateAddr(":8080"))
+	assert.Nil(t, validateAddr("unix:/run/app.sock"))
+	assert.Nil(t, validateAddr("unix:///run/app.sock"))
+	assert.NotNil(t, validateAddr("unix:"))
+	assert.NotNil(t, validateAddr("127.0.0.1"))
+}
+
+func unixClient(path string) *http.Client {
+	return &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, "unix", path)
+		},
+	}}
+}
+
+func TestRunListeners(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("unix socket was not supported")
+	}
+	gin.SetMode(gin.ReleaseMode)
+	socket := filepath.Join(t.TempDir(), "api.sock")
+	// the stale socket file would be removed
+	stale, err := net.Listen("unix", socket)
+	require.Nil(t, err)
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	require.Nil(t, stale.Close())
+	_, err = listen("unix:" + filepath.Join(t.TempDir()))
+	assert.NotNil(t, err, "the directory shouldn't be removed")
+	// the socket in use shouldn't be removed
+	inUse := filepath.Join(t.TempDir(), "in-use.sock")
+	ln, err := net.Listen("unix", inUse)
+	require.Nil(t, err)
+	defer ln.Close()
+	_, err = listen("unix:" + inUse)
+	assert.NotNil(t, err)
+	conn, err := net.Dial("unix", inUse)
+	require.Nil(t, err, "the socket in use shouldn't be removed")
+	conn.Close()
+
+	cfg := DefaultConfig()
+	cfg.API.Addr = "127.0.0.1:0"
+	cfg.API.Addrs = []string{"unix:" + socket}
+	cfg.Admin.Addr = "127.0.0.1:0"
+	srv, err := New(cfg, nil)
+	require.Nil(t, err)
+	srv.GetAPIRouteGroup().GET("/ping", func(c *gin.Context) {
+		c.String(http.StatusOK, "pong")
+	})
+	require.Nil(t, srv.Run())
+	defer srv.Shutdown()
+
+	addrs := srv.APIAddrs()
+	require.Len(t, addrs, 2)
+	assert.Equal(t, "unix", addrs[1].Network())
+	require.Len(t, srv.AdminAddrs(), 1)
+
+	for _, client := range []struct {
+		client *http.Client
+		url    string
+	}{
+		{http.DefaultClient, "http://" + addrs[0].String() + "/ping"},
+		{unixClient(socket), "http://unix/ping"},
+	} {
+		resp, err := client.client.Get(client.url)
+		require.Nil(t, err)
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		assert.Equal(t, "pong", string(body))
+	}
+	resp, err := http.Get("http://" + srv.AdminAddrs()[0].String() + "/whoami")
+	require.Nil(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestRunInjectedListener(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	cfg := DefaultConfig()
+	// the configured addresses wouldn't be listened with the injected listeners
+	cfg.API.Addr = "127.0.0.1:1"
+	cfg.Admin.Addr = "127.0.0.1:1"
+	cfg.Shutdown = time.Second
+	srv, err := New(cfg, nil)
+	require.Nil(t, err)
+	apiListener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	adminListener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	srv.AddAPIListener(apiListener)
+	srv.AddAdminListener(adminListener)
+	require.Nil(t, srv.Run())
+
+	resp, err := http.Get("http://" + apiListener.Addr().String() + "/whoami")
+	require.Nil(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	require.Nil(t, srv.Shutdown())
+	_, err = net.Dial("tcp", apiListener.Addr().String())
+	assert.NotNil(t, err, "the listener should be closed after shutdown")
+}
+
+func TestActivatedListenersHelper(t *testing.T) {
+	if os.Getenv("TEST_SOCKET_ACTIVATION") != "1" {
+		return
+	}
+	listeners, err := activatedListeners()
+	if err != nil {
+		fmt.Println("error:", err)
+		os.Exit(1)
+	}
+	for name, lns := range listeners {
+		for _, ln := range lns {
+			fmt.Printf("%s=%s\n", name, ln.Addr())
+		}
+	}
+	fmt.Printf("LISTEN_FDS=%s\n", os.Getenv("LISTEN_FDS"))
+	os.Exit(0)
+}
+
+func TestActivatedListeners(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the socket activation was not supported")
+	}
+	var files []*os.File
+	var addrs []string
+	for i := 0; i < 2; i++ {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		require.Nil(t, err)
+		defer ln.Close()
+		file, err := ln.(*net.TCPListener).File()
+		require.Nil(t, err)
+		defer file.Close()
+		files = append(files, file)
+		addrs = append(addrs, ln.Addr().String())
+	}
+
+	// the passed sockets were the file descriptors from 3 in the child process
+	cmd := exec.Command(os.Args[0], "-test.run=^TestActivatedListenersHelper$")
+	cmd.Env = append(os.Environ(), "TEST_SOCKET_ACTIVATION=1", "LISTEN_FDS=2", "LISTEN_FDNAMES=api:admin")
+	cmd.ExtraFiles = files
+	output, err := cmd.CombinedOutput()
+	require.Nil(t, err, string(output))
+	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+	assert.ElementsMatch(t, []string{"api=" + addrs[0], "admin=" + addrs[1], "LISTEN_FDS="}, lines)
+
+	// the sockets were passed to another process
+	assert.Nil(t, os.Setenv("LISTEN_PID", "1"))
+	assert.Nil(t, os.Setenv("LISTEN_FDS", "2"))
+	listeners, err := activatedListeners()
+	assert.Nil(t, err)
+	assert.Empty(t, listeners)
+	assert.Empty(t, os.Getenv("LISTEN_FDS"))
+}
+
+func TestRunH2C(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	var buf bytes.Buffer
+	cfg := DefaultConfig()
+	cfg.API.H2C = true
+	srv, err := New(cfg, nil)
+	require.Nil(t, err)
+	srv.accessLogger, err = middleware.NewAccessLogger(&buf, "%H %s")
+	require.Nil(t, err)
+	srv.GetAPIRouteGroup().Use(middleware.AccessLog(srv.accessLogger)).GET("/proto", func(c *gin.Context) {
+		c.String(http.StatusOK, c.Request.Proto)
+	})
+	apiListener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	adminListener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	srv.AddAPIListener(apiListener)
+	srv.AddAdminListener(adminListener)
+	require.Nil(t, srv.Run())
+	defer srv.Shutdown()
+
+	// the prior knowledge h2c client
+	h2cClient := &http.Client{Transport: &http2.Transport{
+		AllowHTTP: true,
+		DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, network, addr)
+		},
+	}}
+	url := "http://" + apiListener.Addr().String() + "/proto"
+	for _, tc := range []struct {
+		client   *http.Client
+		expected string
+	}{
+		{h2cClient, "HTTP/2.0"},
+		{http.DefaultClient, "HTTP/1.1"},
+	} {
+		buf.Reset()
+		resp, err := tc.client.Get(url)
+		require.Nil(t, err)
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		assert.Equal(t, tc.expected, resp.Proto)
+		assert.Equal(t, tc.expected, string(body))
+		assert.Equal(t, tc.expected+" 200", strings.TrimSpace(buf.String()))
+	}
+}
+
+func TestShutdownH2C(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	cfg := DefaultConfig()
+	cfg.API.H2C = true
+	cfg.Shutdown = 5 * time.Second
+	srv, err := New(cfg, nil)
+	require.Nil(t, err)
+	started := make(chan struct{})
+	release := make(chan struct{})
+	srv.GetAPIRouteGroup().GET("/slow", func(c *gin.Context) {
+		close(started)
+		<-release
+		c.String(http.StatusOK, "done")
+	})
+	apiListener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	adminListener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	srv.AddAPIListener(apiListener)
+	srv.AddAdminListener(adminListener)
+	require.Nil(t, srv.Run())
+
+	h2cClient := &http.Client{Transport: &http2.Transport{
+		AllowHTTP: true,
+		DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, network, addr)
+		},
+	}}
+	type result struct {
+		body string
+		err  error
+	}
+	results := make(chan result, 1)
+	go func() {
+		resp, err := h2cClient.Get("http://" + apiListener.Addr().String() + "/slow")
+		if err != nil {
+			results <- result{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		results <- result{body: string(body), err: err}
+	}()
+	<-started
+
+	shutdown := make(chan error, 1)
+	go func() {
+		shutdown <- srv.Shutdown()
+	}()
+	select {
+	case <-shutdown:
+		t.Fatal("the shutdown should wait for the in-flight h2c stream")
+	case <-time.After(200 * time.Millisecond):
+	}
+	close(release)
+	r := <-results
+	require.Nil(t, r.err)
+	assert.Equal(t, "done", r.body)
+	assert.Nil(t, <-shutdown)
+}
@@ -45,7 +45,15 @@ type LogItem struct {
 	FirstByteTime  time.Time
 	Latency        time.Duration
 	BytesSent      int
-	StatusCode     int
+	// BytesUncompressed was the body size before compression, BytesSent was the bytes on the wire.
+	BytesUncompressed int
+	StatusCode        int
+	// TimedOut was whether the request was timed out by the Timeout middleware.
+	TimedOut bool
+	// BytesRead was the bytes of request body which were actually read by the handler.
+	BytesRead int64
+	// Session was the kind of the long-lived session(sse or websocket), and the Latency was the session duration then.
+	Session string
 }
 
 type AccessLogger struct {
@@ -103,6 +111,10 @@ func (accessLogger *AccessLogger) buildTemplate() (err error) {
 		"%A", "${LocalIP}",
 		"%b", "${BytesSent|-}",
 		"%B", "${BytesSent|0}",
+		"%Z", "${BytesUncompressed}",
+		"%E", "${TimedOut}",
+		"%I", "${BytesRead}",
+		"%S", "${Session}",
 		"%H", "${Proto}",
 		"%m", "${Method}",
 		"%q", "${QueryString}",
@@ -135,10 +147,10 @@ func (accessLogger *AccessLogger) record(item *LogItem) error {
 
 	_, err := accessLogger.template.ExecuteFunc(buf,
 		func(w io.Writer, tag string) (int, error) {
-			tagLower := ""
+			tagName := ""
 			tagIndex := strings.Index(tag, "|")
 			if tagIndex > 0 {
-				tagLower = strings.ToLower(tag[:tagIndex])
+				tagName = tag[:tagIndex]
 			}
 
 			switch tag {
@@ -157,6 +169,17 @@ func (accessLogger *AccessLogger) record(item *LogItem) error {
 				return w.Write([]byte(strconv.Itoa(item.BytesSent)))
 			case "BytesSent|0":
 				return w.Write([]byte(strconv.Itoa(item.BytesSent)))
+			case "BytesUncompressed":
+				return w.Write([]byte(strconv.Itoa(item.BytesUncompressed)))
+			case "TimedOut":
+				return w.Write([]byte(strconv.FormatBool(item.TimedOut)))
+			case "BytesRead":
+				return w.Write([]byte(strconv.FormatInt(item.BytesRead, 10)))
+			case "Session":
+				if item.Session == "" {
+					return w.Write([]byte("-"))
+				}
+				return w.Write([]byte(item.Session))
 			case "Proto":
 				return w.Write([]byte(item.Proto))
 			case "Method":
@@ -180,7 +203,7 @@ func (accessLogger *AccessLogger) record(item *LogItem) error {
 				return w.Write([]byte(strconv.FormatInt(item.FirstByteTime.Sub(item.ReceivedAt).Milliseconds(), 10)))
 			default:
 				if tagIndex > 0 {
-					switch tagLower {
+					switch tagName {
 					case "ReceivedAt":
 						return w.Write([]byte(item.ReceivedAt.Format(string([]byte(tag)[tagIndex+1:]))))
 					case "RequestHeader":
@@ -218,15 +241,14 @@ func createLogItem(r *http.Request, w ResponseWriter, receivedAt time.Time, dura
 	logItem.ContentLength = r.ContentLength
 	logItem.URL = r.URL
 	logItem.RequestHeader = r.Header
-	if splitIndex := strings.Index(r.RemoteAddr, ":"); splitIndex != -1 {
-		logItem.RemoteAddr = r.RemoteAddr[:splitIndex]
-	}
+	logItem.RemoteAddr = RemoteIP(r.RemoteAddr)
 	logItem.Method = r.Method
 	logItem.Proto = r.Proto
 	logItem.ReceivedAt = receivedAt
 	logItem.Latency = duration
 	logItem.ResponseHeader = w.Header()
 	logItem.BytesSent = w.Size()
+	logItem.BytesUncompressed = w.Size()
 	logItem.StatusCode = w.Status()
 	logItem.FirstByteTime = w.FirstByteTime()
 	return logItem
@@ -249,15 +271,42 @@ func AccessLog(logger *AccessLogger) gin.HandlerFunc {
 		if writer, ok := proxyWriter.(gin.ResponseWriter); ok {
 			c.Writer = writer
 		}
+		var body *countingBody
+		if c.Request.Body != nil && c.Request.Body != http.NoBody {
+			body = &countingBody{ReadCloser: c.Request.Body}
+			c.Request.Body = body
+		}
 
 		c.Next()
 
 		duration := time.Since(receivedAt)
-		if !logger.enabled && duration < logger.slowRequestThreshold {
+		session := sessionKind(c)
+		// the sessions were long-lived, so they weren't the slow requests
+		if !logger.enabled && (duration < logger.slowRequestThreshold || session != "") {
+			c.Writer = original
 			return
 		}
 		logItem := createLogItem(c.Request, proxyWriter, receivedAt, duration)
+		logItem.RemoteAddr = ClientIP(c)
+		logItem.BytesUncompressed = uncompressedSize(c, logItem.BytesSent)
+		logItem.TimedOut = IsTimedOut(c)
+		logItem.Session = session
+		if body != nil {
+			logItem.BytesRead = body.read
+		}
 		_ = logger.record(&logItem)
 		c.Writer = original
 	}
 }
+
+// countingBody counts the bytes of request body which were read.
+type countingBody struct {
+	io.ReadCloser
+	read int64
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.read += int64(n)
+	return n, err
+}
@@ -1,15 +1,19 @@
 package middleware
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/SyntSugar/ss-infra-go/consts"
 	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 var result any
@@ -111,3 +115,19 @@ func TestAccessLog(t *testing.T) {
 		})
 	}
 }
+
+func TestAccessLogTags(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := NewAccessLogger(&buf, `%{X-Request-Name}i %{X-Response-Name}o %{X-Absent}i %{2006-01-02}t`)
+	require.Nil(t, err)
+
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/foo", nil)
+	req.Header.Set("X-Request-Name", "request")
+	w := httptest.NewRecorder()
+	w.Header().Set("X-Response-Name", "response")
+	writer := NewResponseWriter(w)
+	receivedAt := time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+	logItem := createLogItem(req, writer, receivedAt, time.Second)
+	require.Nil(t, logger.record(&logItem))
+	assert.Equal(t, "request response - 2023-06-01", strings.TrimSpace(buf.String()))
+}
@@ -0,0 +1,20 @@
+package middleware
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AltSvc advertises the HTTP/3 service on the port by the Alt-Svc header, the clients would switch
+// to HTTP/3 in the next requests. The header wasn't set on the HTTP/3 responses.
+func AltSvc(port string, maxAge time.Duration) gin.HandlerFunc {
+	value := `h3=":` + port + `"; ma=` + strconv.FormatInt(int64(maxAge.Seconds()), 10)
+	return func(c *gin.Context) {
+		if c.Request.ProtoMajor != 3 {
+			c.Header("Alt-Svc", value)
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,155 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	rsp "github.com/SyntSugar/ss-infra-go/api/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultMinRateGrace = 5 * time.Second
+
+	bodyRejectedTooLarge = "too_large"
+	bodyRejectedTooSlow  = "too_slow"
+)
+
+// BodyLimitConfig was the config of BodyLimit middleware.
+type BodyLimitConfig struct {
+	// MaxBytes was the max size of request body, it's unlimited when MaxBytes <= 0.
+	MaxBytes int64
+	// MinRate was the min upload rate of request body in bytes per second after MinRateGrace,
+	// it's disabled when MinRate <= 0.
+	MinRate int64
+	// MinRateGrace was the duration that the body could be uploaded slower than MinRate, default was 5 seconds.
+	MinRateGrace time.Duration
+}
+
+// BodyLimit limits the request body with http.MaxBytesReader, the body which was too large would
+// fail to read and be rejected with 41300 if the handler didn't write the response. The body whose
+// Content-Length exceeded MaxBytes would fail at the first read without reading any byte.
+// The body which was uploaded slower than MinRate would fail to read and be rejected with 40800.
+// It overrides the limit of the outer BodyLimit middlewares, so the route group could set
+// a larger or smaller limit than the global one.
+func BodyLimit(cfg BodyLimitConfig) gin.HandlerFunc {
+	if cfg.MinRateGrace <= 0 {
+		cfg.MinRateGrace = defaultMinRateGrace
+	}
+	return func(c *gin.Context) {
+		if c.Request.Body == nil || c.Request.Body == http.NoBody || (cfg.MaxBytes <= 0 && cfg.MinRate <= 0) {
+			c.Next()
+			return
+		}
+		original := c.Request.Body
+		if outer, ok := original.(*limitedBody); ok && outer.read == 0 {
+			original = outer.original
+		}
+		body := &limitedBody{
+			original:      original,
+			cfg:           &cfg,
+			contentLength: c.Request.ContentLength,
+			startTime:     time.Now(),
+		}
+		if cfg.MaxBytes > 0 {
+			body.reader = http.MaxBytesReader(c.Writer, original, cfg.MaxBytes)
+		} else {
+			body.reader = original
+		}
+		if cfg.MinRate > 0 {
+			body.controller = http.NewResponseController(c.Writer)
+		}
+		c.Request.Body = body
+		defer body.resetReadDeadline()
+		c.Next()
+
+		switch {
+		case body.tooLarge:
+			serMetrics.BodyRejected.WithLabelValues(c.FullPath(), bodyRejectedTooLarge).Inc()
+			if !c.Writer.Written() {
+				rsp.ResponseWithErrors(c, http.StatusRequestEntityTooLarge, 0,
+					[]any{fmt.Sprintf("request body was larger than %d bytes", cfg.MaxBytes)})
+			}
+		case body.tooSlow:
+			serMetrics.BodyRejected.WithLabelValues(c.FullPath(), bodyRejectedTooSlow).Inc()
+			if !c.Writer.Written() {
+				rsp.ResponseWithErrors(c, http.StatusRequestTimeout, 0,
+					[]any{fmt.Sprintf("request body was uploaded slower than %d bytes/s", cfg.MinRate)})
+			}
+		}
+	}
+}
+
+// limitedBody limits the size and upload rate of the request body. The upload rate was enforced by
+// the read deadline of the connection if it's supported, otherwise it's checked after every read.
+type limitedBody struct {
+	original      io.ReadCloser
+	reader        io.ReadCloser
+	cfg           *BodyLimitConfig
+	controller    *http.ResponseController
+	contentLength int64
+	startTime     time.Time
+	read          int64
+	deadline      bool
+	tooLarge      bool
+	tooSlow       bool
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.read == 0 && b.cfg.MaxBytes > 0 && b.contentLength > b.cfg.MaxBytes {
+		// reject without reading since the Content-Length was already too large
+		b.tooLarge = true
+		return 0, &http.MaxBytesError{Limit: b.cfg.MaxBytes}
+	}
+	if b.controller != nil {
+		// the next byte should be received before the time when the min rate would be violated
+		next := b.startTime.Add(b.cfg.MinRateGrace + time.Duration(float64(b.read+1)/float64(b.cfg.MinRate)*float64(time.Second)))
+		if err := b.controller.SetReadDeadline(next); err == nil {
+			b.deadline = true
+		}
+	}
+	n, err := b.reader.Read(p)
+	b.read += int64(n)
+	if err == io.EOF {
+		b.resetReadDeadline()
+		return n, err
+	}
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.As(err, &maxBytesErr):
+		b.tooLarge = true
+	case errors.Is(err, os.ErrDeadlineExceeded):
+		b.tooSlow = true
+		err = b.slowError()
+	case err == nil && b.cfg.MinRate > 0 && !b.deadline:
+		elapsed := time.Since(b.startTime) - b.cfg.MinRateGrace
+		if elapsed > 0 && float64(b.read) < float64(b.cfg.MinRate)*elapsed.Seconds() {
+			b.tooSlow = true
+			return n, b.slowError()
+		}
+	}
+	return n, err
+}
+
+func (b *limitedBody) Close() error {
+	return b.reader.Close()
+}
+
+// slowError wraps os.ErrDeadlineExceeded, so the bind helpers could recognize it.
+func (b *limitedBody) slowError() error {
+	return fmt.Errorf("request body was uploaded slower than %d bytes/s: %w", b.cfg.MinRate, os.ErrDeadlineExceeded)
+}
+
+// resetReadDeadline clears the read deadline, otherwise the background read of the
+// connection after the body would fail and cancel the request context.
+func (b *limitedBody) resetReadDeadline() {
+	if b.deadline {
+		_ = b.controller.SetReadDeadline(time.Time{})
+		b.deadline = false
+	}
+}
@@ -0,0 +1,144 @@
+package middleware
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	rsp "github.com/SyntSugar/ss-infra-go/api/response"
+)
+
+// slowReader returns one byte every interval.
+type slowReader struct {
+	data     []byte
+	interval time.Duration
+}
+
+func (r *slowReader) Read(p []byte) (int, error) {
+	if len(r.data) == 0 {
+		return 0, io.EOF
+	}
+	time.Sleep(r.interval)
+	p[0] = r.data[0]
+	r.data = r.data[1:]
+	return 1, nil
+}
+
+func TestBodyLimit(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	var buf bytes.Buffer
+	accessLogger, err := NewAccessLogger(&buf, "%s %I")
+	require.Nil(t, err)
+
+	engine := gin.New()
+	engine.Use(AccessLog(accessLogger), BodyLimit(BodyLimitConfig{MaxBytes: 16}))
+	echo := func(c *gin.Context) {
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			return
+		}
+		rsp.ResponseWithOK(c, string(body))
+	}
+	engine.POST("/echo", echo)
+	engine.POST("/upload", BodyLimit(BodyLimitConfig{MaxBytes: 64}), echo)
+
+	for _, tc := range []struct {
+		name      string
+		path      string
+		body      string
+		chunked   bool
+		status    int
+		bytesRead int
+	}{
+		{"Small", "/echo", "hello", false, http.StatusOK, 5},
+		{"ContentLengthTooLarge", "/echo", strings.Repeat("a", 32), false, http.StatusRequestEntityTooLarge, 0},
+		{"ChunkedTooLarge", "/echo", strings.Repeat("a", 32), true, http.StatusRequestEntityTooLarge, 17},
+		{"RouteOverride", "/upload", strings.Repeat("a", 32), false, http.StatusOK, 32},
+		{"RouteTooLarge", "/upload", strings.Repeat("a", 100), true, http.StatusRequestEntityTooLarge, 65},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			buf.Reset()
+			var body io.Reader = strings.NewReader(tc.body)
+			if tc.chunked {
+				body = io.MultiReader(body)
+			}
+			req := httptest.NewRequest(http.MethodPost, tc.path, body)
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, req)
+			assert.Equal(t, tc.status, w.Code)
+			resp, err := rsp.UnmarshalResponse(w.Body.Bytes())
+			require.Nil(t, err)
+			assert.Equal(t, tc.status*100, resp.Code())
+			fields := strings.Fields(buf.String())
+			require.Len(t, fields, 2)
+			assert.Equal(t, strconv.Itoa(tc.bytesRead), fields[1])
+		})
+	}
+}
+
+func TestBodyLimitMinRate(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	engine := gin.New()
+	engine.Use(BodyLimit(BodyLimitConfig{MinRate: 100, MinRateGrace: 50 * time.Millisecond}))
+	engine.POST("/users", func(c *gin.Context) {
+		if _, ok := rsp.BindJSON[map[string]string](c); ok {
+			rsp.ResponseWithOK(c, nil)
+		}
+	})
+
+	// the recorder doesn't support the read deadline, so the rate was checked after reading
+	req := httptest.NewRequest(http.MethodPost, "/users", &slowReader{data: []byte(`{"name":"slow"}`), interval: 20 * time.Millisecond})
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusRequestTimeout, w.Code)
+	resp, err := rsp.UnmarshalResponse(w.Body.Bytes())
+	require.Nil(t, err)
+	assert.Equal(t, 40800, resp.Code())
+
+	req = httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(`{"name":"fast"}`))
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestBodyLimitMinRateDeadline(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	accessLogger, err := NewAccessLogger(io.Discard, "")
+	require.Nil(t, err)
+	engine := gin.New()
+	engine.Use(AccessLog(accessLogger), BodyLimit(BodyLimitConfig{MinRate: 1000, MinRateGrace: 100 * time.Millisecond}))
+	engine.POST("/users", func(c *gin.Context) {
+		if _, ok := rsp.BindJSON[map[string]string](c); ok {
+			rsp.ResponseWithOK(c, nil)
+		}
+	})
+	server := httptest.NewServer(engine)
+	defer server.Close()
+
+	// the client stalls after the first byte, the read deadline should interrupt the blocking read
+	pr, pw := io.Pipe()
+	defer pw.Close()
+	go func() {
+		_, _ = pw.Write([]byte("{"))
+	}()
+	startTime := time.Now()
+	resp, err := http.Post(server.URL+"/users", "application/json", pr)
+	require.Nil(t, err)
+	defer resp.Body.Close()
+	assert.Less(t, time.Since(startTime), 2*time.Second)
+	assert.Equal(t, http.StatusRequestTimeout, resp.StatusCode)
+
+	resp, err = http.Post(server.URL+"/users", "application/json", strings.NewReader(`{"name":"fast"}`))
+	require.Nil(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
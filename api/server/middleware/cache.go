@@ -0,0 +1,308 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SyntSugar/ss-infra-go/consts"
+	"github.com/SyntSugar/ss-infra-go/log"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const (
+	HeaderCache        = "X-Cache"
+	defaultCacheTTL    = time.Minute
+	cacheResultHit     = "hit"
+	cacheResultMiss    = "miss"
+	cacheResultNotMod  = "not_modified"
+	cacheResultBypass  = "bypass"
+	cacheResultFailure = "error"
+)
+
+// defaultCacheVaryHeaders were the request headers which were always part of the cache key,
+// since the responses could be negotiated by them, e.g. JSON or MessagePack by Accept.
+var defaultCacheVaryHeaders = []string{"Accept", "Accept-Encoding", "Accept-Language"}
+
+// CacheConfig was the config of Cache middleware.
+type CacheConfig struct {
+	// Store caches the whole responses, only the ETag and Cache-Control would be handled when it's nil.
+	Store CacheStore
+	// TTL was the retention of the cached responses, default was 1 minute.
+	TTL time.Duration
+	// CacheControl was the Cache-Control header of the responses, e.g. "public, max-age=60",
+	// it wouldn't overwrite the header which was set by the handler.
+	CacheControl string
+	// VaryHeaders was the request headers which were part of the cache key besides the Accept,
+	// Accept-Encoding, Accept-Language and the Vary header of the responses, e.g. X-Tenant.
+	VaryHeaders []string
+	// IgnoreQuery would exclude the query parameters from the cache key.
+	IgnoreQuery bool
+	// Principal returns the identity of requester, e.g. the user id, to cache the private responses.
+	Principal func(c *gin.Context) string
+	// Tags returns the tags of the response to invalidate it by InvalidateTags, the handler
+	// could also add tags by SetCacheTags.
+	Tags func(c *gin.Context) []string
+}
+
+// SetCacheTags adds the tags of the response which would be cached by the Cache middleware.
+func SetCacheTags(c *gin.Context, tags ...string) {
+	existing := c.GetStringSlice(string(consts.ContextKeyCacheTags))
+	c.Set(string(consts.ContextKeyCacheTags), append(existing, tags...))
+}
+
+// Cache computes the strong ETag over the body of the ok responses and answers the
+// If-None-Match with 304, the Cache-Control policy could be set per route. The whole
+// responses would be cached in the store if it's not nil, keyed by the path, query,
+// vary headers and principal. The header names in the Vary of the responses were part
+// of the keys of the route after the response was cached, and the response with
+// "Vary: *" wasn't cached. Without the Principal, the requests with the Authorization
+// or Cookie header and the private responses bypass the store, since the responses
+// could be specific to the requester. It only works for GET and HEAD requests.
+func Cache(cfg CacheConfig, logger *log.Logger) gin.HandlerFunc {
+	if logger == nil {
+		logger = log.GlobalLogger()
+	}
+	if cfg.TTL <= 0 {
+		cfg.TTL = defaultCacheTTL
+	}
+	// the header names in the Vary of the cached responses by route
+	var routeVaries sync.Map
+	return func(c *gin.Context) {
+		if (c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead) || isSessionRoute(c) {
+			c.Next()
+			return
+		}
+		ctx := c.Request.Context()
+		uri := c.FullPath()
+		route := c.Request.Method + " " + uri
+		var key string
+		shared := cfg.Principal != nil || (c.GetHeader("Authorization") == "" && c.GetHeader("Cookie") == "")
+		if cfg.Store != nil && shared && !strings.Contains(c.GetHeader("Cache-Control"), "no-cache") {
+			vary, _ := routeVaries.Load(route)
+			names, _ := vary.([]string)
+			key = cacheKey(c, &cfg, names)
+			cached, err := cfg.Store.Get(ctx, key)
+			if err != nil {
+				serMetrics.Cache.WithLabelValues(uri, cacheResultFailure).Inc()
+				logger.WarnCtx(ctx, "Get cached response failed", zap.String("uri", uri), zap.Error(err))
+			} else if cached != nil {
+				header := c.Writer.Header()
+				for k, values := range cached.Header {
+					header[k] = values
+				}
+				header.Set(HeaderCache, "HIT")
+				if etagMatched(c.GetHeader("If-None-Match"), cached.ETag) {
+					serMetrics.Cache.WithLabelValues(uri, cacheResultNotMod).Inc()
+					c.Status(http.StatusNotModified)
+					c.Writer.WriteHeaderNow()
+				} else {
+					serMetrics.Cache.WithLabelValues(uri, cacheResultHit).Inc()
+					c.Status(cached.Status)
+					_, _ = c.Writer.Write(cached.Body)
+				}
+				c.Abort()
+				return
+			}
+		}
+
+		writer := &bufferedResponseWriter{ResponseWriter: c.Writer, status: http.StatusOK}
+		c.Writer = writer
+		c.Next()
+		c.Writer = writer.ResponseWriter
+		if writer.flushed {
+			return
+		}
+
+		header := c.Writer.Header()
+		if writer.status != http.StatusOK {
+			writer.writeTo(c.Writer, writer.status)
+			return
+		}
+		etag := header.Get("ETag")
+		if etag == "" {
+			sum := sha256.Sum256(writer.body.Bytes())
+			etag = `"` + hex.EncodeToString(sum[:16]) + `"`
+			header.Set("ETag", etag)
+		}
+		if cfg.CacheControl != "" && header.Get("Cache-Control") == "" {
+			header.Set("Cache-Control", cfg.CacheControl)
+		}
+
+		vary, varyAll := varyHeaderNames(header)
+		cacheControl := header.Get("Cache-Control")
+		private := cfg.Principal == nil && strings.Contains(cacheControl, "private")
+		if key != "" && !varyAll && !private && !strings.Contains(cacheControl, "no-store") {
+			header.Set(HeaderCache, "MISS")
+			routeVaries.Store(route, vary)
+			key = cacheKey(c, &cfg, vary)
+			cachedHeader := header.Clone()
+			cachedHeader.Del("Date")
+			cachedHeader.Del("Content-Length")
+			cachedHeader.Del("Set-Cookie")
+			tags := c.GetStringSlice(string(consts.ContextKeyCacheTags))
+			if cfg.Tags != nil {
+				tags = append(tags, cfg.Tags(c)...)
+			}
+			cached := &CachedResponse{Status: http.StatusOK, Header: cachedHeader, Body: writer.body.Bytes(), ETag: etag}
+			if err := cfg.Store.Set(ctx, key, cached, tags, cfg.TTL); err != nil {
+				logger.WarnCtx(ctx, "Set cached response failed", zap.String("uri", uri), zap.Error(err))
+			}
+			serMetrics.Cache.WithLabelValues(uri, cacheResultMiss).Inc()
+		} else {
+			serMetrics.Cache.WithLabelValues(uri, cacheResultBypass).Inc()
+		}
+
+		if etagMatched(c.GetHeader("If-None-Match"), etag) {
+			serMetrics.Cache.WithLabelValues(uri, cacheResultNotMod).Inc()
+			writer.body.Reset()
+			writer.writeTo(c.Writer, http.StatusNotModified)
+			return
+		}
+		writer.writeTo(c.Writer, http.StatusOK)
+	}
+}
+
+// cacheKey returns the hash of method, path, query, vary headers and principal, the vary
+// headers were the default ones, the configured ones and the names in the Vary of responses.
+func cacheKey(c *gin.Context, cfg *CacheConfig, vary []string) string {
+	var b strings.Builder
+	b.WriteString(c.Request.Method + "\n" + c.Request.URL.Path + "\n")
+	if !cfg.IgnoreQuery {
+		// Encode sorts the query by keys
+		b.WriteString(c.Request.URL.Query().Encode())
+	}
+	b.WriteString("\n")
+	seen := make(map[string]struct{})
+	var headers []string
+	for _, names := range [][]string{defaultCacheVaryHeaders, cfg.VaryHeaders, vary} {
+		for _, name := range names {
+			name = http.CanonicalHeaderKey(name)
+			if _, ok := seen[name]; !ok {
+				seen[name] = struct{}{}
+				headers = append(headers, name)
+			}
+		}
+	}
+	sort.Strings(headers)
+	for _, name := range headers {
+		b.WriteString(name + ":" + strings.Join(c.Request.Header.Values(name), ",") + "\n")
+	}
+	if cfg.Principal != nil {
+		b.WriteString(cfg.Principal(c))
+	}
+	sum := sha256.Sum256([]byte(b.String()))
+	return hex.EncodeToString(sum[:])
+}
+
+// varyHeaderNames returns the sorted header names in the Vary of the response,
+// and whether the response varied by all headers, i.e. "Vary: *".
+func varyHeaderNames(header http.Header) ([]string, bool) {
+	var names []string
+	for _, value := range header.Values("Vary") {
+		for _, name := range strings.Split(value, ",") {
+			name = strings.TrimSpace(name)
+			if name == "*" {
+				return nil, true
+			}
+			if name != "" {
+				names = append(names, http.CanonicalHeaderKey(name))
+			}
+		}
+	}
+	sort.Strings(names)
+	return names, false
+}
+
+// etagMatched returns whether the If-None-Match header matches the etag in the weak comparison.
+func etagMatched(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" || etag == "" {
+		return false
+	}
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// bufferedResponseWriter buffers the response to compute the ETag before writing,
+// it would write through once the handler flushed, e.g. streaming.
+type bufferedResponseWriter struct {
+	gin.ResponseWriter
+	status  int
+	body    bytes.Buffer
+	flushed bool
+}
+
+func (w *bufferedResponseWriter) WriteHeader(code int) {
+	if code > 0 && !w.flushed {
+		w.status = code
+	}
+}
+
+func (w *bufferedResponseWriter) WriteHeaderNow() {}
+
+func (w *bufferedResponseWriter) Write(data []byte) (int, error) {
+	if w.flushed {
+		return w.ResponseWriter.Write(data)
+	}
+	return w.body.Write(data)
+}
+
+func (w *bufferedResponseWriter) WriteString(s string) (int, error) {
+	if w.flushed {
+		return w.ResponseWriter.WriteString(s)
+	}
+	return w.body.WriteString(s)
+}
+
+func (w *bufferedResponseWriter) Status() int {
+	if w.flushed {
+		return w.ResponseWriter.Status()
+	}
+	return w.status
+}
+
+func (w *bufferedResponseWriter) Size() int {
+	if w.flushed {
+		return w.ResponseWriter.Size()
+	}
+	return w.body.Len()
+}
+
+func (w *bufferedResponseWriter) Written() bool {
+	return w.flushed || w.body.Len() > 0
+}
+
+func (w *bufferedResponseWriter) Flush() {
+	if !w.flushed {
+		w.flushed = true
+		w.writeTo(w.ResponseWriter, w.status)
+	}
+	w.ResponseWriter.Flush()
+}
+
+func (w *bufferedResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *bufferedResponseWriter) writeTo(writer gin.ResponseWriter, status int) {
+	writer.WriteHeader(status)
+	if w.body.Len() == 0 {
+		writer.WriteHeaderNow()
+		return
+	}
+	_, _ = writer.Write(w.body.Bytes())
+	w.body.Reset()
+}
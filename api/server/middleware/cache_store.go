@@ -0,0 +1,210 @@
+package middleware
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const defaultCacheKeyPrefix = "cache:"
+
+// CachedResponse was the cached response of the route.
+type CachedResponse struct {
+	Status int         `json:"status"`
+	Header http.Header `json:"header,omitempty"`
+	Body   []byte      `json:"body,omitempty"`
+	ETag   string      `json:"etag"`
+}
+
+// CacheStore stores the whole responses by the cache key.
+type CacheStore interface {
+	// Get returns the cached response, it returns nil if the key was absent or expired.
+	Get(ctx context.Context, key string) (*CachedResponse, error)
+	// Set stores the response with ttl and indexes the key by tags.
+	Set(ctx context.Context, key string, response *CachedResponse, tags []string, ttl time.Duration) error
+	// InvalidateTags deletes the responses which were indexed by any of tags.
+	InvalidateTags(ctx context.Context, tags ...string) error
+}
+
+type lruEntry struct {
+	key       string
+	response  *CachedResponse
+	tags      []string
+	expiredAt time.Time
+}
+
+type lruCacheStore struct {
+	mu       sync.Mutex
+	capacity int
+	ll       *list.List
+	items    map[string]*list.Element
+	tags     map[string]map[string]struct{}
+}
+
+// NewLRUCacheStore creates the in-process cache store which evicts the least recently used
+// response when the number of responses exceeds the capacity.
+func NewLRUCacheStore(capacity int) (CacheStore, error) {
+	if capacity <= 0 {
+		return nil, errors.New("capacity of the LRU cache should be positive")
+	}
+	return &lruCacheStore{
+		capacity: capacity,
+		ll:       list.New(),
+		items:    make(map[string]*list.Element),
+		tags:     make(map[string]map[string]struct{}),
+	}, nil
+}
+
+func (s *lruCacheStore) Get(_ context.Context, key string) (*CachedResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	elem, ok := s.items[key]
+	if !ok {
+		return nil, nil
+	}
+	entry := elem.Value.(*lruEntry)
+	if time.Now().After(entry.expiredAt) {
+		s.remove(elem)
+		return nil, nil
+	}
+	s.ll.MoveToFront(elem)
+	return entry.response, nil
+}
+
+func (s *lruCacheStore) Set(_ context.Context, key string, response *CachedResponse, tags []string, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if elem, ok := s.items[key]; ok {
+		s.remove(elem)
+	}
+	s.items[key] = s.ll.PushFront(&lruEntry{
+		key:       key,
+		response:  response,
+		tags:      tags,
+		expiredAt: time.Now().Add(ttl),
+	})
+	for _, tag := range tags {
+		if s.tags[tag] == nil {
+			s.tags[tag] = make(map[string]struct{})
+		}
+		s.tags[tag][key] = struct{}{}
+	}
+	for s.ll.Len() > s.capacity {
+		s.remove(s.ll.Back())
+	}
+	return nil
+}
+
+func (s *lruCacheStore) InvalidateTags(_ context.Context, tags ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, tag := range tags {
+		for key := range s.tags[tag] {
+			if elem, ok := s.items[key]; ok {
+				s.remove(elem)
+			}
+		}
+		delete(s.tags, tag)
+	}
+	return nil
+}
+
+// remove deletes the entry and its tag indexes, it must be called with the lock held.
+func (s *lruCacheStore) remove(elem *list.Element) {
+	entry := s.ll.Remove(elem).(*lruEntry)
+	delete(s.items, entry.key)
+	for _, tag := range entry.tags {
+		delete(s.tags[tag], entry.key)
+		if len(s.tags[tag]) == 0 {
+			delete(s.tags, tag)
+		}
+	}
+}
+
+// extendExpireScript extends the ttl of key if it was shorter, the tag set would
+// be kept at least as long as the responses indexed by it. It's evaluated with EVAL
+// since EVALSHA couldn't fall back in the pipeline.
+const extendExpireScript = `
+if redis.call("PTTL", KEYS[1]) < tonumber(ARGV[1]) then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[1])
+end
+return 0`
+
+type redisCacheStore struct {
+	client redis.UniversalClient
+	prefix string
+}
+
+// NewRedisCacheStore creates the cache store with the redis client, e.g. the client of datastore/redis.
+// The tags were stored in the sets of keys, and the prefix was "cache:" when it's empty. The commands
+// never span multiple keys, so that it works with the redis cluster which the keys were in different slots.
+func NewRedisCacheStore(client redis.UniversalClient, prefix string) CacheStore {
+	if prefix == "" {
+		prefix = defaultCacheKeyPrefix
+	}
+	return &redisCacheStore{client: client, prefix: prefix}
+}
+
+func (s *redisCacheStore) Get(ctx context.Context, key string) (*CachedResponse, error) {
+	value, err := s.client.Get(ctx, s.prefix+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var response CachedResponse
+	if err := json.Unmarshal(value, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+func (s *redisCacheStore) Set(ctx context.Context, key string, response *CachedResponse, tags []string, ttl time.Duration) error {
+	value, err := json.Marshal(response)
+	if err != nil {
+		return err
+	}
+	_, err = s.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, s.prefix+key, value, ttl)
+		for _, tag := range tags {
+			tagKey := s.tagKey(tag)
+			pipe.SAdd(ctx, tagKey, s.prefix+key)
+			pipe.Eval(ctx, extendExpireScript, []string{tagKey}, ttl.Milliseconds())
+		}
+		return nil
+	})
+	return err
+}
+
+func (s *redisCacheStore) InvalidateTags(ctx context.Context, tags ...string) error {
+	for _, tag := range tags {
+		tagKey := s.tagKey(tag)
+		keys, err := s.client.SMembers(ctx, tagKey).Result()
+		if err != nil {
+			return err
+		}
+		// delete the keys one by one, since they could be in different slots of the cluster
+		_, err = s.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+			for _, key := range keys {
+				pipe.Del(ctx, key)
+			}
+			pipe.Del(ctx, tagKey)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *redisCacheStore) tagKey(tag string) string {
+	return s.prefix + "tag:" + tag
+}
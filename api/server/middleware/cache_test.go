@@ -0,0 +1,274 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	rsp "github.com/SyntSugar/ss-infra-go/api/response"
+)
+
+func TestCacheETag(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	engine := gin.New()
+	engine.GET("/users/:id", Cache(CacheConfig{CacheControl: "private, max-age=60"}, nil), func(c *gin.Context) {
+		rsp.ResponseWithOK(c, map[string]string{"id": c.Param("id")})
+	})
+	engine.GET("/missing", Cache(CacheConfig{}, nil), func(c *gin.Context) {
+		rsp.ResponseWithErrors(c, http.StatusNotFound, 0, nil)
+	})
+
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users/1", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	etag := w.Header().Get("ETag")
+	require.NotEmpty(t, etag)
+	assert.Equal(t, "private, max-age=60", w.Header().Get("Cache-Control"))
+	resp, err := rsp.UnmarshalResponse(w.Body.Bytes())
+	require.Nil(t, err)
+	assert.Equal(t, 20000, resp.Code())
+
+	req := httptest.NewRequest(http.MethodGet, "/users/1", nil)
+	req.Header.Set("If-None-Match", `"other", W/`+etag)
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	assert.Empty(t, w.Body.String())
+	assert.Equal(t, etag, w.Header().Get("ETag"))
+
+	req = httptest.NewRequest(http.MethodGet, "/users/2", nil)
+	req.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotEqual(t, etag, w.Header().Get("ETag"))
+
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/missing", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Empty(t, w.Header().Get("ETag"))
+	resp, err = rsp.UnmarshalResponse(w.Body.Bytes())
+	require.Nil(t, err)
+	assert.Equal(t, 40400, resp.Code())
+}
+
+func TestCacheStore(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	store, err := NewLRUCacheStore(10)
+	require.Nil(t, err)
+	var calls int32
+	engine := gin.New()
+	engine.GET("/users/:id", Cache(CacheConfig{
+		Store:       store,
+		VaryHeaders: []string{"Accept-Language"},
+		Principal:   func(c *gin.Context) string { return c.GetHeader("X-User") },
+		Tags:        func(c *gin.Context) []string { return []string{"user:" + c.Param("id")} },
+	}, nil), func(c *gin.Context) {
+		SetCacheTags(c, "users")
+		rsp.ResponseWithOK(c, atomic.AddInt32(&calls, 1))
+	})
+
+	get := func(path string, headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		return w
+	}
+
+	first := get("/users/1?b=2&a=1", nil)
+	assert.Equal(t, "MISS", first.Header().Get(HeaderCache))
+	hit := get("/users/1?a=1&b=2", nil)
+	assert.Equal(t, "HIT", hit.Header().Get(HeaderCache))
+	assert.Equal(t, first.Body.String(), hit.Body.String())
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+
+	notModified := get("/users/1?a=1&b=2", map[string]string{"If-None-Match": first.Header().Get("ETag")})
+	assert.Equal(t, http.StatusNotModified, notModified.Code)
+
+	get("/users/1?a=1&b=2", map[string]string{"Accept-Language": "zh"})
+	get("/users/1?a=1&b=2", map[string]string{"X-User": "u1"})
+	get("/users/1?a=1&b=2", map[string]string{"Cache-Control": "no-cache"})
+	assert.Equal(t, int32(4), atomic.LoadInt32(&calls))
+
+	require.Nil(t, store.InvalidateTags(context.Background(), "user:1"))
+	assert.Equal(t, "MISS", get("/users/1?a=1&b=2", nil).Header().Get(HeaderCache))
+	get("/users/2", nil)
+	assert.Equal(t, "HIT", get("/users/2", nil).Header().Get(HeaderCache))
+	require.Nil(t, store.InvalidateTags(context.Background(), "users"))
+	assert.Equal(t, "MISS", get("/users/2", nil).Header().Get(HeaderCache))
+}
+
+func TestCacheCredentials(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	store, err := NewLRUCacheStore(10)
+	require.Nil(t, err)
+	var calls int32
+	engine := gin.New()
+	engine.Use(Cache(CacheConfig{Store: store}, nil))
+	engine.GET("/public", func(c *gin.Context) {
+		rsp.ResponseWithOK(c, atomic.AddInt32(&calls, 1))
+	})
+	engine.GET("/private", func(c *gin.Context) {
+		c.Header("Cache-Control", "private, max-age=60")
+		rsp.ResponseWithOK(c, atomic.AddInt32(&calls, 1))
+	})
+
+	get := func(path, name, value string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if name != "" {
+			req.Header.Set(name, value)
+		}
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, "MISS", get("/public", "", "").Header().Get(HeaderCache))
+	// the credentialed requests neither read nor write the shared responses
+	assert.Empty(t, get("/public", "Authorization", "Bearer u1").Header().Get(HeaderCache))
+	assert.Empty(t, get("/public", "Cookie", "session=u1").Header().Get(HeaderCache))
+	assert.Equal(t, "HIT", get("/public", "", "").Header().Get(HeaderCache))
+	assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+
+	assert.Empty(t, get("/private", "", "").Header().Get(HeaderCache))
+	assert.Empty(t, get("/private", "", "").Header().Get(HeaderCache))
+	assert.Equal(t, int32(5), atomic.LoadInt32(&calls))
+}
+
+func TestLRUCacheStore(t *testing.T) {
+	_, err := NewLRUCacheStore(0)
+	assert.NotNil(t, err)
+
+	ctx := context.Background()
+	store, err := NewLRUCacheStore(2)
+	require.Nil(t, err)
+	require.Nil(t, store.Set(ctx, "a", &CachedResponse{ETag: "a"}, []string{"t"}, time.Minute))
+	require.Nil(t, store.Set(ctx, "b", &CachedResponse{ETag: "b"}, nil, time.Minute))
+	cached, _ := store.Get(ctx, "a")
+	require.NotNil(t, cached)
+	require.Nil(t, store.Set(ctx, "c", &CachedResponse{ETag: "c"}, nil, time.Minute))
+	cached, _ = store.Get(ctx, "b")
+	assert.Nil(t, cached, "b was the least recently used")
+	cached, _ = store.Get(ctx, "a")
+	assert.NotNil(t, cached)
+
+	require.Nil(t, store.Set(ctx, "d", &CachedResponse{ETag: "d"}, nil, -time.Second))
+	cached, _ = store.Get(ctx, "d")
+	assert.Nil(t, cached, "d was expired")
+}
+
+func TestCacheVary(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	store, err := NewLRUCacheStore(10)
+	require.Nil(t, err)
+	engine := gin.New()
+	engine.GET("/users", Cache(CacheConfig{Store: store}, nil), func(c *gin.Context) {
+		if c.GetHeader("Accept") == "application/msgpack" {
+			c.Data(http.StatusOK, "application/msgpack", []byte{0x80})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{})
+	})
+	engine.GET("/tenants", Cache(CacheConfig{Store: store}, nil), func(c *gin.Context) {
+		c.Header("Vary", "X-Tenant")
+		c.String(http.StatusOK, c.GetHeader("X-Tenant"))
+	})
+	engine.GET("/any", Cache(CacheConfig{Store: store}, nil), func(c *gin.Context) {
+		c.Header("Vary", "*")
+		c.String(http.StatusOK, "any")
+	})
+
+	get := func(path, name, value string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set(name, value)
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		return w
+	}
+
+	// the msgpack response shouldn't be served to the JSON request
+	assert.Equal(t, "MISS", get("/users", "Accept", "application/msgpack").Header().Get(HeaderCache))
+	w := get("/users", "Accept", "application/json")
+	assert.Equal(t, "MISS", w.Header().Get(HeaderCache))
+	assert.Equal(t, "{}", w.Body.String())
+	w = get("/users", "Accept", "application/msgpack")
+	assert.Equal(t, "HIT", w.Header().Get(HeaderCache))
+	assert.Equal(t, "application/msgpack", w.Header().Get("Content-Type"))
+
+	// the headers in the Vary of the response were part of the key
+	assert.Equal(t, "MISS", get("/tenants", "X-Tenant", "a").Header().Get(HeaderCache))
+	w = get("/tenants", "X-Tenant", "b")
+	assert.Equal(t, "MISS", w.Header().Get(HeaderCache))
+	assert.Equal(t, "b", w.Body.String())
+	w = get("/tenants", "X-Tenant", "a")
+	assert.Equal(t, "HIT", w.Header().Get(HeaderCache))
+	assert.Equal(t, "a", w.Body.String())
+
+	get("/any", "Accept", "*/*")
+	assert.Empty(t, get("/any", "Accept", "*/*").Header().Get(HeaderCache))
+}
+
+func TestRedisCacheStore(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+	store := NewRedisCacheStore(client, "")
+	ctx := context.Background()
+
+	cached, err := store.Get(ctx, "a")
+	require.Nil(t, err)
+	assert.Nil(t, cached)
+
+	response := &CachedResponse{
+		Status: http.StatusOK,
+		Header: http.Header{"Content-Type": []string{"application/json"}},
+		Body:   []byte(`{"id":1}`),
+		ETag:   `"a"`,
+	}
+	require.Nil(t, store.Set(ctx, "a", response, []string{"users", "user:1"}, time.Minute))
+	cached, err = store.Get(ctx, "a")
+	require.Nil(t, err)
+	assert.Equal(t, response, cached)
+	assert.Equal(t, time.Minute, mr.TTL(defaultCacheKeyPrefix+"a"))
+
+	// the ttl of tag set was extended to the longest response, but not shortened
+	require.Nil(t, store.Set(ctx, "b", &CachedResponse{ETag: `"b"`}, []string{"users"}, time.Hour))
+	assert.Equal(t, time.Hour, mr.TTL(defaultCacheKeyPrefix+"tag:users"))
+	require.Nil(t, store.Set(ctx, "c", &CachedResponse{ETag: `"c"`}, []string{"users"}, time.Second))
+	assert.Equal(t, time.Hour, mr.TTL(defaultCacheKeyPrefix+"tag:users"))
+	assert.Equal(t, time.Minute, mr.TTL(defaultCacheKeyPrefix+"tag:user:1"))
+	members, err := mr.SMembers(defaultCacheKeyPrefix + "tag:users")
+	require.Nil(t, err)
+	assert.ElementsMatch(t, []string{defaultCacheKeyPrefix + "a", defaultCacheKeyPrefix + "b", defaultCacheKeyPrefix + "c"}, members)
+
+	require.Nil(t, store.InvalidateTags(ctx, "user:1"))
+	cached, err = store.Get(ctx, "a")
+	require.Nil(t, err)
+	assert.Nil(t, cached)
+	assert.False(t, mr.Exists(defaultCacheKeyPrefix+"tag:user:1"))
+	cached, _ = store.Get(ctx, "b")
+	assert.NotNil(t, cached)
+
+	require.Nil(t, store.InvalidateTags(ctx, "users", "absent"))
+	for _, key := range []string{"b", "c"} {
+		cached, err = store.Get(ctx, key)
+		require.Nil(t, err)
+		assert.Nil(t, cached)
+	}
+
+	mr.SetError("connection refused")
+	_, err = store.Get(ctx, "a")
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,197 @@
+package middleware
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/SyntSugar/ss-infra-go/consts"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	HeaderCFConnectingIP = "CF-Connecting-IP"
+	HeaderXForwardedFor  = "X-Forwarded-For"
+	HeaderXRealIP        = "X-Real-IP"
+)
+
+// CloudflareIPRanges was the published IP ranges of Cloudflare(https://www.cloudflare.com/ips/),
+// it's appended to the trusted proxies when the TrustCloudflare was enabled, and the CF-Connecting-IP
+// header was honored only if the peer was inside them.
+var CloudflareIPRanges = []string{
+	"173.245.48.0/20", "103.21.244.0/22", "103.22.200.0/22", "103.31.4.0/22",
+	"141.101.64.0/18", "108.162.192.0/18", "190.93.240.0/20", "188.114.96.0/20",
+	"197.234.240.0/22", "198.41.128.0/17", "162.158.0.0/15", "104.16.0.0/13",
+	"104.24.0.0/14", "172.64.0.0/13", "131.0.72.0/22",
+	"2400:cb00::/32", "2606:4700::/32", "2803:f800::/32", "2405:b500::/32",
+	"2405:8100::/32", "2a06:98c0::/29", "2c0f:f248::/32",
+}
+
+// ClientIPConfig was the config of ClientIPResolver.
+type ClientIPConfig struct {
+	// TrustedProxies was the CIDRs or IPs of the trusted proxies, the forwarded headers
+	// would be ignored if the peer wasn't one of them.
+	TrustedProxies []string
+	// TrustCloudflare trusts the Cloudflare edges, and their CF-Connecting-IP header which takes
+	// precedence over the Headers when the peer was a Cloudflare edge.
+	TrustCloudflare bool
+	// Headers was the forwarded headers in order of precedence, default was X-Forwarded-For and X-Real-IP.
+	Headers []string
+}
+
+// ClientIPResolver resolves the client IP of requests behind the trusted proxies.
+type ClientIPResolver struct {
+	trusted    []*net.IPNet
+	cloudflare []*net.IPNet
+	headers    []string
+}
+
+// NewClientIPResolver creates the resolver, returns error if the trusted proxies were invalid.
+func NewClientIPResolver(cfg ClientIPConfig) (*ClientIPResolver, error) {
+	headers := cfg.Headers
+	if len(headers) == 0 {
+		headers = []string{HeaderXForwardedFor, HeaderXRealIP}
+	}
+	trusted, err := ParseCIDRs(cfg.TrustedProxies)
+	if err != nil {
+		return nil, err
+	}
+	resolver := &ClientIPResolver{trusted: trusted, headers: headers}
+	if cfg.TrustCloudflare {
+		if resolver.cloudflare, err = ParseCIDRs(CloudflareIPRanges); err != nil {
+			return nil, err
+		}
+		resolver.trusted = append(resolver.trusted, resolver.cloudflare...)
+	}
+	return resolver, nil
+}
+
+// ParseCIDRs parses the CIDRs, the bare IP was treated as the single address network.
+func ParseCIDRs(values []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(values))
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if !strings.Contains(value, "/") {
+			ip := net.ParseIP(value)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid trusted proxy: %s", value)
+			}
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy: %s", value)
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets, nil
+}
+
+// IsTrusted returns whether the IP was one of the trusted proxies.
+func (resolver *ClientIPResolver) IsTrusted(ip net.IP) bool {
+	return containsIP(resolver.trusted, ip)
+}
+
+// Resolve returns the client IP of request. The forwarded headers were honored only if the peer
+// was trusted, and the X-Forwarded-For was walked from right to left until the untrusted hop.
+// The CF-Connecting-IP was honored only if the peer was a Cloudflare edge.
+func (resolver *ClientIPResolver) Resolve(r *http.Request) string {
+	remoteIP := RemoteIP(r.RemoteAddr)
+	ip := net.ParseIP(remoteIP)
+	if ip == nil || !resolver.IsTrusted(ip) {
+		return remoteIP
+	}
+	if containsIP(resolver.cloudflare, ip) {
+		if value := net.ParseIP(strings.TrimSpace(r.Header.Get(HeaderCFConnectingIP))); value != nil {
+			return value.String()
+		}
+	}
+	for _, header := range resolver.headers {
+		var hops []string
+		for _, value := range r.Header.Values(header) {
+			hops = append(hops, strings.Split(value, ",")...)
+		}
+		if client := resolver.clientHop(hops); client != nil {
+			return client.String()
+		}
+	}
+	return remoteIP
+}
+
+// clientHop returns the rightmost untrusted hop which was appended by the proxies, or the leftmost
+// one if all hops were trusted. It returns nil if any hop on the way was invalid, and the next header
+// would be used then, the same as gin.
+func (resolver *ClientIPResolver) clientHop(hops []string) net.IP {
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := net.ParseIP(strings.TrimSpace(hops[i]))
+		if hop == nil {
+			return nil
+		}
+		if i == 0 || !resolver.IsTrusted(hop) {
+			return hop
+		}
+	}
+	return nil
+}
+
+// SetupEngine sets the trusted proxies and the forwarded headers of the gin engine as the resolver,
+// so that c.ClientIP() agrees with ClientIP, since gin trusts all proxies by default. The CF-Connecting-IP
+// wasn't set into gin which honors it from any peer, and the X-Forwarded-For which was appended by the
+// Cloudflare edges resolves the same client IP.
+func (resolver *ClientIPResolver) SetupEngine(engine *gin.Engine) error {
+	proxies := make([]string, 0, len(resolver.trusted))
+	for _, ipNet := range resolver.trusted {
+		proxies = append(proxies, ipNet.String())
+	}
+	engine.ForwardedByClientIP = true
+	engine.RemoteIPHeaders = append([]string(nil), resolver.headers...)
+	return engine.SetTrustedProxies(proxies)
+}
+
+// ClientIPMiddleware resolves the client IP once per request and stores it in the context,
+// it's used by the access log, metrics and the auth middlewares by the ClientIP func.
+func ClientIPMiddleware(resolver *ClientIPResolver) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ip := resolver.Resolve(c.Request)
+		c.Set(string(consts.ContextKeyClientIP), ip)
+		source := "remote"
+		if ip != RemoteIP(c.Request.RemoteAddr) {
+			source = "forwarded"
+		}
+		serMetrics.ClientIPResolved.WithLabelValues(source).Inc()
+		c.Next()
+	}
+}
+
+// ClientIP returns the client IP which was resolved by the ClientIPMiddleware,
+// it's the IP of peer if the middleware wasn't used.
+func ClientIP(c *gin.Context) string {
+	if ip := c.GetString(string(consts.ContextKeyClientIP)); ip != "" {
+		return ip
+	}
+	return RemoteIP(c.Request.RemoteAddr)
+}
+
+// RemoteIP returns the IP of the remote address which was "host:port" or "[host]:port" for IPv6.
+func RemoteIP(remoteAddr string) string {
+	if host, _, err := net.SplitHostPort(remoteAddr); err == nil {
+		return host
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(remoteAddr, "["), "]")
+}
+
+func containsIP(nets []*net.IPNet, ip net.IP) bool {
+	for _, ipNet := range nets {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
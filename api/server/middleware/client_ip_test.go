@@ -0,0 +1,141 @@
+package middleware
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRemoteIP(t *testing.T) {
+	assert.Equal(t, "10.0.0.1", RemoteIP("10.0.0.1:8080"))
+	assert.Equal(t, "2001:db8::1", RemoteIP("[2001:db8::1]:8080"))
+	assert.Equal(t, "2001:db8::1", RemoteIP("2001:db8::1"))
+	assert.Equal(t, "@", RemoteIP("@"))
+}
+
+func TestParseCIDRs(t *testing.T) {
+	nets, err := ParseCIDRs([]string{"10.0.0.0/8", "192.168.1.1", "2001:db8::/32", "::1"})
+	require.Nil(t, err)
+	require.Len(t, nets, 4)
+	assert.Equal(t, "192.168.1.1/32", nets[1].String())
+	assert.Equal(t, "::1/128", nets[3].String())
+
+	_, err = ParseCIDRs([]string{"10.0.0.0/33"})
+	assert.NotNil(t, err)
+	_, err = ParseCIDRs([]string{"localhost"})
+	assert.NotNil(t, err)
+}
+
+func TestClientIPResolver(t *testing.T) {
+	resolver, err := NewClientIPResolver(ClientIPConfig{
+		TrustedProxies:  []string{"10.0.0.0/8", "2001:db8::/32"},
+		TrustCloudflare: true,
+	})
+	require.Nil(t, err)
+
+	for _, tc := range []struct {
+		name       string
+		remoteAddr string
+		headers    map[string]string
+		expected   string
+	}{
+		{"untrusted peer", "1.2.3.4:80", map[string]string{HeaderXForwardedFor: "5.6.7.8"}, "1.2.3.4"},
+		{"no headers", "10.0.0.1:80", nil, "10.0.0.1"},
+		{"forwarded for", "10.0.0.1:80", map[string]string{HeaderXForwardedFor: "9.9.9.9, 5.6.7.8, 10.0.0.2"}, "5.6.7.8"},
+		{"all trusted hops", "10.0.0.1:80", map[string]string{HeaderXForwardedFor: "10.0.0.3, 10.0.0.2"}, "10.0.0.3"},
+		{"invalid hop", "10.0.0.1:80", map[string]string{HeaderXForwardedFor: "5.6.7.8, unknown, 10.0.0.2"}, "10.0.0.1"},
+		{"invalid hop with real ip", "10.0.0.1:80",
+			map[string]string{HeaderXForwardedFor: "5.6.7.8, unknown", HeaderXRealIP: "5.6.7.9"}, "5.6.7.9"},
+		{"real ip", "10.0.0.1:80", map[string]string{HeaderXRealIP: "5.6.7.8"}, "5.6.7.8"},
+		{"ipv6 peer", "[2001:db8::1]:80", map[string]string{HeaderXForwardedFor: "2001:db9::1"}, "2001:db9::1"},
+		{"forwarded port", "10.0.0.1:80", map[string]string{HeaderXForwardedFor: "5.6.7.8:443"}, "10.0.0.1"},
+		{"cloudflare", "173.245.48.1:80", map[string]string{HeaderCFConnectingIP: "5.6.7.8", HeaderXForwardedFor: "9.9.9.9"}, "5.6.7.8"},
+		{"spoofed cloudflare", "1.2.3.4:80", map[string]string{HeaderCFConnectingIP: "5.6.7.8"}, "1.2.3.4"},
+		{"cloudflare header from trusted proxy", "10.0.0.1:80",
+			map[string]string{HeaderCFConnectingIP: "5.6.7.8", HeaderXForwardedFor: "9.9.9.9, 173.245.48.1"}, "9.9.9.9"},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = tc.remoteAddr
+		for k, v := range tc.headers {
+			req.Header.Set(k, v)
+		}
+		assert.Equal(t, tc.expected, resolver.Resolve(req), tc.name)
+	}
+}
+
+func TestClientIPResolverSetupEngine(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	for _, cfg := range []ClientIPConfig{
+		{},
+		{TrustedProxies: []string{"10.0.0.0/8", "2001:db8::/32"}},
+		{TrustedProxies: []string{"10.0.0.0/8"}, TrustCloudflare: true},
+	} {
+		resolver, err := NewClientIPResolver(cfg)
+		require.Nil(t, err)
+		engine := gin.New()
+		require.Nil(t, resolver.SetupEngine(engine))
+		var resolved, ginResolved string
+		engine.Use(ClientIPMiddleware(resolver))
+		engine.GET("/", func(c *gin.Context) {
+			resolved, ginResolved = ClientIP(c), c.ClientIP()
+		})
+
+		for _, tc := range []struct {
+			remoteAddr string
+			headers    map[string]string
+		}{
+			{"1.2.3.4:80", map[string]string{HeaderXForwardedFor: "5.6.7.8"}},
+			{"10.0.0.1:80", map[string]string{HeaderXForwardedFor: "9.9.9.9, 5.6.7.8, 10.0.0.2"}},
+			{"10.0.0.1:80", map[string]string{HeaderXForwardedFor: "10.0.0.3, 10.0.0.2"}},
+			{"10.0.0.1:80", map[string]string{HeaderXForwardedFor: "5.6.7.8, unknown", HeaderXRealIP: "5.6.7.9"}},
+			{"[2001:db8::1]:80", map[string]string{HeaderXForwardedFor: "2001:db9::1"}},
+			{"173.245.48.1:80", map[string]string{HeaderCFConnectingIP: "5.6.7.8", HeaderXForwardedFor: "5.6.7.8"}},
+			{"10.0.0.1:80", map[string]string{HeaderCFConnectingIP: "5.6.7.8", HeaderXForwardedFor: "9.9.9.9"}},
+		} {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tc.remoteAddr
+			for k, v := range tc.headers {
+				req.Header.Set(k, v)
+			}
+			engine.ServeHTTP(httptest.NewRecorder(), req)
+			assert.Equal(t, resolved, ginResolved, "%+v %+v", cfg, tc)
+		}
+	}
+}
+
+func TestClientIPAccessLog(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	var buf bytes.Buffer
+	accessLogger, err := NewAccessLogger(&buf, `%a "%{X-Forwarded-For}i"`)
+	require.Nil(t, err)
+	resolver, err := NewClientIPResolver(ClientIPConfig{TrustedProxies: []string{"::1"}})
+	require.Nil(t, err)
+
+	var ip string
+	engine := gin.New()
+	engine.Use(AccessLog(accessLogger), ClientIPMiddleware(resolver))
+	engine.GET("/", func(c *gin.Context) {
+		ip = ClientIP(c)
+		c.Status(http.StatusOK)
+	})
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "[::1]:12345"
+	req.Header.Set(HeaderXForwardedFor, "5.6.7.8")
+	engine.ServeHTTP(httptest.NewRecorder(), req)
+	assert.Equal(t, "5.6.7.8", ip)
+	assert.Equal(t, `5.6.7.8 "5.6.7.8"`, strings.TrimSpace(buf.String()))
+
+	// the IP of peer was used without the middleware
+	buf.Reset()
+	engine = gin.New()
+	engine.Use(AccessLog(accessLogger))
+	engine.GET("/", func(c *gin.Context) { c.Status(http.StatusOK) })
+	engine.ServeHTTP(httptest.NewRecorder(), req)
+	assert.Equal(t, `::1 "5.6.7.8"`, strings.TrimSpace(buf.String()))
+}
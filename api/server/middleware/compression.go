@@ -0,0 +1,266 @@
+package middleware
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/SyntSugar/ss-infra-go/consts"
+
+	"github.com/andybalholm/brotli"
+	"github.com/gin-gonic/gin"
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	EncodingGzip   = "gzip"
+	EncodingBrotli = "br"
+	EncodingZstd   = "zstd"
+
+	defaultCompressionMinSize = 1024
+	defaultBrotliLevel        = 4
+)
+
+var defaultCompressibleTypes = []string{
+	"application/json",
+	"application/problem+json",
+	"application/javascript",
+	"application/xml",
+	"image/svg+xml",
+	"text/",
+}
+
+// CompressionConfig was the config of Compression middleware.
+type CompressionConfig struct {
+	// Encodings was the supported encodings in the order of preference when the client
+	// accepted them with the same quality, default was br, zstd and gzip.
+	Encodings []string
+	// MinSize was the min body size to compress, default was 1KiB.
+	MinSize int
+	// ContentTypes was the allowlist of content type prefixes, default was JSON, XML, JavaScript and text.
+	ContentTypes []string
+}
+
+func (cfg *CompressionConfig) init() {
+	if len(cfg.Encodings) == 0 {
+		cfg.Encodings = []string{EncodingBrotli, EncodingZstd, EncodingGzip}
+	}
+	if cfg.MinSize <= 0 {
+		cfg.MinSize = defaultCompressionMinSize
+	}
+	if len(cfg.ContentTypes) == 0 {
+		cfg.ContentTypes = defaultCompressibleTypes
+	}
+}
+
+type encoder interface {
+	io.WriteCloser
+	Reset(w io.Writer)
+	Flush() error
+}
+
+var encoderPools = map[string]*sync.Pool{
+	EncodingGzip: {New: func() any {
+		return gzip.NewWriter(nil)
+	}},
+	EncodingBrotli: {New: func() any {
+		return brotli.NewWriterLevel(nil, defaultBrotliLevel)
+	}},
+	EncodingZstd: {New: func() any {
+		// the error was always nil with these options
+		enc, _ := zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1), zstd.WithEncoderLevel(zstd.SpeedDefault))
+		return enc
+	}},
+}
+
+// Compression compresses the response with the encoding negotiated by the Accept-Encoding header.
+// The response would be compressed only if its body was larger than MinSize and its content type was
+// in the allowlist, and the streaming responses(e.g. SSE) which flushed before that were bypassed.
+// The uncompressed size was kept in the context for the access log and metrics, while the writer
+// of the outer middlewares counts the bytes on the wire.
+func Compression(cfg CompressionConfig) gin.HandlerFunc {
+	cfg.init()
+	return func(c *gin.Context) {
+		if c.Request.Method == http.MethodHead || c.GetHeader("Range") != "" || isSessionRoute(c) {
+			c.Next()
+			return
+		}
+		writer := &compressResponseWriter{
+			ResponseWriter: c.Writer,
+			cfg:            &cfg,
+			encoding:       negotiateEncoding(c.GetHeader("Accept-Encoding"), cfg.Encodings),
+		}
+		c.Writer = writer
+		defer func() {
+			writer.finish()
+			c.Writer = writer.ResponseWriter
+			c.Set(string(consts.ContextKeyUncompressedSize), writer.size)
+		}()
+		c.Next()
+	}
+}
+
+// negotiateEncoding returns the supported encoding with the highest quality, the preference
+// order would be used if the qualities were the same. It returns empty if none was acceptable.
+func negotiateEncoding(acceptEncoding string, supported []string) string {
+	if acceptEncoding == "" {
+		return ""
+	}
+	qualities := make(map[string]float64)
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if v, found := strings.CutPrefix(strings.TrimSpace(params), "q="); found {
+			if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+				q = parsed
+			}
+		}
+		qualities[strings.ToLower(strings.TrimSpace(name))] = q
+	}
+	best, bestQ := "", 0.0
+	for _, encoding := range supported {
+		q, ok := qualities[encoding]
+		if !ok {
+			q, ok = qualities["*"]
+		}
+		if ok && q > bestQ {
+			best, bestQ = encoding, q
+		}
+	}
+	return best
+}
+
+// compressResponseWriter buffers the body until MinSize to decide whether to compress.
+type compressResponseWriter struct {
+	gin.ResponseWriter
+	cfg      *CompressionConfig
+	encoding string
+	buf      bytes.Buffer
+	encoder  encoder
+	decided  bool
+	// size was the uncompressed bytes written by the handler.
+	size int
+}
+
+func (w *compressResponseWriter) Write(data []byte) (int, error) {
+	w.size += len(data)
+	if w.decided {
+		if w.encoder != nil {
+			return w.encoder.Write(data)
+		}
+		return w.ResponseWriter.Write(data)
+	}
+	w.buf.Write(data)
+	if w.buf.Len() >= w.cfg.MinSize {
+		if err := w.decide(true); err != nil {
+			return 0, err
+		}
+	}
+	return len(data), nil
+}
+
+func (w *compressResponseWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *compressResponseWriter) Size() int {
+	return w.size
+}
+
+func (w *compressResponseWriter) Written() bool {
+	return w.buf.Len() > 0 || w.ResponseWriter.Written()
+}
+
+// Flush bypasses the compression if it wasn't decided, since the streaming responses
+// should be delivered immediately.
+func (w *compressResponseWriter) Flush() {
+	if !w.decided {
+		_ = w.decide(false)
+	}
+	if w.encoder != nil {
+		_ = w.encoder.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+func (w *compressResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *compressResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	w.decided = true
+	return w.ResponseWriter.Hijack()
+}
+
+// decide sets the headers and writes the buffered body with or without the encoder.
+func (w *compressResponseWriter) decide(compress bool) error {
+	w.decided = true
+	header := w.Header()
+	contentType := header.Get("Content-Type")
+	compressible := w.compressibleType(contentType)
+	if compressible {
+		addVary(header, "Accept-Encoding")
+	}
+	status := w.ResponseWriter.Status()
+	if compress && compressible && w.encoding != "" && header.Get("Content-Encoding") == "" &&
+		status != http.StatusNoContent && status != http.StatusNotModified && status != http.StatusPartialContent {
+		header.Set("Content-Encoding", w.encoding)
+		header.Del("Content-Length")
+		header.Del("Accept-Ranges")
+		w.encoder = encoderPools[w.encoding].Get().(encoder)
+		w.encoder.Reset(w.ResponseWriter)
+	}
+	if w.buf.Len() == 0 {
+		return nil
+	}
+	var err error
+	if w.encoder != nil {
+		_, err = w.encoder.Write(w.buf.Bytes())
+	} else {
+		_, err = w.ResponseWriter.Write(w.buf.Bytes())
+	}
+	w.buf.Reset()
+	return err
+}
+
+func (w *compressResponseWriter) compressibleType(contentType string) bool {
+	if contentType == "" || strings.HasPrefix(contentType, "text/event-stream") {
+		return false
+	}
+	for _, prefix := range w.cfg.ContentTypes {
+		if strings.HasPrefix(contentType, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// finish writes the small body without compression, and returns the encoder into the pool.
+func (w *compressResponseWriter) finish() {
+	if !w.decided {
+		_ = w.decide(false)
+	}
+	if w.encoder != nil {
+		_ = w.encoder.Close()
+		w.encoder.Reset(io.Discard)
+		encoderPools[w.encoding].Put(w.encoder)
+		w.encoder = nil
+	}
+}
+
+func addVary(header http.Header, value string) {
+	for _, vary := range header.Values("Vary") {
+		for _, v := range strings.Split(vary, ",") {
+			if strings.EqualFold(strings.TrimSpace(v), value) {
+				return
+			}
+		}
+	}
+	header.Add("Vary", value)
+}
@@ -0,0 +1,156 @@
+package middleware
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/gin-gonic/gin"
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	supported := []string{EncodingBrotli, EncodingZstd, EncodingGzip}
+	for _, tc := range []struct {
+		acceptEncoding string
+		expected       string
+	}{
+		{"", ""},
+		{"identity", ""},
+		{"gzip", EncodingGzip},
+		{"gzip, br", EncodingBrotli},
+		{"gzip;q=1.0, br;q=0.5", EncodingGzip},
+		{"zstd, br;q=0", EncodingZstd},
+		{"*", EncodingBrotli},
+		{"*;q=0.5, gzip", EncodingGzip},
+		{"GZIP;q=0.8, deflate", EncodingGzip},
+	} {
+		assert.Equal(t, tc.expected, negotiateEncoding(tc.acceptEncoding, supported), tc.acceptEncoding)
+	}
+}
+
+func decompress(t *testing.T, encoding string, body []byte) string {
+	var reader io.Reader
+	switch encoding {
+	case EncodingGzip:
+		gr, err := gzip.NewReader(bytes.NewReader(body))
+		require.Nil(t, err)
+		reader = gr
+	case EncodingBrotli:
+		reader = brotli.NewReader(bytes.NewReader(body))
+	case EncodingZstd:
+		zr, err := zstd.NewReader(bytes.NewReader(body))
+		require.Nil(t, err)
+		defer zr.Close()
+		reader = zr
+	default:
+		return string(body)
+	}
+	data, err := io.ReadAll(reader)
+	require.Nil(t, err)
+	return string(data)
+}
+
+func TestCompression(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	large := strings.Repeat("compressible ", 200)
+	var uncompressed, wire int
+	engine := gin.New()
+	engine.Use(func(c *gin.Context) {
+		c.Next()
+		uncompressed = uncompressedSize(c, c.Writer.Size())
+		wire = c.Writer.Size()
+	})
+	engine.Use(Compression(CompressionConfig{}))
+	engine.GET("/large", func(c *gin.Context) {
+		c.String(http.StatusOK, large)
+	})
+	engine.GET("/small", func(c *gin.Context) {
+		c.String(http.StatusOK, "small")
+	})
+	engine.GET("/binary", func(c *gin.Context) {
+		c.Data(http.StatusOK, "application/octet-stream", []byte(large))
+	})
+	engine.GET("/events", func(c *gin.Context) {
+		c.Header("Content-Type", "text/event-stream")
+		for i := 0; i < 100; i++ {
+			_, _ = c.Writer.WriteString("data: " + large[:20] + "\n\n")
+			c.Writer.Flush()
+		}
+	})
+	engine.GET("/flush", func(c *gin.Context) {
+		c.Header("Content-Type", "text/plain")
+		_, _ = c.Writer.WriteString("first")
+		c.Writer.Flush()
+		_, _ = c.Writer.WriteString(large)
+	})
+
+	for _, encoding := range []string{EncodingGzip, EncodingBrotli, EncodingZstd} {
+		req := httptest.NewRequest(http.MethodGet, "/large", nil)
+		req.Header.Set("Accept-Encoding", encoding)
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, encoding, w.Header().Get("Content-Encoding"))
+		assert.Equal(t, "Accept-Encoding", w.Header().Get("Vary"))
+		assert.Equal(t, large, decompress(t, encoding, w.Body.Bytes()))
+		assert.Equal(t, len(large), uncompressed)
+		assert.Equal(t, w.Body.Len(), wire)
+		assert.Less(t, wire, uncompressed)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/large", nil)
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	assert.Empty(t, w.Header().Get("Content-Encoding"))
+	assert.Equal(t, "Accept-Encoding", w.Header().Get("Vary"))
+	assert.Equal(t, large, w.Body.String())
+
+	for _, path := range []string{"/small", "/binary", "/events", "/flush"} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code, path)
+		assert.Empty(t, w.Header().Get("Content-Encoding"), path)
+		assert.Equal(t, w.Body.Len(), uncompressed, path)
+		assert.Equal(t, w.Body.Len(), wire, path)
+	}
+
+	req = httptest.NewRequest(http.MethodHead, "/large", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	assert.Empty(t, w.Header().Get("Content-Encoding"))
+}
+
+func TestCompressionAccessLog(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	var buf bytes.Buffer
+	logger, err := NewAccessLogger(&buf, "%B %Z")
+	require.Nil(t, err)
+	large := strings.Repeat("a", 4096)
+	engine := gin.New()
+	engine.Use(AccessLog(logger), Compression(CompressionConfig{}))
+	engine.GET("/large", func(c *gin.Context) {
+		c.String(http.StatusOK, large)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/large", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	require.Equal(t, EncodingGzip, w.Header().Get("Content-Encoding"))
+	fields := strings.Fields(buf.String())
+	require.Len(t, fields, 2)
+	assert.Equal(t, strconv.Itoa(w.Body.Len()), fields[0])
+	assert.Equal(t, strconv.Itoa(len(large)), fields[1])
+}
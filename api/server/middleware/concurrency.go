@@ -0,0 +1,326 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	rsp "github.com/SyntSugar/ss-infra-go/api/response"
+	"github.com/SyntSugar/ss-infra-go/consts"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	LimitAlgorithmAIMD     = "aimd"
+	LimitAlgorithmGradient = "gradient"
+
+	HeaderPriority = "X-Priority"
+
+	defaultInitialLimit     = 20
+	defaultMinLimit         = 1
+	defaultMaxLimit         = 1000
+	defaultLatencyThreshold = time.Second
+	globalLimitScope        = "global"
+)
+
+// Priority was the class of requests, the lower priority requests would be shed first under overload.
+type Priority int
+
+const (
+	PriorityCritical Priority = iota
+	PriorityNormal
+	PriorityLow
+)
+
+// priorityShares was the share of limit which could be used by the priority classes,
+// e.g. the low priority requests would be shed when half of the limit was in use.
+var priorityShares = map[Priority]float64{
+	PriorityCritical: 1.0,
+	PriorityNormal:   0.9,
+	PriorityLow:      0.5,
+}
+
+func (p Priority) String() string {
+	switch p {
+	case PriorityCritical:
+		return "critical"
+	case PriorityLow:
+		return "low"
+	default:
+		return "normal"
+	}
+}
+
+// ParsePriority parses the priority name, it returns PriorityNormal if the name was unknown.
+func ParsePriority(name string) Priority {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "critical":
+		return PriorityCritical
+	case "low":
+		return PriorityLow
+	default:
+		return PriorityNormal
+	}
+}
+
+// SetPriority sets the priority of the request, it's used by the ConcurrencyLimit middleware
+// and takes precedence over the route and header priorities.
+func SetPriority(c *gin.Context, priority Priority) {
+	c.Set(string(consts.ContextKeyPriority), priority)
+}
+
+// ConcurrencyLimitConfig was the config of ConcurrencyLimit middleware.
+type ConcurrencyLimitConfig struct {
+	// Algorithm was the algorithm to adapt the limit by the observed latency, aimd or gradient(default).
+	Algorithm string
+	// InitialLimit was the initial limit of in-flight requests, default was 20.
+	InitialLimit int
+	// MinLimit and MaxLimit were the bounds of the adaptive limit, default were 1 and 1000.
+	MinLimit int
+	MaxLimit int
+	// LatencyThreshold was the latency that the aimd algorithm treats as the overload, default was 1 second.
+	LatencyThreshold time.Duration
+	// PerRoute would also limit the in-flight requests of every route with an independent adaptive limit,
+	// the excess requests of the route would be rejected with 42900 while the global ones with 50300.
+	PerRoute bool
+	// RouteMaxLimit was the MaxLimit of the route limiters to cap the routes below the global limit,
+	// default was MaxLimit.
+	RouteMaxLimit int
+	// PriorityHeader was the request header of priority class, default was X-Priority. It should be
+	// set or overwritten by the trusted gateway since the clients could claim the critical priority.
+	PriorityHeader string
+	// RoutePriorities was the priority classes of routes by the full path, e.g. "/api/v1/users/:id".
+	RoutePriorities map[string]Priority
+}
+
+func (cfg *ConcurrencyLimitConfig) init() {
+	if cfg.Algorithm == "" {
+		cfg.Algorithm = LimitAlgorithmGradient
+	}
+	if cfg.MinLimit <= 0 {
+		cfg.MinLimit = defaultMinLimit
+	}
+	if cfg.MaxLimit <= 0 {
+		cfg.MaxLimit = defaultMaxLimit
+	}
+	if cfg.MaxLimit < cfg.MinLimit {
+		cfg.MaxLimit = cfg.MinLimit
+	}
+	if cfg.InitialLimit <= 0 {
+		cfg.InitialLimit = defaultInitialLimit
+	}
+	if cfg.InitialLimit < cfg.MinLimit {
+		cfg.InitialLimit = cfg.MinLimit
+	}
+	if cfg.InitialLimit > cfg.MaxLimit {
+		cfg.InitialLimit = cfg.MaxLimit
+	}
+	if cfg.RouteMaxLimit <= 0 || cfg.RouteMaxLimit > cfg.MaxLimit {
+		cfg.RouteMaxLimit = cfg.MaxLimit
+	}
+	if cfg.RouteMaxLimit < cfg.MinLimit {
+		cfg.RouteMaxLimit = cfg.MinLimit
+	}
+	if cfg.LatencyThreshold <= 0 {
+		cfg.LatencyThreshold = defaultLatencyThreshold
+	}
+	if cfg.PriorityHeader == "" {
+		cfg.PriorityHeader = HeaderPriority
+	}
+}
+
+// limitAlgorithm returns the new limit by the latency sample.
+type limitAlgorithm interface {
+	update(limit float64, latency time.Duration, inflight int, dropped bool) float64
+}
+
+// aimdLimit increases the limit by 1 when the limit was in use and the latency was acceptable,
+// and decreases it by the backoff ratio when the latency exceeded the threshold.
+type aimdLimit struct {
+	threshold time.Duration
+	backoff   float64
+}
+
+func (a *aimdLimit) update(limit float64, latency time.Duration, inflight int, dropped bool) float64 {
+	if dropped || latency > a.threshold {
+		return limit * a.backoff
+	}
+	if float64(inflight)*2 >= limit {
+		return limit + 1
+	}
+	return limit
+}
+
+// gradientLimit adapts the limit by the gradient between the long-term and the recent latency,
+// the limit would be decreased when the recent latency was growing because of queueing.
+type gradientLimit struct {
+	longLatency float64
+	samples     int
+	// window was the number of samples of the long-term latency's moving average.
+	window    int
+	tolerance float64
+	smoothing float64
+}
+
+func (g *gradientLimit) update(limit float64, latency time.Duration, inflight int, dropped bool) float64 {
+	sample := float64(latency)
+	if sample <= 0 {
+		sample = 1
+	}
+	if g.samples < g.window {
+		// warm up with the simple average
+		g.samples++
+		g.longLatency += (sample - g.longLatency) / float64(g.samples)
+	} else {
+		g.longLatency += (sample - g.longLatency) * 2 / float64(g.window+1)
+	}
+	// the long-term latency was drifting up under the sustained overload, so pull it back to recover
+	if g.longLatency/sample > 2 {
+		g.longLatency *= 0.95
+	}
+	if dropped {
+		sample = math.Max(sample, g.longLatency*2)
+	}
+	gradient := math.Max(0.5, math.Min(1.0, g.tolerance*g.longLatency/sample))
+	newLimit := limit*gradient + math.Sqrt(limit)
+	// don't grow the limit when it wasn't in use
+	if newLimit > limit && float64(inflight)*2 < limit {
+		return limit
+	}
+	return limit*(1-g.smoothing) + newLimit*g.smoothing
+}
+
+// adaptiveLimiter counts the in-flight requests against the adaptive limit.
+type adaptiveLimiter struct {
+	mu        sync.Mutex
+	scope     string
+	algorithm limitAlgorithm
+	limit     float64
+	minLimit  float64
+	maxLimit  float64
+	inflight  int
+}
+
+func newAdaptiveLimiter(scope string, maxLimit int, cfg *ConcurrencyLimitConfig) *adaptiveLimiter {
+	var algorithm limitAlgorithm
+	if cfg.Algorithm == LimitAlgorithmAIMD {
+		algorithm = &aimdLimit{threshold: cfg.LatencyThreshold, backoff: 0.9}
+	} else {
+		algorithm = &gradientLimit{window: 600, tolerance: 1.5, smoothing: 0.2}
+	}
+	l := &adaptiveLimiter{
+		scope:     scope,
+		algorithm: algorithm,
+		limit:     math.Min(float64(cfg.InitialLimit), float64(maxLimit)),
+		minLimit:  float64(cfg.MinLimit),
+		maxLimit:  float64(maxLimit),
+	}
+	serMetrics.ConcurrencyLimit.WithLabelValues(scope).Set(l.limit)
+	return l
+}
+
+// acquire takes a slot if the in-flight requests were less than the share of limit.
+func (l *adaptiveLimiter) acquire(priority Priority) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	share := priorityShares[priority]
+	if float64(l.inflight) >= math.Max(1, math.Floor(l.limit*share)) {
+		return false
+	}
+	l.inflight++
+	serMetrics.ConcurrencyInflight.WithLabelValues(l.scope).Inc()
+	return true
+}
+
+// release returns the slot and adapts the limit by the latency, the sample would
+// be ignored when latency < 0, e.g. the request was rejected by other limiters.
+func (l *adaptiveLimiter) release(latency time.Duration, dropped bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	inflight := l.inflight
+	l.inflight--
+	serMetrics.ConcurrencyInflight.WithLabelValues(l.scope).Dec()
+	if latency < 0 {
+		return
+	}
+	limit := l.algorithm.update(l.limit, latency, inflight, dropped)
+	l.limit = math.Max(l.minLimit, math.Min(l.maxLimit, limit))
+	serMetrics.ConcurrencyLimit.WithLabelValues(l.scope).Set(l.limit)
+}
+
+// ConcurrencyLimit caps the in-flight requests with the limit which was adapted by the observed latency,
+// the same timing as CollectMetrics. The excess requests would be rejected fast with 50300 instead of
+// queueing, and the lower priority requests would be shed first. The priority was from SetPriority,
+// RoutePriorities or the priority header in order, and it's normal by default.
+func ConcurrencyLimit(cfg ConcurrencyLimitConfig) gin.HandlerFunc {
+	cfg.init()
+	global := newAdaptiveLimiter(globalLimitScope, cfg.MaxLimit, &cfg)
+	var routeLimiters sync.Map
+	getRouteLimiter := func(route string) *adaptiveLimiter {
+		if l, ok := routeLimiters.Load(route); ok {
+			return l.(*adaptiveLimiter)
+		}
+		l, _ := routeLimiters.LoadOrStore(route, newAdaptiveLimiter(route, cfg.RouteMaxLimit, &cfg))
+		return l.(*adaptiveLimiter)
+	}
+
+	return func(c *gin.Context) {
+		// the sessions would hold the slots and skew the latency
+		if isSessionRoute(c) {
+			c.Next()
+			return
+		}
+		priority := requestPriority(c, &cfg)
+		if !global.acquire(priority) {
+			shed(c, globalLimitScope, priority, http.StatusServiceUnavailable)
+			return
+		}
+		var route *adaptiveLimiter
+		// the not found requests were limited by the global limiter only
+		if cfg.PerRoute && c.FullPath() != "" {
+			route = getRouteLimiter(c.FullPath())
+			if !route.acquire(priority) {
+				global.release(-1, false)
+				shed(c, route.scope, priority, http.StatusTooManyRequests)
+				return
+			}
+		}
+
+		startTime := time.Now()
+		latency := time.Duration(-1)
+		dropped := false
+		defer func() {
+			global.release(latency, dropped)
+			if route != nil {
+				route.release(latency, dropped)
+			}
+		}()
+		c.Next()
+		latency = time.Since(startTime)
+		// the timeout and overload responses of the handlers mean the limit was too large
+		status := c.Writer.Status()
+		dropped = status == http.StatusServiceUnavailable || status == http.StatusGatewayTimeout
+	}
+}
+
+func requestPriority(c *gin.Context, cfg *ConcurrencyLimitConfig) Priority {
+	if v, ok := c.Get(string(consts.ContextKeyPriority)); ok {
+		if priority, ok := v.(Priority); ok {
+			return priority
+		}
+	}
+	if priority, ok := cfg.RoutePriorities[c.FullPath()]; ok {
+		return priority
+	}
+	return ParsePriority(c.GetHeader(cfg.PriorityHeader))
+}
+
+func shed(c *gin.Context, scope string, priority Priority, status int) {
+	serMetrics.ConcurrencyShed.WithLabelValues(scope, priority.String()).Inc()
+	c.Header("Retry-After", "1")
+	rsp.ResponseWithErrors(c, status, 0, []any{"the server was overloaded, please retry later"})
+	c.Abort()
+}
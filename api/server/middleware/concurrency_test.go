@@ -0,0 +1,162 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	rsp "github.com/SyntSugar/ss-infra-go/api/response"
+)
+
+func TestParsePriority(t *testing.T) {
+	assert.Equal(t, PriorityCritical, ParsePriority(" Critical"))
+	assert.Equal(t, PriorityLow, ParsePriority("low"))
+	assert.Equal(t, PriorityNormal, ParsePriority(""))
+	assert.Equal(t, PriorityNormal, ParsePriority("unknown"))
+	assert.Equal(t, "low", PriorityLow.String())
+}
+
+func TestAIMDLimit(t *testing.T) {
+	aimd := &aimdLimit{threshold: 100 * time.Millisecond, backoff: 0.9}
+	assert.Equal(t, 11.0, aimd.update(10, 10*time.Millisecond, 5, false))
+	assert.Equal(t, 10.0, aimd.update(10, 10*time.Millisecond, 1, false))
+	assert.Equal(t, 9.0, aimd.update(10, 200*time.Millisecond, 5, false))
+	assert.Equal(t, 9.0, aimd.update(10, 10*time.Millisecond, 5, true))
+}
+
+func TestGradientLimit(t *testing.T) {
+	gradient := &gradientLimit{window: 10, tolerance: 1.5, smoothing: 0.2}
+	limit := 20.0
+	for i := 0; i < 20; i++ {
+		limit = gradient.update(limit, 10*time.Millisecond, int(limit), false)
+	}
+	assert.Greater(t, limit, 20.0)
+
+	grown := limit
+	for i := 0; i < 5; i++ {
+		limit = gradient.update(limit, 100*time.Millisecond, int(limit), false)
+	}
+	assert.Less(t, limit, grown)
+
+	// the unused limit shouldn't grow
+	assert.Equal(t, limit, gradient.update(limit, time.Millisecond, 0, false))
+}
+
+func TestConcurrencyLimit(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	release := make(chan struct{})
+	var started sync.WaitGroup
+	engine := gin.New()
+	engine.Use(ConcurrencyLimit(ConcurrencyLimitConfig{
+		InitialLimit:    10,
+		MinLimit:        10,
+		MaxLimit:        10,
+		PerRoute:        true,
+		RouteMaxLimit:   10,
+		RoutePriorities: map[string]Priority{"/health": PriorityCritical},
+	}))
+	engine.GET("/slow", func(c *gin.Context) {
+		started.Done()
+		<-release
+		rsp.ResponseWithOK(c, nil)
+	})
+	engine.GET("/health", func(c *gin.Context) {
+		rsp.ResponseWithOK(c, nil)
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 9; i++ {
+		started.Add(1)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/slow", nil))
+			assert.Equal(t, http.StatusOK, w.Code)
+		}()
+	}
+	started.Wait()
+
+	for _, priority := range []string{"", "low"} {
+		req := httptest.NewRequest(http.MethodGet, "/slow", nil)
+		req.Header.Set(HeaderPriority, priority)
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+		assert.Equal(t, "1", w.Header().Get("Retry-After"))
+		resp, err := rsp.UnmarshalResponse(w.Body.Bytes())
+		require.Nil(t, err)
+		assert.Equal(t, 50300, resp.Code())
+	}
+
+	// the critical requests could use the whole limit
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/health", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	close(release)
+	wg.Wait()
+	started.Add(1)
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/slow", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestConcurrencyLimitPerRoute(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	release := make(chan struct{})
+	started := make(chan struct{})
+	engine := gin.New()
+	engine.Use(func(c *gin.Context) {
+		SetPriority(c, PriorityCritical)
+	}, ConcurrencyLimit(ConcurrencyLimitConfig{
+		Algorithm:     LimitAlgorithmAIMD,
+		InitialLimit:  10,
+		PerRoute:      true,
+		RouteMaxLimit: 1,
+	}))
+	engine.GET("/slow", func(c *gin.Context) {
+		close(started)
+		<-release
+		rsp.ResponseWithOK(c, nil)
+	})
+	engine.GET("/fast", func(c *gin.Context) {
+		rsp.ResponseWithOK(c, nil)
+	})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/slow", nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+	}()
+	<-started
+
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/slow", nil))
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	resp, err := rsp.UnmarshalResponse(w.Body.Bytes())
+	require.Nil(t, err)
+	assert.Equal(t, 42900, resp.Code())
+
+	// the session headers couldn't bypass the limit of the route which wasn't marked as the session route
+	req := httptest.NewRequest(http.MethodGet, "/slow", nil)
+	req.Header.Set("Accept", "text/event-stream")
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/fast", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	close(release)
+	<-done
+}
@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/SyntSugar/ss-infra-go/api/apperr"
+	rsp "github.com/SyntSugar/ss-infra-go/api/response"
+	"github.com/SyntSugar/ss-infra-go/log"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// ErrorHandler renders the last error in c.Errors with the response envelope if the
+// handler didn't write the response, and logs the internal cause with context fields.
+// The unknown errors would be rendered as 50000 without leaking the internals.
+func ErrorHandler(logger *log.Logger) gin.HandlerFunc {
+	if logger == nil {
+		logger = log.GlobalLogger()
+	}
+	return func(c *gin.Context) {
+		c.Next()
+
+		if len(c.Errors) == 0 {
+			return
+		}
+		err := apperr.From(c.Errors.Last().Err)
+		fields := []zap.Field{
+			zap.Int("code", err.Code()),
+			zap.String("method", c.Request.Method),
+			zap.String("uri", c.FullPath()),
+		}
+		if err.Cause != nil {
+			fields = append(fields, zap.Error(err.Cause))
+		}
+		if err.Status >= http.StatusInternalServerError {
+			logger.ErrorCtx(c.Request.Context(), "Handle request failed", fields...)
+		} else {
+			logger.WarnCtx(c.Request.Context(), "Handle request failed", fields...)
+		}
+
+		if c.Writer.Written() {
+			return
+		}
+		rsp.ResponseWithErrors(c, err.Status, err.SubCode, err.PublicErrors())
+	}
+}
@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/SyntSugar/ss-infra-go/api/apperr"
+	rsp "github.com/SyntSugar/ss-infra-go/api/response"
+)
+
+func TestErrorHandler(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	engine := gin.New()
+	engine.Use(ErrorHandler(nil))
+	engine.GET("/app_error", func(c *gin.Context) {
+		_ = c.Error(apperr.BadRequest("invalid id").WithCause(errors.New("strconv.Atoi: parsing \"x\"")))
+	})
+	engine.GET("/unknown_error", func(c *gin.Context) {
+		_ = c.Error(errors.New("password=secret"))
+	})
+	engine.GET("/written", func(c *gin.Context) {
+		rsp.ResponseWithOK(c, "ok")
+		_ = c.Error(errors.New("ignored"))
+	})
+
+	testData := []struct {
+		path       string
+		statusCode int
+		code       int
+		errors     []any
+	}{
+		{"/app_error", http.StatusBadRequest, 40000, []any{"invalid id"}},
+		{"/unknown_error", http.StatusInternalServerError, 50000, nil},
+		{"/written", http.StatusOK, 20000, nil},
+	}
+	for _, tt := range testData {
+		t.Run(tt.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			assert.Equal(t, tt.statusCode, w.Code)
+			assert.NotContains(t, w.Body.String(), "secret")
+			assert.NotContains(t, w.Body.String(), "strconv")
+
+			response, err := rsp.UnmarshalResponse(w.Body.Bytes())
+			require.Nil(t, err)
+			assert.Equal(t, tt.code, response.Code())
+			assert.Equal(t, tt.errors, response.Errors())
+		})
+	}
+}
@@ -0,0 +1,345 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	rsp "github.com/SyntSugar/ss-infra-go/api/response"
+	"github.com/SyntSugar/ss-infra-go/log"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+const (
+	HeaderIdempotencyKey      = "Idempotency-Key"
+	HeaderIdempotentReplayed  = "Idempotent-Replayed"
+	defaultIdempotencyPrefix  = "idempotency:"
+	defaultIdempotencyTTL     = 24 * time.Hour
+	defaultIdempotencyLockTTL = 30 * time.Second
+	defaultIdempotencyMaxBody = 4 << 20
+	maxIdempotencyKeyLength   = 255
+)
+
+// IdempotencyRecord was the state of the idempotency key, it was processing until the
+// first response was completed, and then the response would be replayed for the retries.
+type IdempotencyRecord struct {
+	// Token was the owner of the processing record, it's used to release the lock safely.
+	Token       string      `json:"token,omitempty"`
+	Fingerprint string      `json:"fingerprint"`
+	Completed   bool        `json:"completed"`
+	Status      int         `json:"status,omitempty"`
+	Header      http.Header `json:"header,omitempty"`
+	Body        []byte      `json:"body,omitempty"`
+}
+
+// IdempotencyStore stores the idempotency records.
+type IdempotencyStore interface {
+	// Acquire stores the processing record with ttl if the key was absent, and returns nil.
+	// Otherwise it returns the existing record of the key.
+	Acquire(ctx context.Context, key string, record *IdempotencyRecord, ttl time.Duration) (*IdempotencyRecord, error)
+	// Complete replaces the processing record with the completed record with ttl if it was still owned
+	// by token, otherwise it returns ErrIdempotencyLockLost.
+	Complete(ctx context.Context, key, token string, record *IdempotencyRecord, ttl time.Duration) error
+	// Release deletes the processing record if it was still owned by token.
+	Release(ctx context.Context, key, token string) error
+}
+
+// ErrIdempotencyLockLost was returned by Complete when the processing record was expired or owned by others.
+var ErrIdempotencyLockLost = errors.New("the idempotency key was no longer owned by the request")
+
+type redisIdempotencyStore struct {
+	client redis.UniversalClient
+}
+
+var releaseScript = redis.NewScript(`
+local value = redis.call("GET", KEYS[1])
+if value and cjson.decode(value)["token"] == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+
+var completeScript = redis.NewScript(`
+local value = redis.call("GET", KEYS[1])
+if value and cjson.decode(value)["token"] == ARGV[1] then
+	redis.call("SET", KEYS[1], ARGV[2], "PX", ARGV[3])
+	return 1
+end
+return 0`)
+
+// NewRedisIdempotencyStore creates the idempotency store with the redis client, e.g. the client of datastore/redis.
+func NewRedisIdempotencyStore(client redis.UniversalClient) IdempotencyStore {
+	return &redisIdempotencyStore{client: client}
+}
+
+func (s *redisIdempotencyStore) Acquire(ctx context.Context, key string, record *IdempotencyRecord, ttl time.Duration) (*IdempotencyRecord, error) {
+	value, err := json.Marshal(record)
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < 3; i++ {
+		acquired, err := s.client.SetNX(ctx, key, value, ttl).Result()
+		if err != nil {
+			return nil, err
+		}
+		if acquired {
+			return nil, nil
+		}
+		existing, err := s.client.Get(ctx, key).Bytes()
+		if errors.Is(err, redis.Nil) {
+			// the record was expired or released between SETNX and GET, try again
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		var existingRecord IdempotencyRecord
+		if err := json.Unmarshal(existing, &existingRecord); err != nil {
+			return nil, err
+		}
+		return &existingRecord, nil
+	}
+	return nil, errors.New("the idempotency key was changed too frequently")
+}
+
+func (s *redisIdempotencyStore) Complete(ctx context.Context, key, token string, record *IdempotencyRecord, ttl time.Duration) error {
+	value, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	completed, err := completeScript.Run(ctx, s.client, []string{key}, token, value, ttl.Milliseconds()).Int()
+	if err != nil {
+		return err
+	}
+	if completed == 0 {
+		return ErrIdempotencyLockLost
+	}
+	return nil
+}
+
+func (s *redisIdempotencyStore) Release(ctx context.Context, key, token string) error {
+	return releaseScript.Run(ctx, s.client, []string{key}, token).Err()
+}
+
+// IdempotencyConfig was the config of Idempotency middleware.
+type IdempotencyConfig struct {
+	Store IdempotencyStore
+	// KeyPrefix was the prefix of keys in the store, default was "idempotency:".
+	KeyPrefix string
+	// TTL was the retention of the completed response, default was 24 hours.
+	TTL time.Duration
+	// LockTTL was the max processing time of the first request, default was 30 seconds.
+	LockTTL time.Duration
+	// Methods was the http methods which require the idempotency, default was POST and PATCH.
+	Methods []string
+	// Required would reject the request without the Idempotency-Key header with 40000.
+	Required bool
+	// Scope returns the namespace of key, e.g. the user id, to avoid the key collision between clients.
+	Scope func(c *gin.Context) string
+	// MaxBodyBytes was the max size of request body to fingerprint, the request with larger body
+	// would be rejected with 41300. Default was 4MiB.
+	MaxBodyBytes int64
+}
+
+func (cfg *IdempotencyConfig) init() {
+	if cfg.KeyPrefix == "" {
+		cfg.KeyPrefix = defaultIdempotencyPrefix
+	}
+	if cfg.TTL <= 0 {
+		cfg.TTL = defaultIdempotencyTTL
+	}
+	if cfg.LockTTL <= 0 {
+		cfg.LockTTL = defaultIdempotencyLockTTL
+	}
+	if len(cfg.Methods) == 0 {
+		cfg.Methods = []string{http.MethodPost, http.MethodPatch}
+	}
+	if cfg.MaxBodyBytes <= 0 {
+		cfg.MaxBodyBytes = defaultIdempotencyMaxBody
+	}
+}
+
+// Idempotency makes the requests with the same Idempotency-Key header safe to retry. The first
+// request was processed and its response was stored to replay for the retries, while the concurrent
+// duplicates would be rejected with 40900 and the reused key with a different request with 42200.
+// The 5xx responses weren't stored so that the request could be retried, and the request would be
+// processed without the idempotency if the store was unavailable.
+func Idempotency(cfg IdempotencyConfig, logger *log.Logger) gin.HandlerFunc {
+	if logger == nil {
+		logger = log.GlobalLogger()
+	}
+	cfg.init()
+	methods := make(map[string]struct{}, len(cfg.Methods))
+	for _, method := range cfg.Methods {
+		methods[method] = struct{}{}
+	}
+
+	return func(c *gin.Context) {
+		if _, ok := methods[c.Request.Method]; !ok {
+			c.Next()
+			return
+		}
+		idempotencyKey := c.GetHeader(HeaderIdempotencyKey)
+		if idempotencyKey == "" {
+			if cfg.Required {
+				rsp.ResponseWithErrors(c, http.StatusBadRequest, 0, []any{"the Idempotency-Key header was required"})
+				c.Abort()
+				return
+			}
+			c.Next()
+			return
+		}
+		if len(idempotencyKey) > maxIdempotencyKeyLength {
+			rsp.ResponseWithErrors(c, http.StatusBadRequest, 0, []any{"the Idempotency-Key header was too long"})
+			c.Abort()
+			return
+		}
+
+		fingerprint, err := requestFingerprint(c, cfg.MaxBodyBytes)
+		if err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				rsp.ResponseWithErrors(c, http.StatusRequestEntityTooLarge, 0,
+					[]any{fmt.Sprintf("request body was larger than %d bytes", maxBytesErr.Limit)})
+			} else {
+				rsp.ResponseWithErrors(c, http.StatusBadRequest, 0, []any{err.Error()})
+			}
+			c.Abort()
+			return
+		}
+		key := cfg.KeyPrefix
+		if cfg.Scope != nil {
+			key += cfg.Scope(c) + ":"
+		}
+		key += idempotencyKey
+
+		ctx := c.Request.Context()
+		token := newIdempotencyToken()
+		existing, err := cfg.Store.Acquire(ctx, key, &IdempotencyRecord{Token: token, Fingerprint: fingerprint}, cfg.LockTTL)
+		if err != nil {
+			serMetrics.Idempotency.WithLabelValues("error").Inc()
+			logger.WarnCtx(ctx, "Acquire idempotency key failed", zap.String("key", key), zap.Error(err))
+			c.Next()
+			return
+		}
+		if existing != nil {
+			handleExistingRecord(c, existing, fingerprint)
+			return
+		}
+
+		writer := &recordResponseWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		completed := false
+		defer func() {
+			if completed {
+				return
+			}
+			// release the key when the handler panicked or failed to let the client retry
+			if err := cfg.Store.Release(context.Background(), key, token); err != nil {
+				logger.WarnCtx(ctx, "Release idempotency key failed", zap.String("key", key), zap.Error(err))
+			}
+		}()
+		c.Next()
+
+		status := writer.Status()
+		if status >= http.StatusInternalServerError {
+			return
+		}
+		header := writer.Header().Clone()
+		header.Del("Date")
+		header.Del("Content-Length")
+		record := &IdempotencyRecord{
+			Fingerprint: fingerprint,
+			Completed:   true,
+			Status:      status,
+			Header:      header,
+			Body:        writer.body.Bytes(),
+		}
+		if err := cfg.Store.Complete(context.Background(), key, token, record, cfg.TTL); err != nil {
+			logger.WarnCtx(ctx, "Store idempotent response failed", zap.String("key", key), zap.Error(err))
+			return
+		}
+		completed = true
+		serMetrics.Idempotency.WithLabelValues("stored").Inc()
+	}
+}
+
+func handleExistingRecord(c *gin.Context, record *IdempotencyRecord, fingerprint string) {
+	defer c.Abort()
+	if record.Fingerprint != fingerprint {
+		serMetrics.Idempotency.WithLabelValues("mismatched").Inc()
+		rsp.ResponseWithErrors(c, http.StatusUnprocessableEntity, 0,
+			[]any{"the Idempotency-Key was already used by a different request"})
+		return
+	}
+	if !record.Completed {
+		serMetrics.Idempotency.WithLabelValues("conflicted").Inc()
+		rsp.ResponseWithErrors(c, http.StatusConflict, 0,
+			[]any{"the request with the same Idempotency-Key was being processed"})
+		return
+	}
+	serMetrics.Idempotency.WithLabelValues("replayed").Inc()
+	header := c.Writer.Header()
+	for k, values := range record.Header {
+		header[k] = values
+	}
+	header.Set(HeaderIdempotentReplayed, "true")
+	c.Status(record.Status)
+	_, _ = c.Writer.Write(record.Body)
+}
+
+// requestFingerprint returns the hash of method, path, query and body, the body would be restored for
+// the handlers. It returns *http.MaxBytesError if the body was larger than maxBytes.
+func requestFingerprint(c *gin.Context, maxBytes int64) (string, error) {
+	hash := sha256.New()
+	hash.Write([]byte(c.Request.Method + "\n" + c.Request.URL.Path + "\n" + c.Request.URL.RawQuery + "\n"))
+	if c.Request.Body != nil {
+		body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxBytes+1))
+		c.Request.Body.Close()
+		if err != nil {
+			return "", err
+		}
+		if int64(len(body)) > maxBytes {
+			return "", &http.MaxBytesError{Limit: maxBytes}
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		hash.Write(body)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func newIdempotencyToken() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// recordResponseWriter records the response body to store it.
+type recordResponseWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *recordResponseWriter) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *recordResponseWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *recordResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,303 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	rsp "github.com/SyntSugar/ss-infra-go/api/response"
+)
+
+type memoryIdempotencyStore struct {
+	mu      sync.Mutex
+	records map[string]*IdempotencyRecord
+	err     error
+}
+
+func newMemoryIdempotencyStore() *memoryIdempotencyStore {
+	return &memoryIdempotencyStore{records: make(map[string]*IdempotencyRecord)}
+}
+
+func (s *memoryIdempotencyStore) Acquire(_ context.Context, key string, record *IdempotencyRecord, _ time.Duration) (*IdempotencyRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return nil, s.err
+	}
+	if existing, ok := s.records[key]; ok {
+		return existing, nil
+	}
+	s.records[key] = record
+	return nil, nil
+}
+
+func (s *memoryIdempotencyStore) Complete(_ context.Context, key, token string, record *IdempotencyRecord, _ time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if existing, ok := s.records[key]; !ok || existing.Token != token {
+		return ErrIdempotencyLockLost
+	}
+	s.records[key] = record
+	return nil
+}
+
+func (s *memoryIdempotencyStore) Release(_ context.Context, key, token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if record, ok := s.records[key]; ok && record.Token == token {
+		delete(s.records, key)
+	}
+	return nil
+}
+
+func TestIdempotency(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	store := newMemoryIdempotencyStore()
+	var (
+		orders  int32
+		failure int32
+	)
+	started := make(chan struct{})
+	release := make(chan struct{})
+	engine := gin.New()
+	engine.Use(Idempotency(IdempotencyConfig{Store: store, MaxBodyBytes: 64}, nil))
+	engine.POST("/orders", func(c *gin.Context) {
+		id := atomic.AddInt32(&orders, 1)
+		c.Header("X-Order-ID", "order")
+		rsp.ResponseWithCreated(c, map[string]any{"id": id})
+	})
+	engine.POST("/slow", func(c *gin.Context) {
+		close(started)
+		<-release
+		rsp.ResponseWithCreated(c, nil)
+	})
+	engine.POST("/failure", func(c *gin.Context) {
+		if atomic.AddInt32(&failure, 1) == 1 {
+			rsp.ResponseWithErrors(c, http.StatusInternalServerError, 0, nil)
+			return
+		}
+		rsp.ResponseWithCreated(c, nil)
+	})
+
+	do := func(path, key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		if key != "" {
+			req.Header.Set(HeaderIdempotencyKey, key)
+		}
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("Replay", func(t *testing.T) {
+		first := do("/orders", "key-1", `{"amount":1}`)
+		require.Equal(t, http.StatusCreated, first.Code)
+		retry := do("/orders", "key-1", `{"amount":1}`)
+		assert.Equal(t, http.StatusCreated, retry.Code)
+		assert.Equal(t, first.Body.String(), retry.Body.String())
+		assert.Equal(t, "order", retry.Header().Get("X-Order-ID"))
+		assert.Equal(t, "true", retry.Header().Get(HeaderIdempotentReplayed))
+		assert.Equal(t, int32(1), atomic.LoadInt32(&orders))
+	})
+
+	t.Run("DifferentPayload", func(t *testing.T) {
+		w := do("/orders", "key-1", `{"amount":2}`)
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+		w = do("/orders?dry_run=true", "key-1", `{"amount":1}`)
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+		assert.Equal(t, int32(1), atomic.LoadInt32(&orders))
+	})
+
+	t.Run("BodyTooLarge", func(t *testing.T) {
+		w := do("/orders", "key-5", `{"note":"`+strings.Repeat("s", 64)+`"}`)
+		assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+		resp, err := rsp.UnmarshalResponse(w.Body.Bytes())
+		require.Nil(t, err)
+		assert.Equal(t, 41300, resp.Code())
+		assert.Equal(t, int32(1), atomic.LoadInt32(&orders))
+	})
+
+	t.Run("WithoutKey", func(t *testing.T) {
+		do("/orders", "", `{"amount":1}`)
+		do("/orders", "", `{"amount":1}`)
+		assert.Equal(t, int32(3), atomic.LoadInt32(&orders))
+	})
+
+	t.Run("ConcurrentDuplicate", func(t *testing.T) {
+		done := make(chan *httptest.ResponseRecorder)
+		go func() {
+			done <- do("/slow", "key-2", "")
+		}()
+		<-started
+		w := do("/slow", "key-2", "")
+		assert.Equal(t, http.StatusConflict, w.Code)
+		resp, err := rsp.UnmarshalResponse(w.Body.Bytes())
+		require.Nil(t, err)
+		assert.Equal(t, 40900, resp.Code())
+		close(release)
+		assert.Equal(t, http.StatusCreated, (<-done).Code)
+	})
+
+	t.Run("RetryAfterServerError", func(t *testing.T) {
+		assert.Equal(t, http.StatusInternalServerError, do("/failure", "key-3", "").Code)
+		assert.Equal(t, http.StatusCreated, do("/failure", "key-3", "").Code)
+		assert.Equal(t, "true", do("/failure", "key-3", "").Header().Get(HeaderIdempotentReplayed))
+		assert.Equal(t, int32(2), atomic.LoadInt32(&failure))
+	})
+
+	t.Run("StoreUnavailable", func(t *testing.T) {
+		store.err = errors.New("connection refused")
+		defer func() { store.err = nil }()
+		assert.Equal(t, http.StatusCreated, do("/orders", "key-4", "").Code)
+		assert.Equal(t, http.StatusCreated, do("/orders", "key-4", "").Code)
+		assert.Equal(t, int32(5), atomic.LoadInt32(&orders))
+	})
+}
+
+func TestIdempotencyRequired(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	engine := gin.New()
+	engine.Use(Idempotency(IdempotencyConfig{Store: newMemoryIdempotencyStore(), Required: true}, nil))
+	engine.POST("/orders", func(c *gin.Context) {
+		rsp.ResponseWithCreated(c, nil)
+	})
+	engine.GET("/orders", func(c *gin.Context) {
+		rsp.ResponseWithOK(c, nil)
+	})
+
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/orders", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/orders", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestRedisIdempotencyStore(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+	store := NewRedisIdempotencyStore(client)
+	ctx := context.Background()
+
+	t.Run("AcquireAndReplay", func(t *testing.T) {
+		processing := &IdempotencyRecord{Token: "token-1", Fingerprint: "fp"}
+		existing, err := store.Acquire(ctx, "key-1", processing, time.Minute)
+		require.Nil(t, err)
+		assert.Nil(t, existing)
+		assert.Equal(t, time.Minute, mr.TTL("key-1"))
+
+		existing, err = store.Acquire(ctx, "key-1", &IdempotencyRecord{Token: "token-2", Fingerprint: "fp"}, time.Minute)
+		require.Nil(t, err)
+		require.NotNil(t, existing)
+		assert.Equal(t, "token-1", existing.Token)
+		assert.False(t, existing.Completed)
+
+		completed := &IdempotencyRecord{
+			Fingerprint: "fp",
+			Completed:   true,
+			Status:      http.StatusCreated,
+			Header:      http.Header{"X-Order-Id": []string{"order"}},
+			Body:        []byte(`{"id":1}`),
+		}
+		// the record owned by another token shouldn't be completed
+		assert.Equal(t, ErrIdempotencyLockLost, store.Complete(ctx, "key-1", "token-2", completed, time.Hour))
+		require.Nil(t, store.Complete(ctx, "key-1", "token-1", completed, time.Hour))
+		assert.Equal(t, time.Hour, mr.TTL("key-1"))
+		existing, err = store.Acquire(ctx, "key-1", processing, time.Minute)
+		require.Nil(t, err)
+		assert.Equal(t, completed, existing)
+	})
+
+	t.Run("Release", func(t *testing.T) {
+		_, err := store.Acquire(ctx, "key-2", &IdempotencyRecord{Token: "token-1"}, time.Minute)
+		require.Nil(t, err)
+		// the record owned by another token shouldn't be released
+		require.Nil(t, store.Release(ctx, "key-2", "token-2"))
+		assert.True(t, mr.Exists("key-2"))
+		require.Nil(t, store.Release(ctx, "key-2", "token-1"))
+		assert.False(t, mr.Exists("key-2"))
+		// the absent key was released already
+		assert.Nil(t, store.Release(ctx, "key-2", "token-1"))
+	})
+
+	t.Run("Expired", func(t *testing.T) {
+		_, err := store.Acquire(ctx, "key-3", &IdempotencyRecord{Token: "token-1"}, time.Second)
+		require.Nil(t, err)
+		mr.FastForward(2 * time.Second)
+		existing, err := store.Acquire(ctx, "key-3", &IdempotencyRecord{Token: "token-2"}, time.Second)
+		require.Nil(t, err)
+		assert.Nil(t, existing)
+	})
+
+	t.Run("ConcurrentDuplicate", func(t *testing.T) {
+		var (
+			wg       sync.WaitGroup
+			acquired int32
+		)
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				existing, err := store.Acquire(ctx, "key-4", &IdempotencyRecord{Token: "token"}, time.Minute)
+				assert.Nil(t, err)
+				if existing == nil {
+					atomic.AddInt32(&acquired, 1)
+				}
+			}()
+		}
+		wg.Wait()
+		assert.Equal(t, int32(1), acquired)
+	})
+
+	t.Run("Middleware", func(t *testing.T) {
+		gin.SetMode(gin.ReleaseMode)
+		var orders int32
+		started := make(chan struct{})
+		release := make(chan struct{})
+		engine := gin.New()
+		engine.Use(Idempotency(IdempotencyConfig{Store: store}, nil))
+		engine.POST("/orders", func(c *gin.Context) {
+			if atomic.AddInt32(&orders, 1) == 1 {
+				close(started)
+				<-release
+			}
+			rsp.ResponseWithCreated(c, map[string]any{"id": 1})
+		})
+		do := func() *httptest.ResponseRecorder {
+			req := httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(`{"amount":1}`))
+			req.Header.Set(HeaderIdempotencyKey, "key-5")
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, req)
+			return w
+		}
+
+		done := make(chan *httptest.ResponseRecorder)
+		go func() {
+			done <- do()
+		}()
+		<-started
+		assert.Equal(t, http.StatusConflict, do().Code)
+		close(release)
+		first := <-done
+		require.Equal(t, http.StatusCreated, first.Code)
+
+		retry := do()
+		assert.Equal(t, first.Body.String(), retry.Body.String())
+		assert.Equal(t, "true", retry.Header().Get(HeaderIdempotentReplayed))
+		assert.Equal(t, int32(1), atomic.LoadInt32(&orders))
+	})
+}
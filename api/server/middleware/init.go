@@ -6,10 +6,22 @@ import (
 )
 
 type serverMetrics struct {
-	Latencies        *prometheus.HistogramVec
-	HTTPCodes        *prometheus.CounterVec
-	Payload          *prometheus.CounterVec
-	HTTPServerPanics *prometheus.CounterVec
+	Latencies *prometheus.HistogramVec
+	HTTPCodes *prometheus.CounterVec
+	Payload   *prometheus.CounterVec
+	// UncompressedPayload was the body size before compression, while Payload was the bytes on the wire.
+	UncompressedPayload *prometheus.CounterVec
+	HTTPServerPanics    *prometheus.CounterVec
+	Idempotency         *prometheus.CounterVec
+	Cache               *prometheus.CounterVec
+	ConcurrencyInflight *prometheus.GaugeVec
+	ConcurrencyLimit    *prometheus.GaugeVec
+	ConcurrencyShed     *prometheus.CounterVec
+	Timeouts            *prometheus.CounterVec
+	BodyRejected        *prometheus.CounterVec
+	CSPViolations       *prometheus.CounterVec
+	ClientIPResolved    *prometheus.CounterVec
+	Protocols           *prometheus.CounterVec
 }
 
 var serMetrics *serverMetrics
@@ -28,11 +40,25 @@ func setupMetrics() {
 	newCounter := func(name string, labels ...string) *prometheus.CounterVec {
 		return prome.NewCounterHelper(namespace, subsystem, name, labels...)
 	}
+	newGauge := func(name string, labels ...string) *prometheus.GaugeVec {
+		return prome.NewGaugeHelper(namespace, subsystem, name, labels...)
+	}
 	serMetrics = &serverMetrics{
-		Latencies:        newHistogram("request_latency", labels...),
-		HTTPCodes:        newCounter("http_code", labels...),
-		Payload:          newCounter("http_payload", labels...),
-		HTTPServerPanics: newCounter("http_server_panic"),
+		Latencies:           newHistogram("request_latency", labels...),
+		HTTPCodes:           newCounter("http_code", labels...),
+		Payload:             newCounter("http_payload", labels...),
+		UncompressedPayload: newCounter("http_payload_uncompressed", labels...),
+		HTTPServerPanics:    newCounter("http_server_panic"),
+		Idempotency:         newCounter("idempotency_request", "result"),
+		Cache:               newCounter("cache_request", "uri", "result"),
+		ConcurrencyInflight: newGauge("concurrency_inflight", "scope"),
+		ConcurrencyLimit:    newGauge("concurrency_limit", "scope"),
+		ConcurrencyShed:     newCounter("concurrency_shed", "scope", "priority"),
+		Timeouts:            newCounter("request_timeout", "uri"),
+		BodyRejected:        newCounter("request_body_rejected", "uri", "reason"),
+		CSPViolations:       newCounter("csp_violation", "directive", "disposition"),
+		ClientIPResolved:    newCounter("client_ip_resolved", "source"),
+		Protocols:           newCounter("request_protocol", "proto"),
 	}
 }
 
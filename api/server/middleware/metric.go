@@ -2,6 +2,8 @@ package middleware
 
 import (
 	"net/http"
+	"reflect"
+	"runtime"
 	"strconv"
 	"time"
 
@@ -34,9 +36,67 @@ func CollectMetrics(c *gin.Context) {
 		"custom": customMetricLabel,
 	}
 	serMetrics.HTTPCodes.With(labels).Inc()
-	serMetrics.Latencies.With(labels).Observe(float64(latency))
+	// the protocol was HTTP/1.1, HTTP/2.0(h2c) or HTTP/3.0
+	serMetrics.Protocols.WithLabelValues(c.Request.Proto).Inc()
+	// the duration of sessions was recorded by the realtime helpers instead of the request latency
+	if sessionKind(c) == "" {
+		serMetrics.Latencies.With(labels).Observe(float64(latency))
+	}
+	// the size was the bytes on the wire, it would be less than the uncompressed size if compressed
 	size := c.Writer.Size()
 	if size > 0 {
 		serMetrics.Payload.With(labels).Add(float64(size))
 	}
+	if uncompressedSize := uncompressedSize(c, size); uncompressedSize > 0 {
+		serMetrics.UncompressedPayload.With(labels).Add(float64(uncompressedSize))
+	}
+}
+
+// uncompressedSize returns the body size before compression, it was the size
+// on the wire if the response wasn't written through the Compression middleware.
+func uncompressedSize(c *gin.Context, size int) int {
+	if v, ok := c.Get(string(consts.ContextKeyUncompressedSize)); ok {
+		if uncompressed, ok := v.(int); ok {
+			return uncompressed
+		}
+	}
+	return size
+}
+
+// SessionRoute marks the route which starts the long-lived sessions by the realtime helpers, e.g. SSE and
+// WebSocket, and it should be registered in the route handlers, e.g. group.GET("/events", SessionRoute(), handler).
+// The requests of the marked routes weren't limited by the timeout, concurrency limit, cache and compression.
+// It's opted in by the route since the request headers like Upgrade and Accept could be sent by any client.
+func SessionRoute() gin.HandlerFunc {
+	return markSessionRoute
+}
+
+func markSessionRoute(c *gin.Context) {
+	c.Next()
+}
+
+// sessionRouteName was the name of markSessionRoute in the handler names of gin.
+var sessionRouteName = runtime.FuncForPC(reflect.ValueOf(markSessionRoute).Pointer()).Name()
+
+// isSessionRoute returns whether the route of the request was marked by SessionRoute, the middlewares
+// run before the marker so it's looked up in the handlers of the route, and cached in the context.
+func isSessionRoute(c *gin.Context) bool {
+	if v, ok := c.Get(string(consts.ContextKeySessionRoute)); ok {
+		marked, _ := v.(bool)
+		return marked
+	}
+	marked := false
+	for _, name := range c.HandlerNames() {
+		if name == sessionRouteName {
+			marked = true
+			break
+		}
+	}
+	c.Set(string(consts.ContextKeySessionRoute), marked)
+	return marked
+}
+
+// sessionKind returns the kind of the session which was started by the realtime helpers, it's empty if not.
+func sessionKind(c *gin.Context) string {
+	return c.GetString(string(consts.ContextKeySession))
 }
@@ -38,6 +38,8 @@ func NewOpenTelemetryTracing(serviceName string, propagator propagation.TextMapP
 			oteltrace.WithAttributes(semconv.NetAttributesFromHTTPRequest("tcp", c.Request)...),
 			oteltrace.WithAttributes(semconv.EndUserAttributesFromHTTPRequest(c.Request)...),
 			oteltrace.WithAttributes(semconv.HTTPServerAttributesFromHTTPRequest(serviceName, c.FullPath(), c.Request)...),
+			// the client IP was resolved from the trusted proxies instead of X-Forwarded-For
+			oteltrace.WithAttributes(semconv.HTTPClientIPKey.String(ClientIP(c))),
 			oteltrace.WithSpanKind(oteltrace.SpanKindServer),
 		}
 		path := c.FullPath()
@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+
+	"github.com/SyntSugar/ss-infra-go/tracing/tracingtest"
+)
+
+func TestOpenTelemetryTracing(t *testing.T) {
+	recorder := tracingtest.Install(t)
+	gin.SetMode(gin.ReleaseMode)
+	engine := gin.New()
+	engine.Use(NewOpenTelemetryTracing("test-service", nil, nil))
+	engine.GET("/users/:id", func(c *gin.Context) {
+		_, span := otel.Tracer("handler").Start(c.Request.Context(), "load user")
+		span.End()
+		c.Status(http.StatusNotFound)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/users/1", nil)
+	// the X-Forwarded-For of the untrusted peer wasn't the client IP
+	req.Header.Set(HeaderXForwardedFor, "5.6.7.8")
+	otel.GetTextMapPropagator().Inject(req.Context(), propagation.HeaderCarrier(req.Header))
+	engine.ServeHTTP(httptest.NewRecorder(), req)
+
+	recorder.AssertSpanNames(t, "GET /users/:id", "load user")
+	recorder.AssertParent(t, "GET /users/:id", "load user")
+	recorder.AssertAttribute(t, "GET /users/:id", "http.status_code", int64(http.StatusNotFound))
+	recorder.AssertAttribute(t, "GET /users/:id", "http.route", "/users/:id")
+	recorder.AssertAttribute(t, "GET /users/:id", "http.client_ip", RemoteIP(req.RemoteAddr))
+}
@@ -133,6 +133,11 @@ func (responseWriter *ginResponseWriter) WriteString(s string) (n int, err error
 	return responseWriter.ResponseWriter.WriteString(s)
 }
 
+// Unwrap returns the underlying writer for http.ResponseController.
+func (responseWriter *ginResponseWriter) Unwrap() http.ResponseWriter {
+	return responseWriter.ResponseWriter
+}
+
 // newResponseWriter creates a new ResponseWriter from a gin.ResponseWriter.
 func newResponseWriter(writer gin.ResponseWriter) ResponseWriter {
 	return &ginResponseWriter{ResponseWriter: writer}
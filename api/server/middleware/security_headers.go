@@ -0,0 +1,281 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SyntSugar/ss-infra-go/consts"
+	"github.com/SyntSugar/ss-infra-go/log"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const (
+	// OmitHeader removes the header which was set by the outer SecurityHeaders middleware.
+	OmitHeader = "-"
+	// NoncePlaceholder was replaced with the nonce of request in the ContentSecurityPolicy.
+	NoncePlaceholder = "{nonce}"
+
+	maxCSPReportSize = 64 << 10
+	cspLabelOther    = "other"
+)
+
+// cspDirectives were the known CSP directives, the others in the reports were counted as "other"
+// to bound the cardinality of metrics, since the reports were sent by the clients.
+var cspDirectives = map[string]struct{}{
+	"base-uri": {}, "block-all-mixed-content": {}, "child-src": {}, "connect-src": {}, "default-src": {},
+	"fenced-frame-src": {}, "font-src": {}, "form-action": {}, "frame-ancestors": {}, "frame-src": {},
+	"img-src": {}, "manifest-src": {}, "media-src": {}, "navigate-to": {}, "object-src": {},
+	"plugin-types": {}, "prefetch-src": {}, "report-to": {}, "report-uri": {}, "require-sri-for": {},
+	"require-trusted-types-for": {}, "sandbox": {}, "script-src": {}, "script-src-attr": {},
+	"script-src-elem": {}, "style-src": {}, "style-src-attr": {}, "style-src-elem": {},
+	"trusted-types": {}, "upgrade-insecure-requests": {}, "worker-src": {},
+}
+
+// SecurityHeadersConfig was the config of SecurityHeaders middleware, the header would be untouched when
+// its field was empty so that the route group could override some headers of the outer middleware,
+// and it would be removed when the field was OmitHeader.
+type SecurityHeadersConfig struct {
+	// HSTSMaxAge was the max-age of Strict-Transport-Security, it's omitted when HSTSMaxAge <= 0.
+	HSTSMaxAge            time.Duration
+	HSTSIncludeSubdomains bool
+	HSTSPreload           bool
+	// ContentSecurityPolicy was the policy of Content-Security-Policy, the {nonce} placeholder
+	// would be replaced with the nonce of request which could be got by CSPNonce.
+	ContentSecurityPolicy string
+	// CSPReportOnly sends the policy in Content-Security-Policy-Report-Only to monitor the violations without enforcing.
+	CSPReportOnly bool
+	// CSPReportURI was the uri which the violation reports were sent to, e.g. the csp-report endpoint of admin server.
+	CSPReportURI string
+	// ContentTypeOptions was the X-Content-Type-Options, e.g. nosniff.
+	ContentTypeOptions string
+	// FrameOptions was the X-Frame-Options, e.g. DENY or SAMEORIGIN.
+	FrameOptions   string
+	ReferrerPolicy string
+	// PermissionsPolicy was the Permissions-Policy, e.g. "camera=(), microphone=()".
+	PermissionsPolicy         string
+	CrossOriginOpenerPolicy   string
+	CrossOriginEmbedderPolicy string
+	CrossOriginResourcePolicy string
+}
+
+// APISecurityHeaders returns the preset for the JSON APIs, which forbids the responses
+// from being rendered as documents or embedded by other origins.
+func APISecurityHeaders() SecurityHeadersConfig {
+	return SecurityHeadersConfig{
+		HSTSMaxAge:                365 * 24 * time.Hour,
+		HSTSIncludeSubdomains:     true,
+		ContentSecurityPolicy:     "default-src 'none'; frame-ancestors 'none'",
+		ContentTypeOptions:        "nosniff",
+		FrameOptions:              "DENY",
+		ReferrerPolicy:            "no-referrer",
+		PermissionsPolicy:         "camera=(), microphone=(), geolocation=(), payment=()",
+		CrossOriginOpenerPolicy:   "same-origin",
+		CrossOriginResourcePolicy: "same-origin",
+	}
+}
+
+// HTMLSecurityHeaders returns the preset for the HTML pages, the inline scripts and styles
+// were allowed only with the nonce of request.
+func HTMLSecurityHeaders() SecurityHeadersConfig {
+	return SecurityHeadersConfig{
+		HSTSMaxAge:            365 * 24 * time.Hour,
+		HSTSIncludeSubdomains: true,
+		ContentSecurityPolicy: "default-src 'self'; script-src 'self' 'nonce-{nonce}'; " +
+			"style-src 'self' 'nonce-{nonce}'; img-src 'self' data:; object-src 'none'; " +
+			"base-uri 'self'; form-action 'self'; frame-ancestors 'self'",
+		ContentTypeOptions:        "nosniff",
+		FrameOptions:              "SAMEORIGIN",
+		ReferrerPolicy:            "strict-origin-when-cross-origin",
+		PermissionsPolicy:         "camera=(), microphone=(), geolocation=(), payment=()",
+		CrossOriginOpenerPolicy:   "same-origin",
+		CrossOriginEmbedderPolicy: "require-corp",
+		CrossOriginResourcePolicy: "same-origin",
+	}
+}
+
+// CSPNonce returns the nonce of request which was generated by SecurityHeaders, it's used in
+// the nonce attribute of the inline scripts and styles, e.g. <script nonce="{{ .nonce }}">.
+func CSPNonce(c *gin.Context) string {
+	return c.GetString(string(consts.ContextKeyCSPNonce))
+}
+
+// SecurityHeaders sets the security headers of responses, it could be used by the route group
+// again to override the headers of the outer one, and the nonce of request would be shared.
+func SecurityHeaders(cfg SecurityHeadersConfig) gin.HandlerFunc {
+	var hsts string
+	if cfg.HSTSMaxAge > 0 {
+		hsts = "max-age=" + strconv.FormatInt(int64(cfg.HSTSMaxAge.Seconds()), 10)
+		if cfg.HSTSIncludeSubdomains {
+			hsts += "; includeSubDomains"
+		}
+		if cfg.HSTSPreload {
+			hsts += "; preload"
+		}
+	}
+	csp := cfg.ContentSecurityPolicy
+	if csp != "" && csp != OmitHeader && cfg.CSPReportURI != "" {
+		csp += "; report-uri " + cfg.CSPReportURI
+	}
+	cspHeader, otherCSPHeader := "Content-Security-Policy", "Content-Security-Policy-Report-Only"
+	if cfg.CSPReportOnly {
+		cspHeader, otherCSPHeader = otherCSPHeader, cspHeader
+	}
+	headers := [][2]string{
+		{"Strict-Transport-Security", hsts},
+		{"X-Content-Type-Options", cfg.ContentTypeOptions},
+		{"X-Frame-Options", cfg.FrameOptions},
+		{"Referrer-Policy", cfg.ReferrerPolicy},
+		{"Permissions-Policy", cfg.PermissionsPolicy},
+		{"Cross-Origin-Opener-Policy", cfg.CrossOriginOpenerPolicy},
+		{"Cross-Origin-Embedder-Policy", cfg.CrossOriginEmbedderPolicy},
+		{"Cross-Origin-Resource-Policy", cfg.CrossOriginResourcePolicy},
+	}
+
+	return func(c *gin.Context) {
+		header := c.Writer.Header()
+		for _, h := range headers {
+			setSecurityHeader(header, h[0], h[1])
+		}
+		if csp != "" {
+			policy := csp
+			if strings.Contains(policy, NoncePlaceholder) {
+				policy = strings.ReplaceAll(policy, NoncePlaceholder, requestNonce(c))
+			}
+			setSecurityHeader(header, cspHeader, policy)
+			// the overridden policy replaces the outer one in the other mode
+			header.Del(otherCSPHeader)
+		}
+		c.Next()
+	}
+}
+
+func setSecurityHeader(header http.Header, name, value string) {
+	switch value {
+	case "":
+	case OmitHeader:
+		header.Del(name)
+	default:
+		header.Set(name, value)
+	}
+}
+
+// requestNonce returns the nonce of request, it's generated at the first call.
+func requestNonce(c *gin.Context) string {
+	if nonce := CSPNonce(c); nonce != "" {
+		return nonce
+	}
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	nonce := base64.StdEncoding.EncodeToString(b)
+	c.Set(string(consts.ContextKeyCSPNonce), nonce)
+	return nonce
+}
+
+// cspReport was the violation report of the report-uri directive.
+type cspReport struct {
+	DocumentURI        string `json:"document-uri"`
+	BlockedURI         string `json:"blocked-uri"`
+	ViolatedDirective  string `json:"violated-directive"`
+	EffectiveDirective string `json:"effective-directive"`
+	Disposition        string `json:"disposition"`
+}
+
+// reportingAPIReport was the violation report of the Reporting API(report-to directive).
+type reportingAPIReport struct {
+	Type string `json:"type"`
+	Body struct {
+		DocumentURL        string `json:"documentURL"`
+		BlockedURL         string `json:"blockedURL"`
+		EffectiveDirective string `json:"effectiveDirective"`
+		Disposition        string `json:"disposition"`
+	} `json:"body"`
+}
+
+// CSPReportHandler collects the CSP violation reports in both the report-uri(application/csp-report)
+// and the Reporting API(application/reports+json) formats, the violations were logged and counted
+// by the effective directive, and the unknown directives and dispositions were counted as "other".
+func CSPReportHandler(logger *log.Logger) gin.HandlerFunc {
+	if logger == nil {
+		logger = log.GlobalLogger()
+	}
+	return func(c *gin.Context) {
+		body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxCSPReportSize))
+		if err != nil {
+			c.Status(http.StatusBadRequest)
+			return
+		}
+		var reports []cspReport
+		if strings.HasPrefix(c.ContentType(), "application/reports+json") {
+			var entries []reportingAPIReport
+			if err := json.Unmarshal(body, &entries); err != nil {
+				c.Status(http.StatusBadRequest)
+				return
+			}
+			for _, entry := range entries {
+				if entry.Type != "csp-violation" {
+					continue
+				}
+				reports = append(reports, cspReport{
+					DocumentURI:        entry.Body.DocumentURL,
+					BlockedURI:         entry.Body.BlockedURL,
+					EffectiveDirective: entry.Body.EffectiveDirective,
+					Disposition:        entry.Body.Disposition,
+				})
+			}
+		} else {
+			var wrapper struct {
+				Report cspReport `json:"csp-report"`
+			}
+			if err := json.Unmarshal(body, &wrapper); err != nil {
+				c.Status(http.StatusBadRequest)
+				return
+			}
+			reports = append(reports, wrapper.Report)
+		}
+
+		for _, report := range reports {
+			directive := report.EffectiveDirective
+			if directive == "" {
+				directive = report.ViolatedDirective
+			}
+			// the violated-directive of old browsers contains the source list
+			if i := strings.IndexByte(directive, ' '); i > 0 {
+				directive = directive[:i]
+			}
+			serMetrics.CSPViolations.WithLabelValues(cspDirectiveLabel(directive), cspDispositionLabel(report.Disposition)).Inc()
+			logger.WarnCtx(c.Request.Context(), "CSP violation",
+				zap.String("document_uri", report.DocumentURI),
+				zap.String("blocked_uri", report.BlockedURI),
+				zap.String("directive", directive),
+				zap.String("disposition", report.Disposition))
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// cspDirectiveLabel returns the directive as the metric label, it's "other" for the unknown directives.
+func cspDirectiveLabel(directive string) string {
+	directive = strings.ToLower(directive)
+	if _, ok := cspDirectives[directive]; ok {
+		return directive
+	}
+	return cspLabelOther
+}
+
+// cspDispositionLabel returns the disposition as the metric label, it's "other" except enforce and report.
+func cspDispositionLabel(disposition string) string {
+	switch disposition = strings.ToLower(disposition); disposition {
+	case "enforce", "report":
+		return disposition
+	default:
+		return cspLabelOther
+	}
+}
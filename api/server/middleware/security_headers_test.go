@@ -0,0 +1,119 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSecurityHeaders(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	apiHeaders := APISecurityHeaders()
+	apiHeaders.HSTSPreload = true
+	apiHeaders.CSPReportURI = "/csp-report"
+	htmlHeaders := HTMLSecurityHeaders()
+	htmlHeaders.CSPReportOnly = true
+	htmlHeaders.CrossOriginEmbedderPolicy = OmitHeader
+
+	var nonce string
+	engine := gin.New()
+	engine.Use(SecurityHeaders(apiHeaders))
+	engine.GET("/api", func(c *gin.Context) {
+		assert.Empty(t, CSPNonce(c))
+		c.Status(http.StatusOK)
+	})
+	engine.Group("/pages", SecurityHeaders(htmlHeaders)).GET("", func(c *gin.Context) {
+		nonce = CSPNonce(c)
+		c.Status(http.StatusOK)
+	})
+
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api", nil))
+	assert.Equal(t, "max-age=31536000; includeSubDomains; preload", w.Header().Get("Strict-Transport-Security"))
+	assert.Equal(t, "default-src 'none'; frame-ancestors 'none'; report-uri /csp-report", w.Header().Get("Content-Security-Policy"))
+	assert.Empty(t, w.Header().Get("Content-Security-Policy-Report-Only"))
+	assert.Equal(t, "nosniff", w.Header().Get("X-Content-Type-Options"))
+	assert.Equal(t, "DENY", w.Header().Get("X-Frame-Options"))
+	assert.Equal(t, "no-referrer", w.Header().Get("Referrer-Policy"))
+	assert.Equal(t, "same-origin", w.Header().Get("Cross-Origin-Opener-Policy"))
+
+	// the route group overrides the headers of the outer one
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/pages", nil))
+	assert.NotEmpty(t, nonce)
+	assert.Equal(t, "max-age=31536000; includeSubDomains", w.Header().Get("Strict-Transport-Security"))
+	assert.Empty(t, w.Header().Get("Content-Security-Policy"))
+	csp := w.Header().Get("Content-Security-Policy-Report-Only")
+	assert.Contains(t, csp, "script-src 'self' 'nonce-"+nonce+"'")
+	assert.Contains(t, csp, "style-src 'self' 'nonce-"+nonce+"'")
+	assert.Equal(t, "SAMEORIGIN", w.Header().Get("X-Frame-Options"))
+	assert.Empty(t, w.Header().Get("Cross-Origin-Embedder-Policy"))
+
+	// the nonce was generated per request
+	lastNonce := nonce
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/pages", nil))
+	assert.NotEqual(t, lastNonce, nonce)
+}
+
+func TestSecurityHeadersHSTS(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	engine := gin.New()
+	engine.Use(SecurityHeaders(SecurityHeadersConfig{HSTSMaxAge: 10 * time.Minute}))
+	engine.GET("/", func(c *gin.Context) { c.Status(http.StatusOK) })
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, "max-age=600", w.Header().Get("Strict-Transport-Security"))
+	assert.Empty(t, w.Header().Get("Content-Security-Policy"))
+	assert.Empty(t, w.Header().Get("X-Frame-Options"))
+}
+
+func TestCSPReportHandler(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	engine := gin.New()
+	engine.POST("/csp-report", CSPReportHandler(nil))
+
+	counter := serMetrics.CSPViolations.WithLabelValues("script-src-elem", "report")
+	before := testutil.ToFloat64(counter)
+	other := serMetrics.CSPViolations.WithLabelValues(cspLabelOther, cspLabelOther)
+	otherBefore := testutil.ToFloat64(other)
+	for _, tc := range []struct {
+		contentType string
+		body        string
+		status      int
+	}{
+		{
+			"application/csp-report",
+			`{"csp-report":{"document-uri":"https://example.com/","blocked-uri":"inline",` +
+				`"violated-directive":"script-src-elem","effective-directive":"script-src-elem","disposition":"report"}}`,
+			http.StatusNoContent,
+		},
+		{
+			"application/reports+json",
+			`[{"type":"csp-violation","body":{"documentURL":"https://example.com/","blockedURL":"inline",` +
+				`"effectiveDirective":"script-src-elem","disposition":"report"}},{"type":"deprecation","body":{}}]`,
+			http.StatusNoContent,
+		},
+		{
+			"application/csp-report",
+			`{"csp-report":{"document-uri":"https://example.com/","violated-directive":"x-` +
+				strings.Repeat("a", 32) + ` 'self'","disposition":"random"}}`,
+			http.StatusNoContent,
+		},
+		{"application/csp-report", `{"csp-report":`, http.StatusBadRequest},
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/csp-report", strings.NewReader(tc.body))
+		req.Header.Set("Content-Type", tc.contentType)
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		assert.Equal(t, tc.status, w.Code, tc.body)
+	}
+	assert.Equal(t, before+2, testutil.ToFloat64(counter))
+	assert.Equal(t, otherBefore+1, testutil.ToFloat64(other))
+}
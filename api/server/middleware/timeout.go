@@ -0,0 +1,116 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	rsp "github.com/SyntSugar/ss-infra-go/api/response"
+	"github.com/SyntSugar/ss-infra-go/consts"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TimeoutConfig was the config of Timeout middleware.
+type TimeoutConfig struct {
+	// Timeout was the deadline of the requests, the inbound deadline would be used only if it's zero.
+	Timeout time.Duration
+	// MaxTimeout caps the inbound deadline when the Timeout was zero, it's unlimited by default.
+	MaxTimeout time.Duration
+	// Header was the inbound deadline header, default was X-Request-Timeout. The value was a duration
+	// like "1.5s" or the milliseconds, the grpc-timeout header would also be honored.
+	Header string
+}
+
+// Timeout sets the deadline on the request context, the shorter one of Timeout and the inbound
+// deadline header would be used. The handlers should pass the request context to the downstream
+// calls to stop at the deadline, and the request would be responded with 50400 if the handler
+// didn't write the response before the deadline.
+func Timeout(cfg TimeoutConfig) gin.HandlerFunc {
+	if cfg.Header == "" {
+		cfg.Header = consts.HeaderRequestTimeout
+	}
+	return func(c *gin.Context) {
+		if isSessionRoute(c) {
+			c.Next()
+			return
+		}
+		timeout := cfg.Timeout
+		if inbound, ok := inboundTimeout(c, cfg.Header); ok {
+			if cfg.MaxTimeout > 0 && inbound > cfg.MaxTimeout {
+				inbound = cfg.MaxTimeout
+			}
+			if timeout <= 0 || inbound < timeout {
+				timeout = inbound
+			}
+		}
+		if timeout <= 0 {
+			c.Next()
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+
+		if !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return
+		}
+		c.Set(string(consts.ContextKeyTimedOut), true)
+		serMetrics.Timeouts.WithLabelValues(c.FullPath()).Inc()
+		if !c.Writer.Written() {
+			rsp.ResponseWithErrors(c, http.StatusGatewayTimeout, 0, []any{"the request was timed out after " + timeout.String()})
+		}
+	}
+}
+
+// inboundTimeout parses the deadline header or the grpc-timeout header.
+func inboundTimeout(c *gin.Context, header string) (time.Duration, bool) {
+	if value := c.GetHeader(header); value != "" {
+		if d, err := time.ParseDuration(value); err == nil && d > 0 {
+			return d, true
+		}
+		if ms, err := strconv.ParseInt(value, 10, 64); err == nil && ms > 0 {
+			return time.Duration(ms) * time.Millisecond, true
+		}
+	}
+	return parseGRPCTimeout(c.GetHeader(consts.HeaderGRPCTimeout))
+}
+
+// parseGRPCTimeout parses the grpc-timeout header which was at most 8 digits followed by the unit,
+// e.g. "100m" means 100 milliseconds.
+func parseGRPCTimeout(value string) (time.Duration, bool) {
+	if len(value) < 2 || len(value) > 9 {
+		return 0, false
+	}
+	var unit time.Duration
+	switch value[len(value)-1] {
+	case 'H':
+		unit = time.Hour
+	case 'M':
+		unit = time.Minute
+	case 'S':
+		unit = time.Second
+	case 'm':
+		unit = time.Millisecond
+	case 'u':
+		unit = time.Microsecond
+	case 'n':
+		unit = time.Nanosecond
+	default:
+		return 0, false
+	}
+	n, err := strconv.ParseInt(value[:len(value)-1], 10, 64)
+	if err != nil || n <= 0 {
+		return 0, false
+	}
+	return time.Duration(n) * unit, true
+}
+
+// IsTimedOut returns whether the request was timed out by the Timeout middleware.
+func IsTimedOut(c *gin.Context) bool {
+	return c.GetBool(string(consts.ContextKeyTimedOut))
+}
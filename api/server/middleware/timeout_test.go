@@ -0,0 +1,130 @@
+package middleware
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	rsp "github.com/SyntSugar/ss-infra-go/api/response"
+	"github.com/SyntSugar/ss-infra-go/consts"
+)
+
+func TestParseGRPCTimeout(t *testing.T) {
+	for _, tc := range []struct {
+		value    string
+		expected time.Duration
+		ok       bool
+	}{
+		{"100m", 100 * time.Millisecond, true},
+		{"1S", time.Second, true},
+		{"2M", 2 * time.Minute, true},
+		{"1H", time.Hour, true},
+		{"500u", 500 * time.Microsecond, true},
+		{"10n", 10 * time.Nanosecond, true},
+		{"", 0, false},
+		{"m", 0, false},
+		{"10s", 0, false},
+		{"123456789S", 0, false},
+		{"-1S", 0, false},
+	} {
+		d, ok := parseGRPCTimeout(tc.value)
+		assert.Equal(t, tc.ok, ok, tc.value)
+		assert.Equal(t, tc.expected, d, tc.value)
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	var buf bytes.Buffer
+	accessLogger, err := NewAccessLogger(&buf, "%s %E")
+	require.Nil(t, err)
+	accessLogger.Enabled()
+
+	var deadline time.Duration
+	engine := gin.New()
+	engine.Use(AccessLog(accessLogger), ErrorHandler(nil))
+	group := engine.Group("", Timeout(TimeoutConfig{Timeout: time.Second}))
+	group.GET("/wait", func(c *gin.Context) {
+		d, _ := c.Request.Context().Deadline()
+		deadline = time.Until(d)
+		<-c.Request.Context().Done()
+		_ = c.Error(c.Request.Context().Err())
+	})
+	group.GET("/fast", func(c *gin.Context) {
+		rsp.ResponseWithOK(c, nil)
+	})
+
+	for _, header := range []string{consts.HeaderRequestTimeout, consts.HeaderGRPCTimeout} {
+		buf.Reset()
+		req := httptest.NewRequest(http.MethodGet, "/wait", nil)
+		if header == consts.HeaderRequestTimeout {
+			req.Header.Set(header, "50ms")
+		} else {
+			req.Header.Set(header, "50m")
+		}
+		startTime := time.Now()
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		assert.Less(t, time.Since(startTime), time.Second)
+		assert.LessOrEqual(t, deadline, 50*time.Millisecond)
+		assert.Equal(t, http.StatusGatewayTimeout, w.Code)
+		resp, err := rsp.UnmarshalResponse(w.Body.Bytes())
+		require.Nil(t, err)
+		assert.Equal(t, 50400, resp.Code())
+		assert.Equal(t, "504 true", strings.TrimSpace(buf.String()))
+	}
+
+	// the inbound deadline shouldn't extend the route timeout
+	req := httptest.NewRequest(http.MethodGet, "/wait", nil)
+	req.Header.Set(consts.HeaderRequestTimeout, "60000")
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusGatewayTimeout, w.Code)
+	assert.LessOrEqual(t, deadline, time.Second)
+	assert.Greater(t, deadline, 500*time.Millisecond)
+
+	buf.Reset()
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/fast", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "200 false", strings.TrimSpace(buf.String()))
+}
+
+func TestTimeoutSession(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	var buf bytes.Buffer
+	accessLogger, err := NewAccessLogger(&buf, "%s %S")
+	require.Nil(t, err)
+	engine := gin.New()
+	engine.Use(AccessLog(accessLogger), Timeout(TimeoutConfig{Timeout: time.Millisecond}))
+	engine.GET("/events", SessionRoute(), func(c *gin.Context) {
+		_, ok := c.Request.Context().Deadline()
+		assert.False(t, ok, "the session shouldn't have the deadline")
+		c.Set(string(consts.ContextKeySession), "sse")
+		c.Status(http.StatusOK)
+	})
+	engine.GET("/slow", func(c *gin.Context) {
+		_, ok := c.Request.Context().Deadline()
+		assert.True(t, ok, "the session headers shouldn't bypass the timeout of unmarked routes")
+		c.Status(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/events", nil)
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "200 sse", strings.TrimSpace(buf.String()))
+
+	for _, header := range [][2]string{{"Accept", "text/event-stream"}, {"Upgrade", "websocket"}} {
+		req = httptest.NewRequest(http.MethodGet, "/slow", nil)
+		req.Header.Set(header[0], header[1])
+		engine.ServeHTTP(httptest.NewRecorder(), req)
+	}
+}
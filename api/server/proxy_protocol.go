@@ -0,0 +1,227 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultProxyHeaderTimeout = 5 * time.Second
+	// maxProxyV1HeaderSize was the max size of the v1 header including the CRLF.
+	maxProxyV1HeaderSize = 107
+)
+
+var (
+	proxyV1Prefix    = []byte("PROXY ")
+	proxyV2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+	errInvalidProxyHeader = errors.New("invalid PROXY protocol header")
+)
+
+// ProxyProtocolListener accepts the connections with the PROXY protocol v1/v2 header, the RemoteAddr
+// of connections was the client address in the header. The header was parsed only if the peer was
+// one of the trusted proxies(no peer was trusted when it's empty), and the connection without
+// the header was served as it's.
+type ProxyProtocolListener struct {
+	net.Listener
+	// Trusted was the trusted proxies which were allowed to send the header.
+	Trusted []*net.IPNet
+	// HeaderTimeout was the timeout of reading the header, default was 5 seconds.
+	HeaderTimeout time.Duration
+}
+
+// NewProxyProtocolListener wraps the listener to accept the PROXY protocol header from the trusted proxies.
+func NewProxyProtocolListener(ln net.Listener, trusted []*net.IPNet) *ProxyProtocolListener {
+	return &ProxyProtocolListener{Listener: ln, Trusted: trusted, HeaderTimeout: defaultProxyHeaderTimeout}
+}
+
+func (ln *ProxyProtocolListener) Accept() (net.Conn, error) {
+	conn, err := ln.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	if !ln.isTrusted(conn.RemoteAddr()) {
+		return conn, nil
+	}
+	timeout := ln.HeaderTimeout
+	if timeout <= 0 {
+		timeout = defaultProxyHeaderTimeout
+	}
+	// the header was parsed at the first use of the connection to avoid blocking the accept loop
+	return &proxyConn{Conn: conn, reader: bufio.NewReader(conn), timeout: timeout}, nil
+}
+
+func (ln *ProxyProtocolListener) isTrusted(addr net.Addr) bool {
+	tcpAddr, ok := addr.(*net.TCPAddr)
+	if !ok {
+		return false
+	}
+	for _, ipNet := range ln.Trusted {
+		if ipNet.Contains(tcpAddr.IP) {
+			return true
+		}
+	}
+	return false
+}
+
+type proxyConn struct {
+	net.Conn
+	reader  *bufio.Reader
+	timeout time.Duration
+
+	once       sync.Once
+	err        error
+	remoteAddr net.Addr
+	localAddr  net.Addr
+}
+
+func (conn *proxyConn) init() {
+	conn.once.Do(func() {
+		_ = conn.Conn.SetReadDeadline(time.Now().Add(conn.timeout))
+		conn.remoteAddr, conn.localAddr, conn.err = readProxyHeader(conn.reader)
+		_ = conn.Conn.SetReadDeadline(time.Time{})
+		if conn.err != nil {
+			// the connection couldn't be served after the broken header
+			_ = conn.Conn.Close()
+		}
+	})
+}
+
+func (conn *proxyConn) Read(p []byte) (int, error) {
+	conn.init()
+	if conn.err != nil {
+		return 0, conn.err
+	}
+	return conn.reader.Read(p)
+}
+
+func (conn *proxyConn) RemoteAddr() net.Addr {
+	conn.init()
+	if conn.remoteAddr != nil {
+		return conn.remoteAddr
+	}
+	return conn.Conn.RemoteAddr()
+}
+
+func (conn *proxyConn) LocalAddr() net.Addr {
+	conn.init()
+	if conn.localAddr != nil {
+		return conn.localAddr
+	}
+	return conn.Conn.LocalAddr()
+}
+
+// readProxyHeader reads the v1 or v2 header, the addresses were nil if there's no header
+// or the proxy didn't forward the addresses, e.g. the health checks of the proxy.
+func readProxyHeader(reader *bufio.Reader) (net.Addr, net.Addr, error) {
+	first, err := reader.Peek(1)
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, nil, nil
+		}
+		return nil, nil, err
+	}
+	switch first[0] {
+	case proxyV1Prefix[0]:
+		if prefix, err := reader.Peek(len(proxyV1Prefix)); err == nil && bytes.Equal(prefix, proxyV1Prefix) {
+			return readProxyV1Header(reader)
+		}
+	case proxyV2Signature[0]:
+		if prefix, err := reader.Peek(len(proxyV2Signature)); err == nil && bytes.Equal(prefix, proxyV2Signature) {
+			return readProxyV2Header(reader)
+		}
+	}
+	return nil, nil, nil
+}
+
+// readProxyV1Header reads the header like "PROXY TCP4 192.168.0.1 192.168.0.11 56324 443\r\n".
+func readProxyV1Header(reader *bufio.Reader) (net.Addr, net.Addr, error) {
+	var line []byte
+	for !bytes.HasSuffix(line, []byte("\r\n")) {
+		if len(line) >= maxProxyV1HeaderSize {
+			return nil, nil, errInvalidProxyHeader
+		}
+		b, err := reader.ReadByte()
+		if err != nil {
+			return nil, nil, err
+		}
+		line = append(line, b)
+	}
+	fields := strings.Fields(string(line))
+	if len(fields) < 2 {
+		return nil, nil, errInvalidProxyHeader
+	}
+	switch fields[1] {
+	case "UNKNOWN":
+		return nil, nil, nil
+	case "TCP4", "TCP6":
+	default:
+		return nil, nil, errInvalidProxyHeader
+	}
+	if len(fields) != 6 {
+		return nil, nil, errInvalidProxyHeader
+	}
+	srcIP, dstIP := net.ParseIP(fields[2]), net.ParseIP(fields[3])
+	srcPort, srcErr := strconv.ParseUint(fields[4], 10, 16)
+	dstPort, dstErr := strconv.ParseUint(fields[5], 10, 16)
+	if srcIP == nil || dstIP == nil || srcErr != nil || dstErr != nil {
+		return nil, nil, errInvalidProxyHeader
+	}
+	return &net.TCPAddr{IP: srcIP, Port: int(srcPort)}, &net.TCPAddr{IP: dstIP, Port: int(dstPort)}, nil
+}
+
+// readProxyV2Header reads the binary header, the TLVs were skipped.
+func readProxyV2Header(reader *bufio.Reader) (net.Addr, net.Addr, error) {
+	header := make([]byte, len(proxyV2Signature)+4)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		return nil, nil, err
+	}
+	verCmd, family := header[12], header[13]
+	length := int(binary.BigEndian.Uint16(header[14:16]))
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(reader, payload); err != nil {
+		return nil, nil, err
+	}
+	if verCmd>>4 != 2 {
+		return nil, nil, fmt.Errorf("%w: unsupported version %d", errInvalidProxyHeader, verCmd>>4)
+	}
+	switch verCmd & 0x0f {
+	case 0x0:
+		// LOCAL command was sent by the proxy itself, the real addresses were kept
+		return nil, nil, nil
+	case 0x1:
+	default:
+		return nil, nil, errInvalidProxyHeader
+	}
+
+	var ipLen int
+	switch family >> 4 {
+	case 0x1:
+		ipLen = net.IPv4len
+	case 0x2:
+		ipLen = net.IPv6len
+	default:
+		// AF_UNSPEC and AF_UNIX weren't the IP addresses
+		return nil, nil, nil
+	}
+	if len(payload) < 2*ipLen+4 {
+		return nil, nil, errInvalidProxyHeader
+	}
+	srcIP := net.IP(append([]byte{}, payload[:ipLen]...))
+	dstIP := net.IP(append([]byte{}, payload[ipLen:2*ipLen]...))
+	srcPort := int(binary.BigEndian.Uint16(payload[2*ipLen:]))
+	dstPort := int(binary.BigEndian.Uint16(payload[2*ipLen+2:]))
+	if family&0x0f == 0x2 {
+		return &net.UDPAddr{IP: srcIP, Port: srcPort}, &net.UDPAddr{IP: dstIP, Port: dstPort}, nil
+	}
+	return &net.TCPAddr{IP: srcIP, Port: srcPort}, &net.TCPAddr{IP: dstIP, Port: dstPort}, nil
+}
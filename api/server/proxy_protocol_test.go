@@ -0,0 +1,103 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/SyntSugar/ss-infra-go/api/server/middleware"
+)
+
+func proxyV2Header(t *testing.T, src, dst *net.TCPAddr) []byte {
+	var buf bytes.Buffer
+	buf.Write(proxyV2Signature)
+	buf.WriteByte(0x21)
+	src4, dst4 := src.IP.To4(), dst.IP.To4()
+	require.NotNil(t, src4)
+	buf.WriteByte(0x11)
+	_ = binary.Write(&buf, binary.BigEndian, uint16(12+3))
+	buf.Write(src4)
+	buf.Write(dst4)
+	_ = binary.Write(&buf, binary.BigEndian, uint16(src.Port))
+	_ = binary.Write(&buf, binary.BigEndian, uint16(dst.Port))
+	// the TLV would be skipped
+	buf.Write([]byte{0x04, 0x00, 0x00})
+	return buf.Bytes()
+}
+
+func TestReadProxyHeader(t *testing.T) {
+	src := &net.TCPAddr{IP: net.ParseIP("5.6.7.8").To4(), Port: 56324}
+	dst := &net.TCPAddr{IP: net.ParseIP("10.0.0.1").To4(), Port: 443}
+	for _, tc := range []struct {
+		name   string
+		header []byte
+		remote string
+		err    bool
+	}{
+		{"v1 tcp4", []byte("PROXY TCP4 5.6.7.8 10.0.0.1 56324 443\r\n"), "5.6.7.8:56324", false},
+		{"v1 tcp6", []byte("PROXY TCP6 2001:db8::1 2001:db8::2 56324 443\r\n"), "[2001:db8::1]:56324", false},
+		{"v1 unknown", []byte("PROXY UNKNOWN\r\n"), "", false},
+		{"v1 invalid", []byte("PROXY TCP4 5.6.7.8 10.0.0.1 99999 443\r\n"), "", true},
+		{"v1 too long", append([]byte("PROXY "), bytes.Repeat([]byte("x"), 200)...), "", true},
+		{"v2 tcp4", proxyV2Header(t, src, dst), "5.6.7.8:56324", false},
+		{"v2 local", append(append([]byte{}, proxyV2Signature...), 0x20, 0x00, 0x00, 0x00), "", false},
+		{"no header", nil, "", false},
+	} {
+		reader := bufio.NewReader(io.MultiReader(bytes.NewReader(tc.header), bytes.NewReader([]byte("GET / HTTP/1.1\r\n"))))
+		remote, _, err := readProxyHeader(reader)
+		if tc.err {
+			assert.NotNil(t, err, tc.name)
+			continue
+		}
+		require.Nil(t, err, tc.name)
+		if tc.remote == "" {
+			assert.Nil(t, remote, tc.name)
+		} else {
+			assert.Equal(t, tc.remote, remote.String(), tc.name)
+		}
+		line, _ := reader.ReadString('\n')
+		assert.Equal(t, "GET / HTTP/1.1\r\n", line, tc.name)
+	}
+}
+
+func TestProxyProtocolListener(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	trusted, err := middleware.ParseCIDRs([]string{"127.0.0.1"})
+	require.Nil(t, err)
+	remoteAddrs := make(chan string, 1)
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		remoteAddrs <- r.RemoteAddr
+	})}
+	go func() { _ = server.Serve(NewProxyProtocolListener(ln, trusted)) }()
+	defer server.Close()
+
+	for _, header := range []string{"PROXY TCP4 5.6.7.8 10.0.0.1 56324 443\r\n", ""} {
+		conn, err := net.Dial("tcp", ln.Addr().String())
+		require.Nil(t, err)
+		_, err = conn.Write([]byte(header + "GET / HTTP/1.1\r\nHost: localhost\r\nConnection: close\r\n\r\n"))
+		require.Nil(t, err)
+		resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+		require.Nil(t, err)
+		resp.Body.Close()
+		conn.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		remoteAddr := <-remoteAddrs
+		if header != "" {
+			assert.Equal(t, "5.6.7.8:56324", remoteAddr)
+		} else {
+			assert.Equal(t, "127.0.0.1", middleware.RemoteIP(remoteAddr))
+		}
+	}
+
+	// no peer was trusted without the trusted proxies
+	untrusted := NewProxyProtocolListener(ln, nil)
+	assert.False(t, untrusted.isTrusted(&net.TCPAddr{IP: net.ParseIP("127.0.0.1")}))
+}
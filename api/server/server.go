@@ -4,18 +4,26 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
+	"github.com/SyntSugar/ss-infra-go/api/openapi"
+	"github.com/SyntSugar/ss-infra-go/api/realtime"
+	rsp "github.com/SyntSugar/ss-infra-go/api/response"
 	"github.com/SyntSugar/ss-infra-go/api/server/handlers"
 	"github.com/SyntSugar/ss-infra-go/api/server/middleware"
 	"github.com/SyntSugar/ss-infra-go/log"
 
 	"github.com/gin-gonic/gin"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.uber.org/zap"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 
 	"go.opentelemetry.io/otel"
 )
@@ -27,8 +35,19 @@ type Server struct {
 
 	apiEngine   *gin.Engine
 	adminEngine *gin.Engine
+	openapi     *openapi.Registry
+	sessions    *realtime.Registry
 	apiServer   *http.Server
 	adminServer *http.Server
+
+	apiListeners   []net.Listener
+	adminListeners []net.Listener
+	upgrading      atomic.Bool
+	draining       atomic.Bool
+	apiConns       connTracker
+	h2cConns       h2cTracker
+	http3Server    HTTP3Server
+	http3Closed    atomic.Bool
 }
 
 // New would create server which contains api and admin api server
@@ -54,9 +73,26 @@ func (srv *Server) setup() error {
 	gin.SetMode(gin.ReleaseMode)
 	if srv.config.API != nil {
 		srv.apiEngine = gin.New()
+		srv.openapi = openapi.NewRegistry("", "")
+		srv.sessions = realtime.NewRegistry()
 		srv.apiServer = &http.Server{
-			Addr:    srv.config.API.Addr,
-			Handler: srv.apiEngine,
+			Addr:              srv.config.API.Addr,
+			Handler:           srv.apiEngine,
+			ReadTimeout:       srv.config.API.ReadTimeout,
+			ReadHeaderTimeout: srv.config.API.ReadHeaderTimeout,
+			WriteTimeout:      srv.config.API.WriteTimeout,
+			IdleTimeout:       srv.config.API.IdleTimeout,
+			MaxHeaderBytes:    srv.config.API.MaxHeaderBytes,
+			ConnState:         srv.apiConns.track,
+		}
+		if srv.config.API.H2C {
+			h2s := &http2.Server{IdleTimeout: srv.config.API.IdleTimeout}
+			// the server sends GOAWAY to the HTTP/2 connections at Shutdown
+			if err := http2.ConfigureServer(srv.apiServer, h2s); err != nil {
+				return err
+			}
+			// the h2c connections were hijacked, so they're waited for by Shutdown
+			srv.apiServer.Handler = srv.h2cConns.track(h2c.NewHandler(srv.apiEngine, h2s))
 		}
 		srv.setupAPIDefaultHandlers()
 	}
@@ -84,17 +120,59 @@ func (srv *Server) setupMiddlewares() error {
 	}
 
 	if srv.apiEngine != nil {
+		// the sessions of the api were closed at Shutdown
+		srv.apiEngine.Use(srv.sessions.Track())
+		if srv.config.API.ResponseFormat != "" {
+			srv.apiEngine.Use(rsp.WithFormat(srv.config.API.ResponseFormat))
+		}
+		resolver, err := middleware.NewClientIPResolver(middleware.ClientIPConfig{
+			TrustedProxies:  srv.config.API.TrustedProxies,
+			TrustCloudflare: srv.config.API.TrustCloudflare,
+		})
+		if err != nil {
+			return err
+		}
+		// the c.ClientIP() of handlers should agree with the resolved client IP
+		if err := resolver.SetupEngine(srv.apiEngine); err != nil {
+			return err
+		}
+		if len(srv.config.API.TrustedProxies) > 0 || srv.config.API.TrustCloudflare {
+			srv.apiEngine.Use(middleware.ClientIPMiddleware(resolver))
+		}
 		srv.apiEngine.Use(
 			middleware.CORSMiddleware(),
 			middleware.DynamicDebugLogging,
 			middleware.PanicRecovery(srv.logger),
 			middleware.AccessLog(accessLogger),
 			middleware.CollectMetrics,
+			middleware.ErrorHandler(srv.logger),
 		)
+		if headers := srv.config.API.SecurityHeaders; headers.Preset != "" {
+			securityHeaders := middleware.APISecurityHeaders()
+			if headers.Preset == SecurityHeadersPresetHTML {
+				securityHeaders = middleware.HTMLSecurityHeaders()
+			}
+			securityHeaders.CSPReportOnly = headers.CSPReportOnly
+			securityHeaders.CSPReportURI = headers.CSPReportURI
+			srv.apiEngine.Use(middleware.SecurityHeaders(securityHeaders))
+		}
+		if srv.config.API.HTTP3Addr != "" {
+			_, port, _ := net.SplitHostPort(srv.config.API.HTTP3Addr)
+			srv.apiEngine.Use(middleware.AltSvc(port, srv.config.API.AltSvcMaxAge))
+		}
+		if srv.config.API.MaxBodyBytes > 0 || srv.config.API.MinUploadRate > 0 {
+			srv.apiEngine.Use(middleware.BodyLimit(middleware.BodyLimitConfig{
+				MaxBytes: srv.config.API.MaxBodyBytes,
+				MinRate:  srv.config.API.MinUploadRate,
+			}))
+		}
+		if srv.config.API.RequestTimeout > 0 {
+			srv.apiEngine.Use(middleware.Timeout(middleware.TimeoutConfig{Timeout: srv.config.API.RequestTimeout}))
+		}
 
 		if srv.config.OpenTelemetry != nil {
 			srv.apiEngine.Use(middleware.NewOpenTelemetryTracing(
-				srv.config.OpenTelemetry.Exporter,
+				srv.config.OpenTelemetry.GetServiceName(),
 				otel.GetTextMapPropagator(),
 				otel.GetTracerProvider()))
 		}
@@ -156,6 +234,11 @@ func (srv *Server) setupAdminDefaultHandlers() {
 	srv.adminEngine.GET(srv.config.Admin.BasePath+"/whoami", handlers.Whoami)
 	srv.adminEngine.Any("/debug/pprof/*profile", handlers.PProf)
 	srv.adminEngine.GET("/metrics", gin.WrapH(promhttp.Handler()))
+	srv.adminEngine.POST(srv.config.Admin.BasePath+"/csp-report", middleware.CSPReportHandler(srv.logger))
+	if srv.apiEngine != nil {
+		srv.adminEngine.GET(srv.config.Admin.BasePath+"/openapi.json", srv.openapi.Handler())
+		srv.adminEngine.GET(srv.config.Admin.BasePath+"/routes", openapi.RoutesHandler(srv.apiEngine, srv.openapi))
+	}
 }
 
 // GetAPIRouteGroup return api's gin engine that user can add api handlers
@@ -163,6 +246,17 @@ func (srv *Server) GetAPIRouteGroup() *gin.RouterGroup {
 	return srv.apiEngine.Group(srv.config.API.BasePath)
 }
 
+// GetAPIRouter return the api router which documents the handlers in the OpenAPI document,
+// the document was served at /openapi.json of the admin server.
+func (srv *Server) GetAPIRouter() *openapi.Router {
+	return openapi.NewRouter(srv.GetAPIRouteGroup(), srv.openapi)
+}
+
+// GetOpenAPIRegistry return the registry of the documented api routes
+func (srv *Server) GetOpenAPIRegistry() *openapi.Registry {
+	return srv.openapi
+}
+
 func (srv *Server) GetAPIEngine() *gin.Engine {
 	return srv.apiEngine
 }
@@ -176,21 +270,122 @@ func (srv *Server) GetAdminRouteGroup() *gin.RouterGroup {
 	return srv.adminEngine.Group("")
 }
 
+// AddAPIListener serves the api on the pre-opened listener, e.g. the ephemeral port in tests.
+// The configured addresses wouldn't be listened if there's any listener added or activated,
+// and it should be called before Run.
+func (srv *Server) AddAPIListener(ln net.Listener) {
+	srv.apiListeners = append(srv.apiListeners, ln)
+}
+
+// AddAdminListener serves the admin api on the pre-opened listener, it should be called before Run.
+func (srv *Server) AddAdminListener(ln net.Listener) {
+	srv.adminListeners = append(srv.adminListeners, ln)
+}
+
+// APIAddrs returns the addresses of the api listeners, it's available after Run.
+func (srv *Server) APIAddrs() []net.Addr {
+	addrs := make([]net.Addr, 0, len(srv.apiListeners))
+	for _, ln := range srv.apiListeners {
+		addrs = append(addrs, ln.Addr())
+	}
+	return addrs
+}
+
+// AdminAddrs returns the addresses of the admin listeners, it's available after Run.
+func (srv *Server) AdminAddrs() []net.Addr {
+	addrs := make([]net.Addr, 0, len(srv.adminListeners))
+	for _, ln := range srv.adminListeners {
+		addrs = append(addrs, ln.Addr())
+	}
+	return addrs
+}
+
 // Run would setup api/admin api server and async listening on api ports
 func (srv *Server) Run() error {
-	for _, server := range []*http.Server{srv.apiServer, srv.adminServer} {
-		go func(httpServer *http.Server) {
-			if httpServer == nil {
-				return
+	if srv.config.API != nil && srv.config.API.HTTP3Addr != "" && srv.http3Server == nil {
+		return errors.New("the http3 server should be set by SetHTTP3Server when the http3 address was advertised")
+	}
+	if err := srv.listen(); err != nil {
+		closeListeners(srv.apiListeners)
+		closeListeners(srv.adminListeners)
+		return err
+	}
+	for _, ln := range srv.apiListeners {
+		go srv.serve(srv.apiServer, ln)
+	}
+	for _, ln := range srv.adminListeners {
+		go srv.serve(srv.adminServer, ln)
+	}
+	if srv.http3Server != nil {
+		// the address of the HTTP/3 server was still bound by the parent until it shut down
+		srv.http3Closed.Store(false)
+		go srv.serveHTTP3(isUpgraded())
+	}
+	if srv.sessions != nil {
+		// the sessions were rejected after Shutdown until the server was run again
+		srv.sessions.Open()
+	}
+	return notifyUpgradeReady()
+}
+
+// listen opens the listeners of the configured addresses unless they were added or activated.
+func (srv *Server) listen() error {
+	// the process which was started by Upgrade serves on the listeners of the parent
+	if srv.config.SocketActivation || isUpgraded() {
+		activated, err := activatedListeners()
+		if err != nil {
+			return err
+		}
+		for name, listeners := range activated {
+			if name == AdminListenerName {
+				srv.adminListeners = append(srv.adminListeners, listeners...)
+			} else {
+				srv.apiListeners = append(srv.apiListeners, listeners...)
 			}
-			if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
-				fatalf("Failed to setup api httpServer, err: %s\n", err.Error())
+		}
+	}
+	if srv.apiServer == nil {
+		closeListeners(srv.apiListeners)
+		srv.apiListeners = nil
+	} else if len(srv.apiListeners) == 0 {
+		for _, addr := range append([]string{srv.config.API.Addr}, srv.config.API.Addrs...) {
+			ln, err := listen(addr)
+			if err != nil {
+				return err
 			}
-		}(server)
+			srv.apiListeners = append(srv.apiListeners, ln)
+		}
+	}
+	if srv.adminServer == nil {
+		closeListeners(srv.adminListeners)
+		srv.adminListeners = nil
+	} else if len(srv.adminListeners) == 0 {
+		ln, err := listen(srv.config.Admin.Addr)
+		if err != nil {
+			return err
+		}
+		srv.adminListeners = append(srv.adminListeners, ln)
+	}
+
+	if srv.apiServer != nil && srv.config.API.ProxyProtocol {
+		trusted, err := middleware.ParseCIDRs(srv.config.API.TrustedProxies)
+		if err != nil {
+			return err
+		}
+		for i, ln := range srv.apiListeners {
+			srv.apiListeners[i] = NewProxyProtocolListener(ln, trusted)
+		}
 	}
 	return nil
 }
 
+func (srv *Server) serve(httpServer *http.Server, ln net.Listener) {
+	// the listeners were closed by drain after the upgrade
+	if err := httpServer.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) && !srv.draining.Load() {
+		fatalf("Failed to setup api httpServer, err: %s\n", err.Error())
+	}
+}
+
 // ServeHTTP uses to parse request from http.Server
 func (srv *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	srv.apiEngine.ServeHTTP(w, r)
@@ -203,11 +398,31 @@ func (srv *Server) Shutdown() error {
 	if srv.apiServer != nil {
 		ctx, cancel := context.WithTimeout(context.Background(), srv.config.Shutdown)
 		defer cancel()
-		return srv.apiServer.Shutdown(ctx)
+		// the server would wait for the SSE handlers and doesn't track the hijacked WebSocket connections
+		if err := srv.sessions.Close(ctx); err != nil {
+			srv.getLogger().Warn("Close the active sessions timed out", zap.Error(err))
+		}
+		if srv.http3Server != nil {
+			if err := srv.shutdownHTTP3(ctx); err != nil {
+				srv.getLogger().Warn("Shutdown the http3 server failed", zap.Error(err))
+			}
+		}
+		err := srv.apiServer.Shutdown(ctx)
+		if waitErr := srv.h2cConns.wait(ctx); err == nil {
+			err = waitErr
+		}
+		return err
 	}
 	return nil
 }
 
+func (srv *Server) getLogger() *log.Logger {
+	if srv.logger == nil {
+		return log.GlobalLogger()
+	}
+	return srv.logger
+}
+
 func fatalf(format string, args ...interface{}) {
 	fmt.Printf(format, args...)
 	os.Exit(1)
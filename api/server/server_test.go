@@ -0,0 +1,55 @@
+package server
+
+import (
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/SyntSugar/ss-infra-go/api/realtime"
+	"github.com/SyntSugar/ss-infra-go/api/server/middleware"
+)
+
+func TestShutdownSessions(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	cfg := DefaultConfig()
+	cfg.Shutdown = 100 * time.Millisecond
+	srv, err := New(cfg, nil)
+	require.Nil(t, err)
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	srv.GetAPIRouteGroup().GET("/events", middleware.SessionRoute(), func(c *gin.Context) {
+		stream, err := realtime.StartSSE(c, nil)
+		if err != nil {
+			return
+		}
+		defer stream.Close()
+		started <- struct{}{}
+		// the session ignores the notification until it's released
+		<-release
+	})
+	apiListener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	adminListener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	srv.AddAPIListener(apiListener)
+	srv.AddAdminListener(adminListener)
+	require.Nil(t, srv.Run())
+
+	resp, err := http.Get("http://" + apiListener.Addr().String() + "/events")
+	require.Nil(t, err)
+	defer resp.Body.Close()
+	<-started
+	assert.Equal(t, 1, srv.sessions.Active())
+	assert.Equal(t, 0, realtime.ActiveSessions(), "the session was tracked by the server")
+
+	// the sessions were closed again without panic if the shutdown was retried
+	assert.NotNil(t, srv.Shutdown())
+	assert.NotNil(t, srv.Shutdown())
+	close(release)
+	assert.Eventually(t, func() bool { return srv.sessions.Active() == 0 }, time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,247 @@
+package server
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// upgradeReadyFDEnv was the file descriptor which the new process reports ready with,
+	// it's also the mark to serve on the passed listeners.
+	upgradeReadyFDEnv     = "INFRA_UPGRADE_READY_FD"
+	upgradeReadyMessage   = "ready"
+	apiListenerName       = "api"
+	defaultUpgradeTimeout = time.Minute
+	// maxDrainNewConnsWait was the max time of waiting for the accepted connections to send the requests.
+	maxDrainNewConnsWait = 5 * time.Second
+)
+
+var ErrUpgrading = errors.New("the server was upgrading")
+
+// filer was the listener which could be passed to the child process.
+type filer interface {
+	File() (*os.File, error)
+}
+
+// WaitForUpgrade blocks until the signal(default was SIGHUP) was received and the new process
+// was ready, then the server was shut down gracefully and the caller could exit. The failed
+// upgrade was logged and the server kept serving until the next signal.
+func (srv *Server) WaitForUpgrade(sigs ...os.Signal) error {
+	if len(sigs) == 0 {
+		sigs = []os.Signal{syscall.SIGHUP}
+	}
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, sigs...)
+	defer signal.Stop(ch)
+	for range ch {
+		pid, err := srv.Upgrade()
+		if err != nil {
+			srv.getLogger().Error("Upgrade the server failed", zap.Error(err))
+			continue
+		}
+		srv.getLogger().Info("Upgrade the server succeeded, shutting down", zap.Int("pid", pid))
+		srv.drain()
+		return srv.Shutdown()
+	}
+	return nil
+}
+
+// Upgrade fork-execs the new binary with the api and admin listeners, and waits for it to report ready
+// by Run. It returns the pid of the new process, and the caller should drain and exit by Shutdown then.
+func (srv *Server) Upgrade() (int, error) {
+	if !srv.upgrading.CompareAndSwap(false, true) {
+		return 0, ErrUpgrading
+	}
+	defer srv.upgrading.Store(false)
+
+	var files []*os.File
+	defer func() {
+		for _, file := range files {
+			_ = file.Close()
+		}
+	}()
+	var names []string
+	for _, group := range []struct {
+		name      string
+		listeners []net.Listener
+	}{
+		{apiListenerName, srv.apiListeners},
+		{AdminListenerName, srv.adminListeners},
+	} {
+		for _, ln := range group.listeners {
+			file, err := listenerFile(ln)
+			if err != nil {
+				return 0, err
+			}
+			files = append(files, file)
+			names = append(names, group.name)
+		}
+	}
+	if len(files) == 0 {
+		return 0, errors.New("there's no listener to pass, the server should be running")
+	}
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		return 0, err
+	}
+	defer reader.Close()
+	files = append(files, writer)
+
+	executable, err := os.Executable()
+	if err != nil {
+		return 0, err
+	}
+	// the path was suffixed with " (deleted)" on linux if the binary was replaced
+	executable = strings.TrimSuffix(executable, " (deleted)")
+	cmd := exec.Command(executable, os.Args[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	cmd.ExtraFiles = files
+	cmd.Env = append(upgradeEnviron(),
+		"LISTEN_FDS="+strconv.Itoa(len(names)),
+		"LISTEN_FDNAMES="+strings.Join(names, ":"),
+		upgradeReadyFDEnv+"="+strconv.Itoa(listenFDsStart+len(names)),
+	)
+	if err := cmd.Start(); err != nil {
+		return 0, err
+	}
+	// the writer should be closed in the parent so that the reader got EOF if the child exited
+	_ = writer.Close()
+	files = files[:len(files)-1]
+
+	ready := make(chan error, 1)
+	go func() {
+		line, err := bufio.NewReader(reader).ReadString('\n')
+		if err == nil && strings.TrimSpace(line) != upgradeReadyMessage {
+			err = fmt.Errorf("unexpected ready message: %s", line)
+		}
+		if err != nil {
+			err = fmt.Errorf("the new process exited before ready: %w", err)
+		}
+		ready <- err
+	}()
+	timer := time.NewTimer(srv.config.UpgradeTimeout)
+	defer timer.Stop()
+	select {
+	case err = <-ready:
+	case <-timer.C:
+		err = fmt.Errorf("the new process wasn't ready in %s", srv.config.UpgradeTimeout)
+	}
+	if err != nil {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+		return 0, err
+	}
+	pid := cmd.Process.Pid
+	// the child process would be reparented after this process exited
+	_ = cmd.Process.Release()
+
+	// the unix socket files were used by the new process, so they shouldn't be removed at shutdown
+	for _, ln := range append(append([]net.Listener{}, srv.apiListeners...), srv.adminListeners...) {
+		if proxyListener, ok := ln.(*ProxyProtocolListener); ok {
+			ln = proxyListener.Listener
+		}
+		if unixListener, ok := ln.(*net.UnixListener); ok {
+			unixListener.SetUnlinkOnClose(false)
+		}
+	}
+	return pid, nil
+}
+
+func listenerFile(ln net.Listener) (*os.File, error) {
+	if proxyListener, ok := ln.(*ProxyProtocolListener); ok {
+		ln = proxyListener.Listener
+	}
+	f, ok := ln.(filer)
+	if !ok {
+		return nil, fmt.Errorf("the listener %s couldn't be passed", ln.Addr())
+	}
+	return f.File()
+}
+
+// isUpgraded returns whether the process was started by Upgrade.
+func isUpgraded() bool {
+	return os.Getenv(upgradeReadyFDEnv) != ""
+}
+
+// notifyUpgradeReady reports ready to the parent process if it's started by Upgrade.
+func notifyUpgradeReady() error {
+	value := os.Getenv(upgradeReadyFDEnv)
+	if value == "" {
+		return nil
+	}
+	_ = os.Unsetenv(upgradeReadyFDEnv)
+	fd, err := strconv.Atoi(value)
+	if err != nil {
+		return fmt.Errorf("invalid %s: %s", upgradeReadyFDEnv, value)
+	}
+	file := os.NewFile(uintptr(fd), "upgrade-ready")
+	defer file.Close()
+	_, err = file.WriteString(upgradeReadyMessage + "\n")
+	return err
+}
+
+// drain stops accepting the connections which would be accepted by the new process, and waits
+// for the accepted connections to send the requests, since the http.Server drops the requests
+// which were read after Shutdown.
+func (srv *Server) drain() {
+	srv.draining.Store(true)
+	closeListeners(srv.apiListeners)
+	deadline := time.Now().Add(maxDrainNewConnsWait)
+	for srv.apiConns.newConns() > 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// connTracker tracks the connections which were accepted but hadn't sent the request yet.
+type connTracker struct {
+	mu    sync.Mutex
+	conns map[net.Conn]struct{}
+}
+
+func (tracker *connTracker) track(conn net.Conn, state http.ConnState) {
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+	if state == http.StateNew {
+		if tracker.conns == nil {
+			tracker.conns = make(map[net.Conn]struct{})
+		}
+		tracker.conns[conn] = struct{}{}
+		return
+	}
+	delete(tracker.conns, conn)
+}
+
+func (tracker *connTracker) newConns() int {
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+	return len(tracker.conns)
+}
+
+// upgradeEnviron returns the environment of the new process without the socket activation variables,
+// LISTEN_PID would be set by systemd to the pid of the old process which made the child ignore LISTEN_FDS.
+func upgradeEnviron() []string {
+	env := os.Environ()
+	filtered := env[:0]
+	for _, kv := range env {
+		name, _, _ := strings.Cut(kv, "=")
+		switch name {
+		case "LISTEN_PID", "LISTEN_FDS", "LISTEN_FDNAMES", upgradeReadyFDEnv:
+			continue
+		}
+		filtered = append(filtered, kv)
+	}
+	return filtered
+}
@@ -0,0 +1,229 @@
+package server
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// udpHTTP3Server binds the UDP address like the HTTP/3 server of quic-go.
+type udpHTTP3Server struct {
+	addr   string
+	mu     sync.Mutex
+	conn   net.PacketConn
+	closed bool
+}
+
+func (s *udpHTTP3Server) ListenAndServe() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return http.ErrServerClosed
+	}
+	conn, err := net.ListenPacket("udp", s.addr)
+	if err != nil {
+		s.mu.Unlock()
+		return err
+	}
+	s.conn = conn
+	s.mu.Unlock()
+	buf := make([]byte, 1500)
+	for {
+		if _, _, err := conn.ReadFrom(buf); err != nil {
+			return http.ErrServerClosed
+		}
+	}
+}
+
+func (s *udpHTTP3Server) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	if s.conn != nil {
+		return s.conn.Close()
+	}
+	return nil
+}
+
+// TestUpgradeHelper was the server process which was upgraded by TestUpgrade.
+func TestUpgradeHelper(t *testing.T) {
+	if os.Getenv("TEST_UPGRADE_HELPER") != "1" {
+		return
+	}
+	gin.SetMode(gin.ReleaseMode)
+	cfg := DefaultConfig()
+	cfg.API.Addr = "127.0.0.1:0"
+	cfg.Admin.Addr = "127.0.0.1:0"
+	cfg.Shutdown = 5 * time.Second
+	cfg.UpgradeTimeout = 10 * time.Second
+	srv, err := New(cfg, nil)
+	if err != nil {
+		fmt.Println("error:", err)
+		os.Exit(1)
+	}
+	if addr := os.Getenv("TEST_UPGRADE_HTTP3_ADDR"); addr != "" {
+		srv.SetHTTP3Server(&udpHTTP3Server{addr: addr})
+	}
+	srv.GetAPIRouteGroup().GET("/pid", func(c *gin.Context) {
+		if c.Query("sleep") != "" {
+			d, _ := time.ParseDuration(c.Query("sleep"))
+			time.Sleep(d)
+		}
+		c.String(http.StatusOK, strconv.Itoa(os.Getpid()))
+	})
+	if err := srv.Run(); err != nil {
+		fmt.Println("error:", err)
+		os.Exit(1)
+	}
+	fmt.Printf("listening %d %s %s\n", os.Getpid(), srv.APIAddrs()[0], srv.AdminAddrs()[0])
+	if err := srv.WaitForUpgrade(syscall.SIGUSR2); err != nil {
+		fmt.Println("error:", err)
+		os.Exit(1)
+	}
+	fmt.Printf("exited %d\n", os.Getpid())
+	os.Exit(0)
+}
+
+func getPid(t *testing.T, client *http.Client, url string) (int, error) {
+	resp, err := client.Get(url)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("unexpected status: %d", resp.StatusCode)
+	}
+	return strconv.Atoi(string(body))
+}
+
+func TestUpgrade(t *testing.T) {
+	cmd := exec.Command(os.Args[0], "-test.run=^TestUpgradeHelper$")
+	// the http3 address would be bound by the upgraded process after the parent released it
+	udpConn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.Nil(t, err)
+	http3Addr := udpConn.LocalAddr().String()
+	require.Nil(t, udpConn.Close())
+	// the LISTEN_PID inherited from systemd shouldn't be passed to the upgraded process
+	cmd.Env = append(os.Environ(), "TEST_UPGRADE_HELPER=1", "LISTEN_PID=1", "TEST_UPGRADE_HTTP3_ADDR="+http3Addr)
+	stdout, err := cmd.StdoutPipe()
+	require.Nil(t, err)
+	require.Nil(t, cmd.Start())
+	// the upgraded process shares the stdout and would be killed at the end
+	lines := make(chan string, 1024)
+	go func() {
+		scanner := bufio.NewScanner(stdout)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+		close(lines)
+	}()
+	// readLine skips the access logs which were also written to the stdout
+	readLine := func() string {
+		timeout := time.After(10 * time.Second)
+		for {
+			select {
+			case line := <-lines:
+				if strings.HasPrefix(line, "listening") || strings.HasPrefix(line, "exited") ||
+					strings.HasPrefix(line, "error") {
+					return line
+				}
+			case <-timeout:
+				t.Fatal("read the output of server timed out")
+				return ""
+			}
+		}
+	}
+
+	fields := strings.Fields(readLine())
+	require.Len(t, fields, 4, fields)
+	require.Equal(t, "listening", fields[0])
+	parentPid, _ := strconv.Atoi(fields[1])
+	apiURL, adminAddr := "http://"+fields[2]+"/pid", fields[3]
+	client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}, Timeout: 5 * time.Second}
+	pid, err := getPid(t, client, apiURL)
+	require.Nil(t, err)
+	require.Equal(t, parentPid, pid)
+
+	// the in-flight request would be drained by the parent
+	slow := make(chan error, 1)
+	go func() {
+		pid, err := getPid(t, client, apiURL+"?sleep=500ms")
+		if err == nil && pid != parentPid {
+			err = fmt.Errorf("the slow request was served by %d", pid)
+		}
+		slow <- err
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	// the requests shouldn't fail during the handoff
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	var failures []error
+	var mu sync.Mutex
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			if _, err := getPid(t, client, apiURL); err != nil {
+				mu.Lock()
+				failures = append(failures, err)
+				mu.Unlock()
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}()
+
+	require.Nil(t, cmd.Process.Signal(syscall.SIGUSR2))
+	fields = strings.Fields(readLine())
+	require.Len(t, fields, 4, fields)
+	childPid, _ := strconv.Atoi(fields[1])
+	defer syscall.Kill(childPid, syscall.SIGKILL)
+	assert.NotEqual(t, parentPid, childPid)
+	assert.Equal(t, "http://"+fields[2]+"/pid", apiURL, "the api listener should be passed")
+	assert.Equal(t, adminAddr, fields[3], "the admin listener should be passed")
+
+	assert.Nil(t, <-slow)
+	assert.Equal(t, fmt.Sprintf("exited %d", parentPid), readLine())
+	// cmd.Wait would close the stdout which was shared with the upgraded process
+	state, err := cmd.Process.Wait()
+	require.Nil(t, err)
+	assert.True(t, state.Success())
+
+	pid, err = getPid(t, client, apiURL)
+	require.Nil(t, err)
+	assert.Equal(t, childPid, pid)
+	assert.Eventually(t, func() bool {
+		conn, err := net.ListenPacket("udp", http3Addr)
+		if err == nil {
+			conn.Close()
+		}
+		return errors.Is(err, syscall.EADDRINUSE)
+	}, 5*time.Second, 50*time.Millisecond, "the http3 address should be bound by the upgraded process")
+	close(stop)
+	wg.Wait()
+	assert.Empty(t, failures)
+}
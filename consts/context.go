@@ -13,4 +13,17 @@ const (
 	ContextKeyMetricLabel        ContextKey = "metricLabel"
 	ContextStartTimeKey          ContextKey = "startTime"
 	ContextSegmentKey            ContextKey = "segment"
+	ContextKeyHTTPClientRoute    ContextKey = "httpClientRoute"
+	ContextKeyResponseFormat     ContextKey = "responseFormat"
+	ContextKeyLocale             ContextKey = "locale"
+	ContextKeyResponseMessage    ContextKey = "responseMessage"
+	ContextKeyCacheTags          ContextKey = "cacheTags"
+	ContextKeyUncompressedSize   ContextKey = "uncompressedSize"
+	ContextKeyPriority           ContextKey = "priority"
+	ContextKeyTimedOut           ContextKey = "timedOut"
+	ContextKeySession            ContextKey = "session"
+	ContextKeySessionRoute       ContextKey = "sessionRoute"
+	ContextKeySessionRegistry    ContextKey = "sessionRegistry"
+	ContextKeyCSPNonce           ContextKey = "cspNonce"
+	ContextKeyClientIP           ContextKey = "clientIP"
 )
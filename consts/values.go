@@ -4,6 +4,8 @@ const (
 	HeaderAMTraceID          = "am-trace-id"
 	HeaderXCloudTraceContext = "x-cloud-trace-context"
 	HeaderEnableDebugLogging = "Enable-Debug-Log"
+	HeaderRequestTimeout     = "X-Request-Timeout"
+	HeaderGRPCTimeout        = "grpc-timeout"
 
 	KeyAMTraceID          = "am_trace_id"
 	KeyCloudflareRay      = "cloudflare_ray"
@@ -29,5 +31,9 @@ const (
 		`"http_x_real_ip":"%{X-Real-IP}i",` +
 		`"http_x_forwarded_for":"%{X-Forwarded-For}i",` +
 		`"content_length":"${Content-Length}",` +
-		`"body_bytes_sent":"%B bytes"}`
+		`"body_bytes_sent":"%B bytes",` +
+		`"body_bytes_uncompressed":"%Z bytes",` +
+		`"timed_out":"%E",` +
+		`"body_bytes_read":"%I bytes",` +
+		`"session":"%S"}`
 )
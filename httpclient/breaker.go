@@ -0,0 +1,219 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/SyntSugar/ss-infra-go/log"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultFailureThreshold = 5
+	defaultOpenTimeout      = 10 * time.Second
+	defaultHalfOpenProbes   = 1
+)
+
+// ErrCircuitOpen was returned when the circuit breaker of the host was open.
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+type breakerState int
+
+const (
+	stateClosed breakerState = iota
+	stateHalfOpen
+	stateOpen
+)
+
+func (s breakerState) String() string {
+	switch s {
+	case stateClosed:
+		return "closed"
+	case stateHalfOpen:
+		return "half_open"
+	default:
+		return "open"
+	}
+}
+
+// BreakerConfig configures the per host circuit breaker, the request which
+// failed with transport error or 5xx status was counted as failure.
+type BreakerConfig struct {
+	// FailureThreshold was the consecutive failures to open the breaker.
+	FailureThreshold int `mapstructure:"failure_threshold"`
+	// OpenTimeout was the duration of open state before probing in half-open state.
+	OpenTimeout time.Duration `mapstructure:"open_timeout"`
+	// HalfOpenProbes was the number of successful probes to close the breaker.
+	HalfOpenProbes int `mapstructure:"half_open_probes"`
+}
+
+func (cfg *BreakerConfig) init() {
+	if cfg.FailureThreshold == 0 {
+		cfg.FailureThreshold = defaultFailureThreshold
+	}
+	if cfg.OpenTimeout == 0 {
+		cfg.OpenTimeout = defaultOpenTimeout
+	}
+	if cfg.HalfOpenProbes == 0 {
+		cfg.HalfOpenProbes = defaultHalfOpenProbes
+	}
+}
+
+type circuitBreaker struct {
+	mu       sync.Mutex
+	host     string
+	cfg      *BreakerConfig
+	logger   *log.Logger
+	state    breakerState
+	failures int
+	openedAt time.Time
+	// generation was increased by each state change, the results of the requests
+	// which were allowed in the previous states were ignored.
+	generation uint64
+	// inflight and successes count the probes in half-open state
+	inflight  int
+	successes int
+}
+
+// breakerToken was the generation of breaker when the request was allowed.
+type breakerToken uint64
+
+// allow returns ErrCircuitOpen if the request was not allowed, or the token to record the result.
+func (b *circuitBreaker) allow() (breakerToken, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case stateOpen:
+		if time.Since(b.openedAt) < b.cfg.OpenTimeout {
+			return 0, ErrCircuitOpen
+		}
+		b.setState(stateHalfOpen)
+		fallthrough
+	case stateHalfOpen:
+		if b.inflight+b.successes >= b.cfg.HalfOpenProbes {
+			return 0, ErrCircuitOpen
+		}
+		b.inflight++
+	}
+	return breakerToken(b.generation), nil
+}
+
+// record records the result of the request, it's ignored if the state was changed after the
+// request was allowed, e.g. the request allowed in closed state finished in half-open state.
+func (b *circuitBreaker) record(token breakerToken, success bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if uint64(token) != b.generation {
+		return
+	}
+	switch b.state {
+	case stateClosed:
+		if success {
+			b.failures = 0
+			return
+		}
+		b.failures++
+		if b.failures >= b.cfg.FailureThreshold {
+			b.setState(stateOpen)
+		}
+	case stateHalfOpen:
+		b.inflight--
+		if !success {
+			b.setState(stateOpen)
+			return
+		}
+		b.successes++
+		if b.successes >= b.cfg.HalfOpenProbes {
+			b.setState(stateClosed)
+		}
+	}
+}
+
+// release gives back the probe slot without recording the result.
+func (b *circuitBreaker) release(token breakerToken) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == stateHalfOpen && uint64(token) == b.generation {
+		b.inflight--
+	}
+}
+
+// setState should be called with the lock held.
+func (b *circuitBreaker) setState(state breakerState) {
+	from := b.state
+	b.state = state
+	b.generation++
+	b.failures = 0
+	b.inflight = 0
+	b.successes = 0
+	if state == stateOpen {
+		b.openedAt = time.Now()
+	}
+
+	metrics.BreakerState.With(prometheus.Labels{"host": b.host}).Set(float64(state))
+	metrics.BreakerTransitions.With(prometheus.Labels{
+		"host": b.host,
+		"from": from.String(),
+		"to":   state.String(),
+	}).Inc()
+	logger := b.logger
+	if logger == nil {
+		logger = log.GlobalLogger()
+	}
+	logger.Warn("Circuit breaker state changed",
+		zap.String("host", b.host),
+		zap.String("from", from.String()),
+		zap.String("to", state.String()),
+	)
+}
+
+type breakerTransport struct {
+	next     http.RoundTripper
+	cfg      *BreakerConfig
+	logger   *log.Logger
+	mu       sync.Mutex
+	breakers map[string]*circuitBreaker
+}
+
+func newBreakerTransport(next http.RoundTripper, cfg *BreakerConfig, logger *log.Logger) http.RoundTripper {
+	cfg.init()
+	return &breakerTransport{
+		next:     next,
+		cfg:      cfg,
+		logger:   logger,
+		breakers: make(map[string]*circuitBreaker),
+	}
+}
+
+func (t *breakerTransport) breaker(host string) *circuitBreaker {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	b, ok := t.breakers[host]
+	if !ok {
+		b = &circuitBreaker{host: host, cfg: t.cfg, logger: t.logger}
+		t.breakers[host] = b
+	}
+	return b
+}
+
+func (t *breakerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	b := t.breaker(req.URL.Host)
+	token, err := b.allow()
+	if err != nil {
+		metrics.BreakerRejected.With(prometheus.Labels{"host": req.URL.Host}).Inc()
+		return nil, err
+	}
+	resp, err := t.next.RoundTrip(req)
+	// the request canceled by caller(e.g. the losing hedged request) was not the fault of the host
+	if err != nil && errors.Is(req.Context().Err(), context.Canceled) {
+		b.release(token)
+		return resp, err
+	}
+	b.record(token, err == nil && resp.StatusCode < http.StatusInternalServerError)
+	return resp, err
+}
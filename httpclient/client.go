@@ -0,0 +1,109 @@
+package httpclient
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/SyntSugar/ss-infra-go/consts"
+	"github.com/SyntSugar/ss-infra-go/log"
+)
+
+// New creates the http client which was instrumented with tracing, metrics,
+// trace id forwarding and logging.
+func New(cfg *Config, logger *log.Logger) (*http.Client, error) {
+	if cfg == nil {
+		cfg = DefaultConfig()
+	}
+	cfg.init()
+	transport, err := NewTransport(cfg, logger)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Client{
+		Transport: transport,
+		Timeout:   cfg.Timeout,
+	}, nil
+}
+
+// NewTransport creates the http.Transport with the connection pool settings
+// and wraps it with the instrumentation.
+func NewTransport(cfg *Config, logger *log.Logger) (http.RoundTripper, error) {
+	if cfg == nil {
+		cfg = DefaultConfig()
+	}
+	cfg.init()
+	dialer := &net.Dialer{
+		Timeout:   cfg.DialTimeout,
+		KeepAlive: cfg.KeepAlive,
+	}
+	transport := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     true,
+		TLSHandshakeTimeout:   cfg.TLSHandshakeTimeout,
+		ResponseHeaderTimeout: cfg.ResponseHeaderTimeout,
+		IdleConnTimeout:       cfg.IdleConnTimeout,
+		MaxIdleConns:          cfg.MaxIdleConns,
+		MaxIdleConnsPerHost:   cfg.MaxIdleConnsPerHost,
+		MaxConnsPerHost:       cfg.MaxConnsPerHost,
+	}
+	if cfg.TLS != nil {
+		tlsConfig, err := cfg.TLS.Build()
+		if err != nil {
+			return nil, fmt.Errorf("build tls config err: %w", err)
+		}
+		transport.TLSClientConfig = tlsConfig
+	}
+	return Wrap(transport, cfg, logger), nil
+}
+
+// Wrap instruments the base round tripper, the http.DefaultTransport would be used if base was nil.
+func Wrap(base http.RoundTripper, cfg *Config, logger *log.Logger) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if cfg == nil {
+		cfg = DefaultConfig()
+	}
+	cfg.init()
+
+	var rt http.RoundTripper = &headerForwarder{next: base}
+	rt = &accessLogger{
+		next:                 rt,
+		logger:               logger,
+		enabled:              cfg.EnableAccessLog,
+		slowRequestThreshold: cfg.SlowRequestThreshold,
+	}
+	// metrics were collected per attempt, and the span covers all attempts
+	rt = &metricsCollector{next: rt}
+	rt = NewResilientTransport(rt, cfg.Resilience, logger)
+	if cfg.EnableTracing {
+		rt = newTracer(rt)
+	}
+	return rt
+}
+
+// WithRoute sets the route template(e.g. /users/:id) of the outbound request,
+// it was used as the metric label and span name instead of the raw path to keep
+// the cardinality low.
+func WithRoute(parent context.Context, route string) context.Context {
+	if parent == nil {
+		return nil
+	}
+	return context.WithValue(parent, consts.ContextKeyHTTPClientRoute, route)
+}
+
+// GetRoute returns the route template of the outbound request.
+func GetRoute(ctx context.Context) string {
+	route, _ := ctx.Value(consts.ContextKeyHTTPClientRoute).(string)
+	return route
+}
+
+func routeOf(req *http.Request) string {
+	if route := GetRoute(req.Context()); route != "" {
+		return route
+	}
+	return "-"
+}
@@ -0,0 +1,84 @@
+package httpclient
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/SyntSugar/ss-infra-go/consts"
+	"github.com/SyntSugar/ss-infra-go/log"
+	"github.com/SyntSugar/ss-infra-go/tracing"
+	"github.com/SyntSugar/ss-infra-go/tracing/tracingtest"
+)
+
+func TestClient(t *testing.T) {
+	recorder := tracingtest.Install(t)
+	var received http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Clone()
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	cfg := DefaultConfig()
+	cfg.EnableAccessLog = true
+	client, err := New(cfg, nil)
+	require.Nil(t, err)
+
+	ctx := tracing.WithAmTraceID(context.Background(), "trace-123")
+	ctx = log.DynamicDebugLogging(ctx)
+	ctx = WithRoute(ctx, "/users/:id")
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, server.URL+"/users/1", strings.NewReader("{}"))
+	resp, err := client.Do(req)
+	require.Nil(t, err)
+	resp.Body.Close()
+
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	assert.Equal(t, "trace-123", received.Get(consts.HeaderAMTraceID))
+	assert.Equal(t, "true", received.Get(consts.HeaderEnableDebugLogging))
+	assert.NotEmpty(t, received.Get("traceparent"))
+	remaining, err := strconv.Atoi(received.Get(consts.HeaderRequestTimeout))
+	require.Nil(t, err)
+	assert.True(t, remaining > 0 && remaining <= 10000)
+	assert.Empty(t, req.Header.Get(consts.HeaderAMTraceID), "the original request shouldn't be modified")
+
+	recorder.AssertSpanNames(t, "POST /users/:id")
+	recorder.AssertAttribute(t, "POST /users/:id", "http.status_code", int64(http.StatusCreated))
+
+	labels := prometheus.Labels{
+		"host":   strings.TrimPrefix(server.URL, "http://"),
+		"route":  "/users/:id",
+		"method": http.MethodPost,
+		"code":   "201",
+	}
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.HTTPCodes.With(labels)))
+}
+
+func TestClientError(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	addr := server.URL
+	server.Close()
+
+	client, err := New(nil, nil)
+	require.Nil(t, err)
+	_, err = client.Get(addr)
+	assert.NotNil(t, err)
+
+	labels := prometheus.Labels{
+		"host":   strings.TrimPrefix(addr, "http://"),
+		"route":  "-",
+		"method": http.MethodGet,
+	}
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.Errors.With(labels)))
+}
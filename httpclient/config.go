@@ -0,0 +1,83 @@
+package httpclient
+
+import (
+	"time"
+
+	"github.com/SyntSugar/ss-infra-go/datastore"
+)
+
+const (
+	defaultTimeout              = 5 * time.Second
+	defaultDialTimeout          = time.Second
+	defaultKeepAlive            = 30 * time.Second
+	defaultTLSHandshakeTimeout  = time.Second
+	defaultIdleConnTimeout      = 90 * time.Second
+	defaultMaxIdleConns         = 256
+	defaultMaxIdleConnsPerHost  = 32
+	defaultSlowRequestThreshold = 1500 * time.Millisecond
+)
+
+type Config struct {
+	// Timeout was the whole timeout of the request including reading the response body.
+	Timeout               time.Duration `mapstructure:"timeout"`
+	DialTimeout           time.Duration `mapstructure:"dial_timeout"`
+	KeepAlive             time.Duration `mapstructure:"keep_alive"`
+	TLSHandshakeTimeout   time.Duration `mapstructure:"tls_handshake_timeout"`
+	ResponseHeaderTimeout time.Duration `mapstructure:"response_header_timeout"`
+	IdleConnTimeout       time.Duration `mapstructure:"idle_conn_timeout"`
+	MaxIdleConns          int           `mapstructure:"max_idle_conns"`
+	MaxIdleConnsPerHost   int           `mapstructure:"max_idle_conns_per_host"`
+	MaxConnsPerHost       int           `mapstructure:"max_conns_per_host"`
+
+	TLS *datastore.ClientTLSConfig `mapstructure:"tls"`
+	// Resilience enables the retries, hedged requests and circuit breaker.
+	Resilience *ResilienceConfig `mapstructure:"resilience"`
+
+	// EnableTracing creates the client span and propagates it to the downstream.
+	EnableTracing bool `mapstructure:"enable_tracing"`
+	// EnableAccessLog logs every outbound request, otherwise only the failed
+	// and slow requests(exceed SlowRequestThreshold) would be logged.
+	EnableAccessLog      bool          `mapstructure:"enable_access_log"`
+	SlowRequestThreshold time.Duration `mapstructure:"slow_request_threshold"`
+}
+
+func DefaultConfig() *Config {
+	return &Config{
+		Timeout:              defaultTimeout,
+		DialTimeout:          defaultDialTimeout,
+		KeepAlive:            defaultKeepAlive,
+		TLSHandshakeTimeout:  defaultTLSHandshakeTimeout,
+		IdleConnTimeout:      defaultIdleConnTimeout,
+		MaxIdleConns:         defaultMaxIdleConns,
+		MaxIdleConnsPerHost:  defaultMaxIdleConnsPerHost,
+		EnableTracing:        true,
+		SlowRequestThreshold: defaultSlowRequestThreshold,
+	}
+}
+
+func (cfg *Config) init() {
+	if cfg.Timeout == 0 {
+		cfg.Timeout = defaultTimeout
+	}
+	if cfg.DialTimeout == 0 {
+		cfg.DialTimeout = defaultDialTimeout
+	}
+	if cfg.KeepAlive == 0 {
+		cfg.KeepAlive = defaultKeepAlive
+	}
+	if cfg.TLSHandshakeTimeout == 0 {
+		cfg.TLSHandshakeTimeout = defaultTLSHandshakeTimeout
+	}
+	if cfg.IdleConnTimeout == 0 {
+		cfg.IdleConnTimeout = defaultIdleConnTimeout
+	}
+	if cfg.MaxIdleConns == 0 {
+		cfg.MaxIdleConns = defaultMaxIdleConns
+	}
+	if cfg.MaxIdleConnsPerHost == 0 {
+		cfg.MaxIdleConnsPerHost = defaultMaxIdleConnsPerHost
+	}
+	if cfg.SlowRequestThreshold == 0 {
+		cfg.SlowRequestThreshold = defaultSlowRequestThreshold
+	}
+}
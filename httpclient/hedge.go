@@ -0,0 +1,116 @@
+package httpclient
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// HedgeConfig configures the hedged requests, another request would be sent
+// if the previous one was not responded after Delay, and the first successful
+// response wins. Only the idempotent requests would be hedged.
+type HedgeConfig struct {
+	Delay time.Duration `mapstructure:"delay"`
+	// MaxHedges was the max number of the extra requests.
+	MaxHedges int `mapstructure:"max_hedges"`
+}
+
+type hedgeTransport struct {
+	next http.RoundTripper
+	cfg  *HedgeConfig
+}
+
+type hedgeResult struct {
+	index  int
+	resp   *http.Response
+	err    error
+	cancel context.CancelFunc
+}
+
+func newHedgeTransport(next http.RoundTripper, cfg *HedgeConfig) http.RoundTripper {
+	return &hedgeTransport{next: next, cfg: cfg}
+}
+
+func (t *hedgeTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.cfg.MaxHedges <= 0 || t.cfg.Delay <= 0 || !isReplayable(req) {
+		return t.next.RoundTrip(req)
+	}
+
+	results := make(chan hedgeResult, t.cfg.MaxHedges+1)
+	cancels := make([]context.CancelFunc, 0, t.cfg.MaxHedges+1)
+	send := func(attemptReq *http.Request) {
+		ctx, cancel := context.WithCancel(req.Context())
+		index := len(cancels)
+		cancels = append(cancels, cancel)
+		go func() {
+			resp, err := t.next.RoundTrip(attemptReq.WithContext(ctx))
+			results <- hedgeResult{index: index, resp: resp, err: err, cancel: cancel}
+		}()
+	}
+	send(req)
+
+	timer := time.NewTimer(t.cfg.Delay)
+	defer timer.Stop()
+	var (
+		last    hedgeResult
+		pending = 1
+	)
+	for pending > 0 {
+		select {
+		case <-timer.C:
+			if len(cancels) > t.cfg.MaxHedges {
+				continue
+			}
+			attemptReq := req.Clone(req.Context())
+			if req.GetBody != nil {
+				body, err := req.GetBody()
+				if err != nil {
+					continue
+				}
+				attemptReq.Body = body
+			}
+			metrics.HedgedRequests.With(prometheus.Labels{"host": req.URL.Host}).Inc()
+			send(attemptReq)
+			pending++
+			timer.Reset(t.cfg.Delay)
+		case result := <-results:
+			pending--
+			if result.err == nil && result.resp.StatusCode < http.StatusInternalServerError {
+				// cancel the others and release their responses in background
+				for i, cancel := range cancels {
+					if i != result.index {
+						cancel()
+					}
+				}
+				go discardResults(results, pending)
+				result.resp.Body = &cancelOnCloseBody{ReadCloser: result.resp.Body, cancel: result.cancel}
+				return result.resp, nil
+			}
+			if last.resp != nil {
+				drainBody(last.resp.Body)
+			}
+			if last.cancel != nil {
+				last.cancel()
+			}
+			last = result
+		}
+	}
+	if last.resp != nil {
+		last.resp.Body = &cancelOnCloseBody{ReadCloser: last.resp.Body, cancel: last.cancel}
+	} else {
+		last.cancel()
+	}
+	return last.resp, last.err
+}
+
+func discardResults(results <-chan hedgeResult, pending int) {
+	for i := 0; i < pending; i++ {
+		result := <-results
+		if result.resp != nil {
+			drainBody(result.resp.Body)
+		}
+		result.cancel()
+	}
+}
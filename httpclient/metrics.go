@@ -0,0 +1,51 @@
+package httpclient
+
+import (
+	prome "github.com/SyntSugar/ss-infra-go/prometheus"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type clientMetrics struct {
+	Latencies *prometheus.HistogramVec
+	HTTPCodes *prometheus.CounterVec
+	Errors    *prometheus.CounterVec
+
+	Retries            *prometheus.CounterVec
+	HedgedRequests     *prometheus.CounterVec
+	BreakerState       *prometheus.GaugeVec
+	BreakerTransitions *prometheus.CounterVec
+	BreakerRejected    *prometheus.CounterVec
+}
+
+var metrics *clientMetrics
+
+const (
+	namespace = "infra"
+	subsystem = "http_client"
+)
+
+func setupMetrics() {
+	labels := []string{"host", "route", "method", "code"}
+	buckets := prometheus.ExponentialBuckets(1, 2, 16)
+	newHistogram := func(name string, labels ...string) *prometheus.HistogramVec {
+		return prome.NewHistogramHelper(namespace, subsystem, name, buckets, labels...)
+	}
+	newCounter := func(name string, labels ...string) *prometheus.CounterVec {
+		return prome.NewCounterHelper(namespace, subsystem, name, labels...)
+	}
+	metrics = &clientMetrics{
+		Latencies: newHistogram("request_latency", labels...),
+		HTTPCodes: newCounter("http_code", labels...),
+		Errors:    newCounter("request_error", "host", "route", "method"),
+
+		Retries:            newCounter("retry", "host", "method"),
+		HedgedRequests:     newCounter("hedged_request", "host"),
+		BreakerState:       prome.NewGaugeHelper(namespace, subsystem, "circuit_breaker_state", "host"),
+		BreakerTransitions: newCounter("circuit_breaker_transition", "host", "from", "to"),
+		BreakerRejected:    newCounter("circuit_breaker_rejected", "host"),
+	}
+}
+
+func init() {
+	setupMetrics()
+}
@@ -0,0 +1,39 @@
+package httpclient
+
+import (
+	"net/http"
+
+	"github.com/SyntSugar/ss-infra-go/log"
+)
+
+// ResilienceConfig composes the resilience policies of the outbound requests,
+// the policy would be disabled when its config was nil.
+type ResilienceConfig struct {
+	Retry   *RetryConfig   `mapstructure:"retry"`
+	Hedge   *HedgeConfig   `mapstructure:"hedge"`
+	Breaker *BreakerConfig `mapstructure:"breaker"`
+}
+
+// NewResilientTransport wraps the next round tripper with retries, hedged requests
+// and the per host circuit breaker. Each retry attempt may be hedged, and each
+// hedged request goes through the circuit breaker, so the open breaker would
+// fail the request fast without retrying.
+func NewResilientTransport(next http.RoundTripper, cfg *ResilienceConfig, logger *log.Logger) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	if cfg == nil {
+		return next
+	}
+	rt := next
+	if cfg.Breaker != nil {
+		rt = newBreakerTransport(rt, cfg.Breaker, logger)
+	}
+	if cfg.Hedge != nil {
+		rt = newHedgeTransport(rt, cfg.Hedge)
+	}
+	if cfg.Retry != nil {
+		rt = newRetryTransport(rt, cfg.Retry)
+	}
+	return rt
+}
@@ -0,0 +1,194 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetry(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.Header().Set(headerRetryAfter, "0")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	rt := NewResilientTransport(nil, &ResilienceConfig{
+		Retry: &RetryConfig{MaxAttempts: 3, InitialInterval: time.Millisecond},
+	}, nil)
+	client := &http.Client{Transport: rt}
+
+	req, _ := http.NewRequest(http.MethodPut, server.URL, strings.NewReader("body"))
+	resp, err := client.Do(req)
+	require.Nil(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.EqualValues(t, 3, atomic.LoadInt32(&calls))
+
+	// non-idempotent request should not be retried
+	atomic.StoreInt32(&calls, 0)
+	resp, err = client.Post(server.URL, "text/plain", strings.NewReader("body"))
+	require.Nil(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	assert.EqualValues(t, 1, atomic.LoadInt32(&calls))
+
+	// the deadline budget was not enough to wait for Retry-After
+	atomic.StoreInt32(&calls, 0)
+	server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Header().Set(headerRetryAfter, "10")
+		w.WriteHeader(http.StatusTooManyRequests)
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	req, _ = http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	resp, err = client.Do(req)
+	require.Nil(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	assert.EqualValues(t, 1, atomic.LoadInt32(&calls))
+}
+
+func TestRetryWithoutBody(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	cfg := DefaultConfig()
+	cfg.Resilience = &ResilienceConfig{
+		Retry: &RetryConfig{MaxAttempts: 3, InitialInterval: time.Millisecond},
+	}
+	client, err := New(cfg, nil)
+	require.Nil(t, err)
+
+	// the GET request has no GetBody, it should be retried without panic
+	resp, err := client.Get(server.URL)
+	require.Nil(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.EqualValues(t, 3, atomic.LoadInt32(&calls))
+
+	atomic.StoreInt32(&calls, 0)
+	req, _ := http.NewRequest(http.MethodDelete, server.URL, http.NoBody)
+	resp, err = client.Do(req)
+	require.Nil(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.EqualValues(t, 3, atomic.LoadInt32(&calls))
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	resp := &http.Response{Header: http.Header{}}
+	assert.Equal(t, time.Duration(0), parseRetryAfter(resp))
+	resp.Header.Set(headerRetryAfter, "3")
+	assert.Equal(t, 3*time.Second, parseRetryAfter(resp))
+	resp.Header.Set(headerRetryAfter, time.Now().Add(time.Minute).UTC().Format(http.TimeFormat))
+	assert.InDelta(t, float64(time.Minute), float64(parseRetryAfter(resp)), float64(2*time.Second))
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	var healthy atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if healthy.Load() {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: NewResilientTransport(nil, &ResilienceConfig{
+		Breaker: &BreakerConfig{FailureThreshold: 2, OpenTimeout: 50 * time.Millisecond},
+	}, nil)}
+
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(server.URL)
+		require.Nil(t, err)
+		resp.Body.Close()
+	}
+	_, err := client.Get(server.URL)
+	assert.True(t, errors.Is(err, ErrCircuitOpen))
+
+	// probe in half-open state after the open timeout
+	healthy.Store(true)
+	time.Sleep(60 * time.Millisecond)
+	resp, err := client.Get(server.URL)
+	require.Nil(t, err)
+	resp.Body.Close()
+	resp, err = client.Get(server.URL)
+	require.Nil(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestCircuitBreakerStaleResult(t *testing.T) {
+	cfg := &BreakerConfig{FailureThreshold: 1, OpenTimeout: 10 * time.Millisecond}
+	cfg.init()
+	b := &circuitBreaker{host: "stale", cfg: cfg}
+
+	// the request was allowed in closed state and finished in half-open state
+	stale, err := b.allow()
+	require.Nil(t, err)
+	token, err := b.allow()
+	require.Nil(t, err)
+	b.record(token, false)
+	assert.Equal(t, stateOpen, b.state)
+	time.Sleep(20 * time.Millisecond)
+	probe, err := b.allow()
+	require.Nil(t, err)
+	assert.Equal(t, stateHalfOpen, b.state)
+
+	b.record(stale, true)
+	b.release(stale)
+	assert.Equal(t, stateHalfOpen, b.state)
+	_, err = b.allow()
+	assert.True(t, errors.Is(err, ErrCircuitOpen))
+	b.record(probe, true)
+	assert.Equal(t, stateClosed, b.state)
+}
+
+func TestHedge(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			// the first request was slow
+			select {
+			case <-time.After(time.Second):
+			case <-r.Context().Done():
+			}
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: NewResilientTransport(nil, &ResilienceConfig{
+		Hedge: &HedgeConfig{Delay: 20 * time.Millisecond, MaxHedges: 1},
+	}, nil)}
+	startTime := time.Now()
+	resp, err := client.Get(server.URL)
+	require.Nil(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Less(t, time.Since(startTime), 500*time.Millisecond)
+	assert.EqualValues(t, 2, atomic.LoadInt32(&calls))
+}
@@ -0,0 +1,221 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	defaultMaxAttempts         = 3
+	defaultInitialInterval     = 100 * time.Millisecond
+	defaultMaxInterval         = 2 * time.Second
+	defaultMultiplier          = 2.0
+	defaultRandomizationFactor = 0.5
+
+	headerRetryAfter     = "Retry-After"
+	headerIdempotencyKey = "Idempotency-Key"
+)
+
+var defaultRetryableStatusCodes = []int{
+	http.StatusTooManyRequests,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+// RetryConfig configures the retries with exponential backoff and jitter,
+// only the idempotent requests(or with the Idempotency-Key header) would be retried.
+type RetryConfig struct {
+	// MaxAttempts was the max attempts including the first one.
+	MaxAttempts         int           `mapstructure:"max_attempts"`
+	InitialInterval     time.Duration `mapstructure:"initial_interval"`
+	MaxInterval         time.Duration `mapstructure:"max_interval"`
+	Multiplier          float64       `mapstructure:"multiplier"`
+	RandomizationFactor float64       `mapstructure:"randomization_factor"`
+	// PerAttemptTimeout limits every attempt, the remaining deadline of the
+	// request context would be used when it's shorter.
+	PerAttemptTimeout    time.Duration `mapstructure:"per_attempt_timeout"`
+	RetryableStatusCodes []int         `mapstructure:"retryable_status_codes"`
+}
+
+func (cfg *RetryConfig) init() {
+	if cfg.MaxAttempts == 0 {
+		cfg.MaxAttempts = defaultMaxAttempts
+	}
+	if cfg.InitialInterval == 0 {
+		cfg.InitialInterval = defaultInitialInterval
+	}
+	if cfg.MaxInterval == 0 {
+		cfg.MaxInterval = defaultMaxInterval
+	}
+	if cfg.Multiplier == 0 {
+		cfg.Multiplier = defaultMultiplier
+	}
+	if cfg.RandomizationFactor == 0 {
+		cfg.RandomizationFactor = defaultRandomizationFactor
+	}
+	if len(cfg.RetryableStatusCodes) == 0 {
+		cfg.RetryableStatusCodes = defaultRetryableStatusCodes
+	}
+}
+
+type retryTransport struct {
+	next      http.RoundTripper
+	cfg       *RetryConfig
+	retryable map[int]struct{}
+}
+
+func newRetryTransport(next http.RoundTripper, cfg *RetryConfig) http.RoundTripper {
+	cfg.init()
+	retryable := make(map[int]struct{}, len(cfg.RetryableStatusCodes))
+	for _, code := range cfg.RetryableStatusCodes {
+		retryable[code] = struct{}{}
+	}
+	return &retryTransport{next: next, cfg: cfg, retryable: retryable}
+}
+
+func (t *retryTransport) newBackOff() backoff.BackOff {
+	b := backoff.NewExponentialBackOff()
+	b.InitialInterval = t.cfg.InitialInterval
+	b.MaxInterval = t.cfg.MaxInterval
+	b.Multiplier = t.cfg.Multiplier
+	b.RandomizationFactor = t.cfg.RandomizationFactor
+	// the attempts and deadline budget were used to stop retrying instead of the elapsed time
+	b.MaxElapsedTime = 0
+	b.Reset()
+	return b
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.cfg.MaxAttempts <= 1 || !isReplayable(req) {
+		return t.roundTripOnce(req)
+	}
+
+	ctx := req.Context()
+	b := t.newBackOff()
+	for attempt := 1; ; attempt++ {
+		attemptReq := req
+		if attempt > 1 {
+			attemptReq = req.Clone(ctx)
+			// the request without body(nil or http.NoBody) has no GetBody and could be sent as it's
+			if req.GetBody != nil {
+				body, err := req.GetBody()
+				if err != nil {
+					return nil, err
+				}
+				attemptReq.Body = body
+			}
+		}
+		resp, err := t.roundTripOnce(attemptReq)
+		if attempt >= t.cfg.MaxAttempts || !t.shouldRetry(ctx, resp, err) {
+			return resp, err
+		}
+
+		wait := b.NextBackOff()
+		if retryAfter := parseRetryAfter(resp); retryAfter > wait {
+			wait = retryAfter
+		}
+		// give up retrying when the remaining deadline budget was not enough to wait
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) <= wait {
+			return resp, err
+		}
+		if resp != nil {
+			drainBody(resp.Body)
+		}
+		metrics.Retries.With(prometheus.Labels{"host": req.URL.Host, "method": req.Method}).Inc()
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// roundTripOnce sends the request with the per attempt timeout.
+func (t *retryTransport) roundTripOnce(req *http.Request) (*http.Response, error) {
+	if t.cfg.PerAttemptTimeout <= 0 {
+		return t.next.RoundTrip(req)
+	}
+	ctx, cancel := context.WithTimeout(req.Context(), t.cfg.PerAttemptTimeout)
+	resp, err := t.next.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	resp.Body = &cancelOnCloseBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+func (t *retryTransport) shouldRetry(ctx context.Context, resp *http.Response, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	if err != nil {
+		return !errors.Is(err, ErrCircuitOpen)
+	}
+	_, ok := t.retryable[resp.StatusCode]
+	return ok
+}
+
+// isIdempotent returns whether the request was safe to send more than once.
+func isIdempotent(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return req.Header.Get(headerIdempotencyKey) != ""
+}
+
+// isReplayable returns whether the request was idempotent and its body could be read again.
+func isReplayable(req *http.Request) bool {
+	if !isIdempotent(req) {
+		return false
+	}
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+// parseRetryAfter parses the Retry-After header in delay seconds or http date.
+func parseRetryAfter(resp *http.Response) time.Duration {
+	if resp == nil {
+		return 0
+	}
+	value := resp.Header.Get(headerRetryAfter)
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return time.Until(date)
+	}
+	return 0
+}
+
+// drainBody reads a little of body to reuse the connection before closing it.
+func drainBody(body io.ReadCloser) {
+	_, _ = io.CopyN(io.Discard, body, 4096)
+	_ = body.Close()
+}
+
+// cancelOnCloseBody cancels the context of request after the body was closed.
+type cancelOnCloseBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnCloseBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
@@ -0,0 +1,157 @@
+package httpclient
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/SyntSugar/ss-infra-go/consts"
+	"github.com/SyntSugar/ss-infra-go/log"
+	"github.com/SyntSugar/ss-infra-go/tracing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+	oteltrace "go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+// headerForwarder forwards the am-trace-id, debug logging and deadline headers from the context.
+type headerForwarder struct {
+	next http.RoundTripper
+}
+
+func (f *headerForwarder) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	traceID := tracing.GetAmTraceID(ctx)
+	debug, _ := ctx.Value(consts.ContextKeyEnableDebugLogging).(bool)
+	deadline, hasDeadline := ctx.Deadline()
+	if (traceID == "" || req.Header.Get(consts.HeaderAMTraceID) != "") &&
+		(!debug || req.Header.Get(consts.HeaderEnableDebugLogging) != "") &&
+		(!hasDeadline || req.Header.Get(consts.HeaderRequestTimeout) != "") {
+		return f.next.RoundTrip(req)
+	}
+
+	// RoundTrip should not modify the request, so clone it before setting headers.
+	req = req.Clone(ctx)
+	if traceID != "" && req.Header.Get(consts.HeaderAMTraceID) == "" {
+		req.Header.Set(consts.HeaderAMTraceID, traceID)
+	}
+	if debug && req.Header.Get(consts.HeaderEnableDebugLogging) == "" {
+		req.Header.Set(consts.HeaderEnableDebugLogging, "true")
+	}
+	// propagate the remaining time of the deadline to let the downstream give up in time
+	if hasDeadline && req.Header.Get(consts.HeaderRequestTimeout) == "" {
+		if remaining := time.Until(deadline).Milliseconds(); remaining > 0 {
+			req.Header.Set(consts.HeaderRequestTimeout, strconv.FormatInt(remaining, 10))
+		}
+	}
+	return f.next.RoundTrip(req)
+}
+
+// accessLogger logs the outbound requests like the access log of the api server.
+type accessLogger struct {
+	next                 http.RoundTripper
+	logger               *log.Logger
+	enabled              bool
+	slowRequestThreshold time.Duration
+}
+
+func (l *accessLogger) RoundTrip(req *http.Request) (*http.Response, error) {
+	startTime := time.Now()
+	resp, err := l.next.RoundTrip(req)
+	latency := time.Since(startTime)
+	if err == nil && !l.enabled && (l.slowRequestThreshold <= 0 || latency < l.slowRequestThreshold) {
+		return resp, err
+	}
+
+	logger := l.logger
+	if logger == nil {
+		logger = log.GlobalLogger()
+	}
+	fields := []zap.Field{
+		zap.String("category", "http_client_access_log"),
+		zap.String("method", req.Method),
+		zap.String("host", req.URL.Host),
+		zap.String("url", req.URL.Redacted()),
+		zap.String("route", routeOf(req)),
+		zap.Int64("request_time", latency.Milliseconds()),
+	}
+	if err != nil {
+		logger.WarnCtx(req.Context(), "HTTPClientAccessLog", append(fields, zap.Error(err))...)
+		return resp, err
+	}
+	fields = append(fields,
+		zap.Int("status", resp.StatusCode),
+		zap.Int64("content_length", resp.ContentLength),
+	)
+	logger.InfoCtx(req.Context(), "HTTPClientAccessLog", fields...)
+	return resp, err
+}
+
+// metricsCollector collects the RED metrics of the outbound requests.
+type metricsCollector struct {
+	next http.RoundTripper
+}
+
+func (c *metricsCollector) RoundTrip(req *http.Request) (*http.Response, error) {
+	startTime := time.Now()
+	resp, err := c.next.RoundTrip(req)
+	latency := time.Since(startTime).Milliseconds()
+
+	code := "-"
+	if err == nil {
+		code = strconv.Itoa(resp.StatusCode)
+	}
+	labels := prometheus.Labels{
+		"host":   req.URL.Host,
+		"route":  routeOf(req),
+		"method": req.Method,
+		"code":   code,
+	}
+	metrics.Latencies.With(labels).Observe(float64(latency))
+	if err != nil {
+		delete(labels, "code")
+		metrics.Errors.With(labels).Inc()
+		return resp, err
+	}
+	metrics.HTTPCodes.With(labels).Inc()
+	return resp, err
+}
+
+// tracer creates the client span and injects it into the request headers.
+type tracer struct {
+	next http.RoundTripper
+}
+
+func newTracer(next http.RoundTripper) http.RoundTripper {
+	return &tracer{next: next}
+}
+
+func (t *tracer) RoundTrip(req *http.Request) (*http.Response, error) {
+	spanName := fmt.Sprintf("HTTP %s", req.Method)
+	if route := GetRoute(req.Context()); route != "" {
+		spanName = fmt.Sprintf("%s %s", req.Method, route)
+	}
+	// use the global ones in every request, so the provider could be set after the client was created
+	ctx, span := otel.GetTracerProvider().Tracer(consts.OtelDefaultTracerName).Start(req.Context(), spanName,
+		oteltrace.WithSpanKind(oteltrace.SpanKindClient),
+		oteltrace.WithAttributes(semconv.HTTPClientAttributesFromHTTPRequest(req)...),
+	)
+	defer span.End()
+
+	req = req.Clone(ctx)
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return resp, err
+	}
+	span.SetAttributes(semconv.HTTPAttributesFromHTTPStatusCode(resp.StatusCode)...)
+	span.SetStatus(semconv.SpanStatusFromHTTPStatusCodeAndSpanKind(resp.StatusCode, oteltrace.SpanKindClient))
+	return resp, err
+}
@@ -0,0 +1,218 @@
+package tracing
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SyntSugar/ss-infra-go/datastore"
+)
+
+// The standard OTEL_* environment variables, the signal specific one(OTEL_EXPORTER_OTLP_TRACES_*)
+// would take precedence over the generic one(OTEL_EXPORTER_OTLP_*).
+// See https://opentelemetry.io/docs/specs/otel/configuration/sdk-environment-variables/
+const (
+	envServiceName        = "OTEL_SERVICE_NAME"
+	envResourceAttributes = "OTEL_RESOURCE_ATTRIBUTES"
+	envSDKDisabled        = "OTEL_SDK_DISABLED"
+	envTracesSampler      = "OTEL_TRACES_SAMPLER"
+	envTracesSamplerArg   = "OTEL_TRACES_SAMPLER_ARG"
+
+	envOTLPPrefix       = "OTEL_EXPORTER_OTLP_"
+	envOTLPTracesPrefix = "OTEL_EXPORTER_OTLP_TRACES_"
+
+	envEndpoint          = "ENDPOINT"
+	envProtocol          = "PROTOCOL"
+	envInsecure          = "INSECURE"
+	envCertificate       = "CERTIFICATE"
+	envClientCertificate = "CLIENT_CERTIFICATE"
+	envClientKey         = "CLIENT_KEY"
+	envHeaders           = "HEADERS"
+	envCompression       = "COMPRESSION"
+	envTimeout           = "TIMEOUT"
+)
+
+// LoadEnv fills the unset fields of config from the standard OTEL_* environment variables,
+// the fields which were set in code would be kept.
+func (config *OTLConfig) LoadEnv() error {
+	if v := os.Getenv(envSDKDisabled); v != "" {
+		disabled, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("invalid %s: %w", envSDKDisabled, err)
+		}
+		if disabled {
+			config.IsExport = false
+		}
+	}
+	switch v := strings.ToLower(os.Getenv(envTracesExporter)); v {
+	case "", "otlp":
+	case "none":
+		config.IsExport = false
+	case "console", LocalExporterStdout:
+		if config.LocalExporter == "" {
+			config.LocalExporter = LocalExporterStdout
+		}
+	default:
+		return fmt.Errorf("unsupported %s: %s", envTracesExporter, v)
+	}
+	if config.ServiceName == "" {
+		config.ServiceName = os.Getenv(envServiceName)
+	}
+	if v := os.Getenv(envResourceAttributes); v != "" {
+		attrs, err := parseKeyValues(v)
+		if err != nil {
+			return fmt.Errorf("invalid %s: %w", envResourceAttributes, err)
+		}
+		if config.ResourceAttributes == nil {
+			config.ResourceAttributes = make(map[string]string, len(attrs))
+		}
+		for k, v := range attrs {
+			if _, ok := config.ResourceAttributes[k]; !ok {
+				config.ResourceAttributes[k] = v
+			}
+		}
+	}
+	if config.Sampler == nil && config.SamplerName == "" {
+		config.SamplerName = os.Getenv(envTracesSampler)
+	}
+	if v := os.Getenv(envTracesSamplerArg); v != "" && config.SamplerRatio == nil {
+		ratio, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return fmt.Errorf("invalid %s: %w", envTracesSamplerArg, err)
+		}
+		config.SamplerRatio = &ratio
+	}
+	if len(config.Propagators) == 0 {
+		config.Propagators = propagatorsFromEnv()
+	}
+	return config.loadExporterEnv()
+}
+
+func (config *OTLConfig) loadExporterEnv() error {
+	if v, name := otlpEnv(envProtocol); v != "" && config.Protocol == "" {
+		switch v {
+		case "grpc":
+			config.Protocol = GRPC
+		case "http/protobuf":
+			config.Protocol = HTTP
+		default:
+			return fmt.Errorf("unsupported %s: %s", name, v)
+		}
+	}
+	if v, name := otlpEnv(envEndpoint); v != "" && config.Endpoint == "" {
+		if err := config.setEndpoint(v, name == envOTLPTracesPrefix+envEndpoint); err != nil {
+			return fmt.Errorf("invalid %s: %w", name, err)
+		}
+	}
+	if v, name := otlpEnv(envInsecure); v != "" && config.TLS == nil {
+		insecure, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("invalid %s: %w", name, err)
+		}
+		if !insecure {
+			config.TLS = &datastore.ClientTLSConfig{}
+		}
+	}
+	caFile, _ := otlpEnv(envCertificate)
+	certFile, _ := otlpEnv(envClientCertificate)
+	keyFile, _ := otlpEnv(envClientKey)
+	if caFile != "" || certFile != "" || keyFile != "" {
+		if config.TLS == nil {
+			config.TLS = &datastore.ClientTLSConfig{}
+		}
+		if config.TLS.CAFile == "" {
+			config.TLS.CAFile = caFile
+		}
+		if config.TLS.CertFile == "" && config.TLS.KeyFile == "" {
+			config.TLS.CertFile = certFile
+			config.TLS.KeyFile = keyFile
+		}
+	}
+	if v, name := otlpEnv(envHeaders); v != "" {
+		headers, err := parseKeyValues(v)
+		if err != nil {
+			return fmt.Errorf("invalid %s: %w", name, err)
+		}
+		if config.Headers == nil {
+			config.Headers = make(map[string]string, len(headers))
+		}
+		for k, v := range headers {
+			if _, ok := config.Headers[k]; !ok {
+				config.Headers[k] = v
+			}
+		}
+	}
+	if v, name := otlpEnv(envCompression); v != "" && config.Compression == "" {
+		if v != CompressionGzip && v != CompressionNone {
+			return fmt.Errorf("unsupported %s: %s", name, v)
+		}
+		config.Compression = v
+	}
+	if v, name := otlpEnv(envTimeout); v != "" && config.Timeout == 0 {
+		ms, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("invalid %s: %w", name, err)
+		}
+		config.Timeout = time.Duration(ms) * time.Millisecond
+	}
+	return nil
+}
+
+// setEndpoint parses the endpoint url from env into the host:port endpoint,
+// it would also set the URLPath when the signal specific endpoint was used
+// and enable TLS when the scheme was https.
+func (config *OTLConfig) setEndpoint(rawURL string, isSignalEndpoint bool) error {
+	if !strings.Contains(rawURL, "://") {
+		config.Endpoint = rawURL
+		return nil
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	config.Endpoint = u.Host
+	if u.Scheme == "https" && config.TLS == nil {
+		config.TLS = &datastore.ClientTLSConfig{}
+	}
+	if config.URLPath == "" {
+		if isSignalEndpoint && u.Path != "" {
+			config.URLPath = u.Path
+		} else if !isSignalEndpoint {
+			config.URLPath = strings.TrimSuffix(u.Path, "/") + "/v1/traces"
+		}
+	}
+	return nil
+}
+
+// otlpEnv returns the value and name of the traces specific env, or the generic one.
+func otlpEnv(key string) (string, string) {
+	if v := strings.TrimSpace(os.Getenv(envOTLPTracesPrefix + key)); v != "" {
+		return v, envOTLPTracesPrefix + key
+	}
+	return strings.TrimSpace(os.Getenv(envOTLPPrefix + key)), envOTLPPrefix + key
+}
+
+// parseKeyValues parses the W3C baggage like format(key1=value1,key2=value2),
+// the values were percent encoded.
+func parseKeyValues(s string) (map[string]string, error) {
+	kvs := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		k, v, found := strings.Cut(pair, "=")
+		k = strings.TrimSpace(k)
+		if !found || k == "" {
+			return nil, fmt.Errorf("malformed key value pair %q", pair)
+		}
+		value, err := url.PathUnescape(strings.TrimSpace(v))
+		if err != nil {
+			return nil, err
+		}
+		kvs[k] = value
+	}
+	return kvs, nil
+}
@@ -0,0 +1,109 @@
+package tracing
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadEnv(t *testing.T) {
+	t.Setenv("OTEL_SERVICE_NAME", "ss-service")
+	t.Setenv("OTEL_RESOURCE_ATTRIBUTES", "deployment.environment=prod,team=infra%20go")
+	t.Setenv("OTEL_TRACES_SAMPLER", "parentbased_traceidratio")
+	t.Setenv("OTEL_TRACES_SAMPLER_ARG", "0.25")
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "https://collector:4318/otlp")
+	t.Setenv("OTEL_EXPORTER_OTLP_PROTOCOL", "grpc")
+	t.Setenv("OTEL_EXPORTER_OTLP_TRACES_PROTOCOL", "http/protobuf")
+	t.Setenv("OTEL_EXPORTER_OTLP_HEADERS", "authorization=Bearer%20token,x-tenant=ss")
+	t.Setenv("OTEL_EXPORTER_OTLP_COMPRESSION", "gzip")
+	t.Setenv("OTEL_EXPORTER_OTLP_TIMEOUT", "5000")
+	t.Setenv("OTEL_EXPORTER_OTLP_CERTIFICATE", "/path/to/ca.pem")
+
+	config := &OTLConfig{Headers: map[string]string{"x-tenant": "code"}}
+	require.Nil(t, config.LoadEnv())
+	assert.Equal(t, "ss-service", config.GetServiceName())
+	assert.Equal(t, map[string]string{"deployment.environment": "prod", "team": "infra go"}, config.ResourceAttributes)
+	assert.Equal(t, SamplerParentBasedTraceIDRatio, config.SamplerName)
+	require.NotNil(t, config.SamplerRatio)
+	assert.Equal(t, 0.25, *config.SamplerRatio)
+	assert.Equal(t, HTTP, config.Protocol)
+	assert.Equal(t, "collector:4318", config.Endpoint)
+	assert.Equal(t, "/otlp/v1/traces", config.URLPath)
+	assert.Equal(t, map[string]string{"authorization": "Bearer token", "x-tenant": "code"}, config.Headers)
+	assert.Equal(t, CompressionGzip, config.Compression)
+	assert.Equal(t, 5*time.Second, config.Timeout)
+	require.NotNil(t, config.TLS)
+	assert.Equal(t, "/path/to/ca.pem", config.TLS.CAFile)
+
+	sampler, err := newSampler(config)
+	require.Nil(t, err)
+	assert.Contains(t, sampler.Description(), "TraceIDRatioBased{0.25}")
+}
+
+func TestLoadEnvKeepCodeConfig(t *testing.T) {
+	t.Setenv("OTEL_SERVICE_NAME", "env-service")
+	t.Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "http://collector:4318/custom/traces")
+	t.Setenv("OTEL_SDK_DISABLED", "true")
+
+	config := &OTLConfig{ServiceName: "code-service", IsExport: true}
+	require.Nil(t, config.LoadEnv())
+	assert.Equal(t, "code-service", config.GetServiceName())
+	assert.Equal(t, "collector:4318", config.Endpoint)
+	assert.Equal(t, "/custom/traces", config.URLPath)
+	assert.Nil(t, config.TLS)
+	assert.False(t, config.IsExport)
+}
+
+func TestLoadEnvInvalid(t *testing.T) {
+	for key, value := range map[string]string{
+		"OTEL_TRACES_SAMPLER_ARG":        "abc",
+		"OTEL_EXPORTER_OTLP_PROTOCOL":    "http/json",
+		"OTEL_EXPORTER_OTLP_TIMEOUT":     "1s",
+		"OTEL_EXPORTER_OTLP_COMPRESSION": "zstd",
+		"OTEL_EXPORTER_OTLP_HEADERS":     "malformed",
+	} {
+		t.Run(key, func(t *testing.T) {
+			t.Setenv(key, value)
+			assert.NotNil(t, (&OTLConfig{}).LoadEnv())
+		})
+	}
+}
+
+func TestNewSampler(t *testing.T) {
+	_, err := newSampler(&OTLConfig{SamplerName: "unknown"})
+	assert.NotNil(t, err)
+
+	sampler, err := newSampler(&OTLConfig{SamplerName: SamplerAlwaysOff})
+	require.Nil(t, err)
+	assert.Equal(t, "AlwaysOffSampler", sampler.Description())
+}
+
+func TestInitOTLProviderWithoutExport(t *testing.T) {
+	headers := map[string]string{"x-tenant": "ss"}
+	t.Setenv("OTEL_EXPORTER_OTLP_HEADERS", "authorization=token")
+	shutdown, err := InitOTLProvider(&OTLConfig{ServiceName: "ss-service", Headers: headers})
+	require.Nil(t, err)
+	assert.Nil(t, shutdown())
+	assert.Equal(t, map[string]string{"x-tenant": "ss"}, headers)
+}
+
+func TestDefaultOTLConfigEnv(t *testing.T) {
+	// the env was loaded by InitOTLProvider instead of at the package init
+	assert.Empty(t, DefaultOTLConfig.Protocol)
+	assert.Empty(t, DefaultOTLConfig.Endpoint)
+	t.Setenv("OTEL_TRACES_SAMPLER_ARG", "invalid")
+	_, err := InitOTLProvider(nil)
+	assert.NotNil(t, err)
+
+	config := DefaultOTLConfig.clone()
+	t.Setenv("OTEL_EXPORTER_OTLP_PROTOCOL", "http/protobuf")
+	t.Setenv("OTEL_TRACES_SAMPLER_ARG", "")
+	require.Nil(t, config.LoadEnv())
+	config.setDefaults()
+	assert.Equal(t, HTTP, config.Protocol)
+	assert.Equal(t, getDefaultOTLEndpoint(), config.Endpoint)
+	assert.Equal(t, "/v1/traces", config.URLPath)
+	assert.NotNil(t, config.Sampler)
+}
@@ -0,0 +1,59 @@
+package tracing
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// The local exporters which don't rely on the collector, they're useful for
+// the tests and the air-gapped debugging.
+const (
+	// LocalExporterStdout writes the spans into stdout in JSON.
+	LocalExporterStdout = "stdout"
+	// LocalExporterFile writes the spans into FilePath in JSON lines.
+	LocalExporterFile = "file"
+	// LocalExporterMemory exports the spans synchronously into SpanExporter,
+	// see tracingtest.Recorder for the in-memory recorder.
+	LocalExporterMemory = "memory"
+
+	envTracesExporter = "OTEL_TRACES_EXPORTER"
+)
+
+// newLocalSpanProcessor returns the span processor of the local exporter and
+// the function to release the resources(e.g. file) after the provider was shutdown.
+func newLocalSpanProcessor(config *OTLConfig) (sdktrace.SpanProcessor, func() error, error) {
+	noop := func() error { return nil }
+	switch config.LocalExporter {
+	case LocalExporterStdout:
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+		if err != nil {
+			return nil, nil, err
+		}
+		return sdktrace.NewBatchSpanProcessor(exporter), noop, nil
+	case LocalExporterFile:
+		if config.FilePath == "" {
+			return nil, nil, errors.New("file path of the file exporter shouldn't be empty")
+		}
+		file, err := os.OpenFile(config.FilePath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+		if err != nil {
+			return nil, nil, fmt.Errorf("open span file(%s) err: %w", config.FilePath, err)
+		}
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(file))
+		if err != nil {
+			file.Close()
+			return nil, nil, err
+		}
+		return sdktrace.NewBatchSpanProcessor(exporter), file.Close, nil
+	case LocalExporterMemory:
+		if config.SpanExporter == nil {
+			return nil, nil, errors.New("span exporter of the memory exporter shouldn't be nil")
+		}
+		return sdktrace.NewSimpleSpanProcessor(config.SpanExporter), noop, nil
+	default:
+		return nil, nil, fmt.Errorf("unsupported local exporter: %s", config.LocalExporter)
+	}
+}
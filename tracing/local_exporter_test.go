@@ -0,0 +1,56 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+)
+
+func TestFileExporter(t *testing.T) {
+	prevProvider := otel.GetTracerProvider()
+	defer otel.SetTracerProvider(prevProvider)
+
+	path := filepath.Join(t.TempDir(), "spans.jsonl")
+	shutdown, err := InitOTLProvider(&OTLConfig{
+		IsExport:      true,
+		SamplerName:   SamplerAlwaysOn,
+		LocalExporter: LocalExporterFile,
+		FilePath:      path,
+	})
+	require.Nil(t, err)
+
+	tracer := otel.Tracer("test")
+	for _, name := range []string{"first", "second"} {
+		_, span := tracer.Start(context.Background(), name)
+		span.End()
+	}
+	require.Nil(t, shutdown())
+
+	bytes, err := os.ReadFile(path)
+	require.Nil(t, err)
+	lines := strings.Split(strings.TrimSpace(string(bytes)), "\n")
+	require.Len(t, lines, 2)
+	for i, name := range []string{"first", "second"} {
+		var span struct{ Name string }
+		require.Nil(t, json.Unmarshal([]byte(lines[i]), &span))
+		assert.Equal(t, name, span.Name)
+	}
+}
+
+func TestLocalExporterInvalid(t *testing.T) {
+	for _, config := range []*OTLConfig{
+		{IsExport: true, LocalExporter: LocalExporterFile},
+		{IsExport: true, LocalExporter: LocalExporterMemory},
+		{IsExport: true, LocalExporter: "unknown"},
+	} {
+		_, err := InitOTLProvider(config)
+		assert.NotNil(t, err, config.LocalExporter)
+	}
+}
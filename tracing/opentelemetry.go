@@ -2,18 +2,22 @@ package tracing
 
 import (
 	"context"
+	"errors"
 	"os"
 	"time"
 
-	"go.opentelemetry.io/contrib/propagators/b3"
+	"github.com/SyntSugar/ss-infra-go/datastore"
+
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
-	"go.opentelemetry.io/otel/sdk/resource"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
-	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+
+	// register the gzip compressor for the gRPC exporter
+	_ "google.golang.org/grpc/encoding/gzip"
 )
 
 type protocol string
@@ -23,82 +27,246 @@ const (
 	HTTP protocol = "HTTP"
 )
 
+const (
+	CompressionGzip = "gzip"
+	CompressionNone = "none"
+
+	defaultConnectTimeout = 3 * time.Second
+)
+
+// RetryConfig configures the retry of the exporter when failed to export spans,
+// it would be disabled if Enabled was false.
+type RetryConfig struct {
+	Enabled         bool
+	InitialInterval time.Duration
+	MaxInterval     time.Duration
+	MaxElapsedTime  time.Duration
+}
+
 type OTLConfig struct {
 	Protocol protocol
 	Endpoint string
-	Sampler  sdktrace.Sampler
-	Exporter string
-	URLPath  string
-	IsExport bool
+	// Sampler would take precedence over the SamplerName and SamplerRatio.
+	Sampler sdktrace.Sampler
+	// SamplerName was one of always_on, always_off, traceidratio, parentbased_always_on,
+	// parentbased_always_off and parentbased_traceidratio.
+	SamplerName string
+	// SamplerRatio was the ratio of traceidratio sampler, default was 1.0 when it's nil.
+	SamplerRatio *float64
+	// Exporter was used as the service name when ServiceName was empty.
+	Exporter    string
+	ServiceName string
+	URLPath     string
+	IsExport    bool
+	// Propagators was the list of propagator names to compose, see NewPropagator
+	// for the supported names. It would read from OTEL_PROPAGATORS when empty.
+	Propagators []string
+
+	// TLS was used to connect the collector in secure, it would be insecure when TLS was nil.
+	TLS         *datastore.ClientTLSConfig
+	Headers     map[string]string
+	Compression string
+	Timeout     time.Duration
+	Retry       *RetryConfig
+	// ResourceAttributes would be added into the resource besides the detected attributes.
+	ResourceAttributes map[string]string
+
+	// LocalExporter was one of stdout, file and memory, it would take precedence
+	// over the OTLP exporter when it's not empty.
+	LocalExporter string
+	// FilePath was the JSON lines file which the file exporter writes to.
+	FilePath string
+	// SpanExporter was the exporter which the memory exporter writes to.
+	SpanExporter sdktrace.SpanExporter
 }
 
+// DefaultOTLConfig was used when the config of InitOTLProvider was nil, the protocol, endpoint,
+// URL path and sampler which weren't set by the OTEL_* env were defaulted by InitOTLProvider.
 var DefaultOTLConfig = &OTLConfig{
-	Protocol: GRPC,
-	Endpoint: getDefaultOTLEndpoint(),
-	Sampler:  sdktrace.ParentBased(sdktrace.TraceIDRatioBased(0.1)),
 	Exporter: os.Getenv("OTEL_EXPORTER_NAME"),
-	URLPath:  "/ote/v1/traces",
 	IsExport: true,
 }
 
+// setDefaults sets the defaults of DefaultOTLConfig after loading the env.
+func (config *OTLConfig) setDefaults() {
+	if config.Protocol == "" {
+		config.Protocol = GRPC
+	}
+	if config.Endpoint == "" {
+		config.Endpoint = getDefaultOTLEndpoint()
+	}
+	if config.URLPath == "" {
+		config.URLPath = "/v1/traces"
+	}
+	if config.Sampler == nil && config.SamplerName == "" {
+		config.Sampler = sdktrace.ParentBased(sdktrace.TraceIDRatioBased(0.1))
+	}
+}
+
+// GetServiceName return the service name, it would fall back to Exporter when ServiceName was empty.
+func (config *OTLConfig) GetServiceName() string {
+	if config.ServiceName != "" {
+		return config.ServiceName
+	}
+	return config.Exporter
+}
+
+// clone copies the config to avoid LoadEnv modifying the caller's maps and TLS config.
+func (config *OTLConfig) clone() *OTLConfig {
+	cfg := *config
+	if config.TLS != nil {
+		tlsConfig := *config.TLS
+		cfg.TLS = &tlsConfig
+	}
+	if config.Headers != nil {
+		cfg.Headers = make(map[string]string, len(config.Headers))
+		for k, v := range config.Headers {
+			cfg.Headers[k] = v
+		}
+	}
+	if config.ResourceAttributes != nil {
+		cfg.ResourceAttributes = make(map[string]string, len(config.ResourceAttributes))
+		for k, v := range config.ResourceAttributes {
+			cfg.ResourceAttributes[k] = v
+		}
+	}
+	return &cfg
+}
+
 // InitOTLProvider initializes an OTLP exporter, and configures the corresponding trace providers.
 func InitOTLProvider(config *OTLConfig) (func() error, error) {
 	if config == nil {
 		config = DefaultOTLConfig
 	}
+	isDefault := config == DefaultOTLConfig
+	config = config.clone()
+	if err := config.LoadEnv(); err != nil {
+		return nil, err
+	}
+	if isDefault {
+		config.setDefaults()
+	}
+
 	ctx := context.Background()
-	res, err := resource.New(ctx,
-		resource.WithAttributes(
-			semconv.ServiceNameKey.String(config.Exporter),
-		),
-	)
+	res, err := newResource(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+	sampler, err := newSampler(config)
 	if err != nil {
 		return nil, err
 	}
 
-	otel.SetTextMapPropagator(b3.New())
+	propagator, err := NewPropagator(config.Propagators)
+	if err != nil {
+		return nil, err
+	}
+	otel.SetTextMapPropagator(propagator)
 	if !config.IsExport {
 		return func() error {
 			return nil
 		}, nil
 	}
 
-	var traceExporter *otlptrace.Exporter
-	timeoutCtx, cancel := context.WithTimeout(ctx, time.Second*3)
-	defer cancel()
-	if config.Protocol == GRPC {
-		// Set up a trace exporter
-		traceExporter, err = otlptracegrpc.New(timeoutCtx,
-			otlptracegrpc.WithInsecure(),
-			otlptracegrpc.WithEndpoint(config.Endpoint),
-			otlptracegrpc.WithDialOption(grpc.WithBlock()),
-		)
-	} else {
-		traceExporter, err = otlptrace.New(ctx,
-			otlptracehttp.NewClient(
-				otlptracehttp.WithEndpoint(config.Endpoint),
-				otlptracehttp.WithInsecure(),
-				otlptracehttp.WithEndpoint(config.URLPath),
-			),
-		)
-	}
+	spanProcessor, release, err := newSpanProcessor(ctx, config)
 	if err != nil {
 		return nil, err
 	}
-
-	// Register the trace exporter with a TracerProvider
-	// using a batch span processor to aggregate spans before export.
-	bsp := sdktrace.NewBatchSpanProcessor(traceExporter)
 	tracerProvider := sdktrace.NewTracerProvider(
-		sdktrace.WithSampler(config.Sampler),
+		sdktrace.WithSampler(sampler),
 		sdktrace.WithResource(res),
-		sdktrace.WithSpanProcessor(bsp),
+		sdktrace.WithSpanProcessor(spanProcessor),
 	)
 	otel.SetTracerProvider(tracerProvider)
 
 	return func() error {
-		return tracerProvider.Shutdown(context.Background())
-	}, err
+		return errors.Join(tracerProvider.Shutdown(context.Background()), release())
+	}, nil
+}
+
+func newSpanProcessor(ctx context.Context, config *OTLConfig) (sdktrace.SpanProcessor, func() error, error) {
+	if config.LocalExporter != "" {
+		return newLocalSpanProcessor(config)
+	}
+
+	var (
+		traceExporter *otlptrace.Exporter
+		err           error
+	)
+	if config.Protocol == GRPC {
+		traceExporter, err = newGRPCExporter(ctx, config)
+	} else {
+		traceExporter, err = newHTTPExporter(ctx, config)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	// using a batch span processor to aggregate spans before export.
+	return sdktrace.NewBatchSpanProcessor(traceExporter), func() error { return nil }, nil
+}
+
+func newGRPCExporter(ctx context.Context, config *OTLConfig) (*otlptrace.Exporter, error) {
+	opts := []otlptracegrpc.Option{
+		otlptracegrpc.WithEndpoint(config.Endpoint),
+		otlptracegrpc.WithDialOption(grpc.WithBlock()),
+	}
+	if config.TLS != nil {
+		tlsConfig, err := config.TLS.Build()
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, otlptracegrpc.WithTLSCredentials(credentials.NewTLS(tlsConfig)))
+	} else {
+		opts = append(opts, otlptracegrpc.WithInsecure())
+	}
+	if len(config.Headers) > 0 {
+		opts = append(opts, otlptracegrpc.WithHeaders(config.Headers))
+	}
+	if config.Compression == CompressionGzip {
+		opts = append(opts, otlptracegrpc.WithCompressor(CompressionGzip))
+	}
+	if config.Timeout > 0 {
+		opts = append(opts, otlptracegrpc.WithTimeout(config.Timeout))
+	}
+	if config.Retry != nil {
+		opts = append(opts, otlptracegrpc.WithRetry(otlptracegrpc.RetryConfig(*config.Retry)))
+	}
+
+	// Set up a trace exporter
+	timeoutCtx, cancel := context.WithTimeout(ctx, defaultConnectTimeout)
+	defer cancel()
+	return otlptracegrpc.New(timeoutCtx, opts...)
+}
+
+func newHTTPExporter(ctx context.Context, config *OTLConfig) (*otlptrace.Exporter, error) {
+	opts := []otlptracehttp.Option{
+		otlptracehttp.WithEndpoint(config.Endpoint),
+	}
+	if config.URLPath != "" {
+		opts = append(opts, otlptracehttp.WithURLPath(config.URLPath))
+	}
+	if config.TLS != nil {
+		tlsConfig, err := config.TLS.Build()
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, otlptracehttp.WithTLSClientConfig(tlsConfig))
+	} else {
+		opts = append(opts, otlptracehttp.WithInsecure())
+	}
+	if len(config.Headers) > 0 {
+		opts = append(opts, otlptracehttp.WithHeaders(config.Headers))
+	}
+	if config.Compression == CompressionGzip {
+		opts = append(opts, otlptracehttp.WithCompression(otlptracehttp.GzipCompression))
+	}
+	if config.Timeout > 0 {
+		opts = append(opts, otlptracehttp.WithTimeout(config.Timeout))
+	}
+	if config.Retry != nil {
+		opts = append(opts, otlptracehttp.WithRetry(otlptracehttp.RetryConfig(*config.Retry)))
+	}
+	return otlptrace.New(ctx, otlptracehttp.NewClient(opts...))
 }
 
 // TODO: When integrate other envs endpoint with k8s.
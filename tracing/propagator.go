@@ -0,0 +1,82 @@
+package tracing
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/contrib/propagators/b3"
+	"go.opentelemetry.io/contrib/propagators/jaeger"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+const (
+	PropagatorTraceContext = "tracecontext"
+	PropagatorBaggage      = "baggage"
+	PropagatorB3           = "b3"
+	PropagatorB3Multi      = "b3multi"
+	PropagatorJaeger       = "jaeger"
+	PropagatorCloudTrace   = "cloudtrace"
+	PropagatorNone         = "none"
+
+	envOtelPropagators = "OTEL_PROPAGATORS"
+)
+
+// defaultPropagators keeps the historical behavior which only understands the B3 headers,
+// both single and multiple headers would be extracted but only the multiple headers injected.
+var defaultPropagators = []string{PropagatorB3Multi}
+
+// NewPropagator composes the text map propagators by names, the names follow the
+// OTEL_PROPAGATORS spec (tracecontext, baggage, b3, b3multi, jaeger) plus the
+// cloudtrace which handles the x-cloud-trace-context header.
+// It would fall back to the OTEL_PROPAGATORS env and then b3multi if names was empty.
+func NewPropagator(names []string) (propagation.TextMapPropagator, error) {
+	if len(names) == 0 {
+		names = propagatorsFromEnv()
+	}
+	if len(names) == 0 {
+		names = defaultPropagators
+	}
+
+	propagators := make([]propagation.TextMapPropagator, 0, len(names))
+	seen := make(map[string]struct{}, len(names))
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		if _, ok := seen[name]; ok {
+			continue
+		}
+		seen[name] = struct{}{}
+
+		switch name {
+		case PropagatorTraceContext:
+			propagators = append(propagators, propagation.TraceContext{})
+		case PropagatorBaggage:
+			propagators = append(propagators, propagation.Baggage{})
+		case PropagatorB3:
+			propagators = append(propagators, b3.New(b3.WithInjectEncoding(b3.B3SingleHeader)))
+		case PropagatorB3Multi:
+			propagators = append(propagators, b3.New(b3.WithInjectEncoding(b3.B3MultipleHeader)))
+		case PropagatorJaeger:
+			propagators = append(propagators, jaeger.Jaeger{})
+		case PropagatorCloudTrace:
+			propagators = append(propagators, CloudTraceContext{})
+		case PropagatorNone:
+			// "none" disables the propagation entirely, see the OTEL_PROPAGATORS spec.
+			return propagation.NewCompositeTextMapPropagator(), nil
+		default:
+			return nil, fmt.Errorf("unsupported propagator: %s", name)
+		}
+	}
+	return propagation.NewCompositeTextMapPropagator(propagators...), nil
+}
+
+func propagatorsFromEnv() []string {
+	value := strings.TrimSpace(os.Getenv(envOtelPropagators))
+	if value == "" {
+		return nil
+	}
+	return strings.Split(value, ",")
+}
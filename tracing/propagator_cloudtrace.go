@@ -0,0 +1,93 @@
+package tracing
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/SyntSugar/ss-infra-go/consts"
+
+	"go.opentelemetry.io/otel/propagation"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+// CloudTraceContext propagates the span context through the GCP
+// x-cloud-trace-context header, the format of header value was
+// "TRACE_ID/SPAN_ID;o=TRACE_TRUE" which the SPAN_ID was decimal.
+// See https://cloud.google.com/trace/docs/trace-context#legacy-http-header
+type CloudTraceContext struct{}
+
+var _ propagation.TextMapPropagator = CloudTraceContext{}
+
+// Inject set the x-cloud-trace-context header from the span context in ctx.
+func (CloudTraceContext) Inject(ctx context.Context, carrier propagation.TextMapCarrier) {
+	sc := oteltrace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return
+	}
+	spanID := sc.SpanID()
+	sampled := 0
+	if sc.IsSampled() {
+		sampled = 1
+	}
+	carrier.Set(consts.HeaderXCloudTraceContext, fmt.Sprintf("%s/%d;o=%d",
+		sc.TraceID().String(), binary.BigEndian.Uint64(spanID[:]), sampled))
+}
+
+// Extract read the x-cloud-trace-context header and return the context with remote span context.
+func (CloudTraceContext) Extract(ctx context.Context, carrier propagation.TextMapCarrier) context.Context {
+	sc, err := parseCloudTraceContext(carrier.Get(consts.HeaderXCloudTraceContext))
+	if err != nil || !sc.IsValid() {
+		return ctx
+	}
+	return oteltrace.ContextWithRemoteSpanContext(ctx, sc)
+}
+
+// Fields return the header keys which would be set by Inject.
+func (CloudTraceContext) Fields() []string {
+	return []string{consts.HeaderXCloudTraceContext}
+}
+
+func parseCloudTraceContext(value string) (oteltrace.SpanContext, error) {
+	if value == "" {
+		return oteltrace.SpanContext{}, fmt.Errorf("empty %s header", consts.HeaderXCloudTraceContext)
+	}
+	traceIDPart, rest, found := strings.Cut(value, "/")
+	if !found {
+		return oteltrace.SpanContext{}, fmt.Errorf("span id was missing in %q", value)
+	}
+	spanIDPart, options, _ := strings.Cut(rest, ";")
+
+	// trace id should be 32 hex chars, pad the left zeros for the shorter one
+	if len(traceIDPart) < 32 {
+		traceIDPart = strings.Repeat("0", 32-len(traceIDPart)) + traceIDPart
+	}
+	traceIDBytes, err := hex.DecodeString(traceIDPart)
+	if err != nil || len(traceIDBytes) != 16 {
+		return oteltrace.SpanContext{}, fmt.Errorf("invalid trace id %q", traceIDPart)
+	}
+	spanIDValue, err := strconv.ParseUint(spanIDPart, 10, 64)
+	if err != nil {
+		return oteltrace.SpanContext{}, fmt.Errorf("invalid span id %q: %w", spanIDPart, err)
+	}
+
+	var (
+		traceID oteltrace.TraceID
+		spanID  oteltrace.SpanID
+		flags   oteltrace.TraceFlags
+	)
+	copy(traceID[:], traceIDBytes)
+	binary.BigEndian.PutUint64(spanID[:], spanIDValue)
+	if options == "o=1" {
+		flags = oteltrace.FlagsSampled
+	}
+	return oteltrace.NewSpanContext(oteltrace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		TraceFlags: flags,
+		Remote:     true,
+	}), nil
+}
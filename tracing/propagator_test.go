@@ -0,0 +1,72 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/propagation"
+	oteltrace "go.opentelemetry.io/otel/trace"
+
+	"github.com/SyntSugar/ss-infra-go/consts"
+)
+
+func TestNewPropagator(t *testing.T) {
+	propagator, err := NewPropagator([]string{"tracecontext", "baggage", "b3multi", "jaeger", "cloudtrace"})
+	require.Nil(t, err)
+	fields := propagator.Fields()
+	assert.Contains(t, fields, "traceparent")
+	assert.Contains(t, fields, "baggage")
+	assert.Contains(t, fields, "x-b3-traceid")
+	assert.Contains(t, fields, "uber-trace-id")
+	assert.Contains(t, fields, consts.HeaderXCloudTraceContext)
+
+	_, err = NewPropagator([]string{"unknown"})
+	assert.NotNil(t, err)
+
+	propagator, err = NewPropagator([]string{"none"})
+	require.Nil(t, err)
+	assert.Empty(t, propagator.Fields())
+}
+
+func TestNewPropagatorFromEnv(t *testing.T) {
+	t.Setenv(envOtelPropagators, "tracecontext, baggage")
+	propagator, err := NewPropagator(nil)
+	require.Nil(t, err)
+	assert.ElementsMatch(t, []string{"traceparent", "tracestate", "baggage"}, propagator.Fields())
+
+	t.Setenv(envOtelPropagators, "")
+	propagator, err = NewPropagator(nil)
+	require.Nil(t, err)
+	assert.Contains(t, propagator.Fields(), "x-b3-traceid")
+
+	propagator, err = NewPropagator([]string{"b3"})
+	require.Nil(t, err)
+	assert.Equal(t, []string{"b3"}, propagator.Fields())
+}
+
+func TestCloudTraceContext(t *testing.T) {
+	propagator := CloudTraceContext{}
+	header := http.Header{}
+	header.Set(consts.HeaderXCloudTraceContext, "105445aa7843bc8bf206b12000100000/1;o=1")
+
+	ctx := propagator.Extract(context.Background(), propagation.HeaderCarrier(header))
+	sc := oteltrace.SpanContextFromContext(ctx)
+	require.True(t, sc.IsValid())
+	assert.True(t, sc.IsRemote())
+	assert.True(t, sc.IsSampled())
+	assert.Equal(t, "105445aa7843bc8bf206b12000100000", sc.TraceID().String())
+	assert.Equal(t, "0000000000000001", sc.SpanID().String())
+
+	injected := http.Header{}
+	propagator.Inject(ctx, propagation.HeaderCarrier(injected))
+	assert.Equal(t, "105445aa7843bc8bf206b12000100000/1;o=1", injected.Get(consts.HeaderXCloudTraceContext))
+
+	for _, value := range []string{"", "105445aa7843bc8bf206b12000100000", "xyz/1;o=1", "105445aa7843bc8bf206b12000100000/abc"} {
+		header.Set(consts.HeaderXCloudTraceContext, value)
+		ctx = propagator.Extract(context.Background(), propagation.HeaderCarrier(header))
+		assert.False(t, oteltrace.SpanContextFromContext(ctx).IsValid(), value)
+	}
+}
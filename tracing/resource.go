@@ -0,0 +1,61 @@
+package tracing
+
+import (
+	"context"
+	"os"
+	"strings"
+
+	"github.com/SyntSugar/ss-infra-go/whoami"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/resource"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+)
+
+const (
+	// The downward API env of the pod, see
+	// https://kubernetes.io/docs/tasks/inject-data-application/environment-variable-expose-pod-information/
+	envPodName           = "POD_NAME"
+	envPodNamespace      = "POD_NAMESPACE"
+	envKubernetesHost    = "KUBERNETES_SERVICE_HOST"
+	serviceAccountNSFile = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+)
+
+func newResource(ctx context.Context, config *OTLConfig) (*resource.Resource, error) {
+	attrs := []attribute.KeyValue{
+		semconv.ServiceNameKey.String(config.GetServiceName()),
+		semconv.ServiceVersionKey.String(whoami.Version()),
+	}
+	hostname, _ := os.Hostname()
+	if hostname != "" {
+		attrs = append(attrs, semconv.HostNameKey.String(hostname))
+	}
+	if os.Getenv(envKubernetesHost) != "" {
+		podName := os.Getenv(envPodName)
+		if podName == "" {
+			// the hostname of pod was the pod name by default
+			podName = hostname
+		}
+		if podName != "" {
+			attrs = append(attrs, semconv.K8SPodNameKey.String(podName))
+		}
+		if namespace := podNamespace(); namespace != "" {
+			attrs = append(attrs, semconv.K8SNamespaceNameKey.String(namespace))
+		}
+	}
+	for k, v := range config.ResourceAttributes {
+		attrs = append(attrs, attribute.String(k, v))
+	}
+	return resource.New(ctx, resource.WithAttributes(attrs...))
+}
+
+func podNamespace() string {
+	if namespace := os.Getenv(envPodNamespace); namespace != "" {
+		return namespace
+	}
+	bytes, err := os.ReadFile(serviceAccountNSFile)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(bytes))
+}
@@ -0,0 +1,46 @@
+package tracing
+
+import (
+	"fmt"
+	"strings"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// The sampler names follow the OTEL_TRACES_SAMPLER spec.
+const (
+	SamplerAlwaysOn                = "always_on"
+	SamplerAlwaysOff               = "always_off"
+	SamplerTraceIDRatio            = "traceidratio"
+	SamplerParentBasedAlwaysOn     = "parentbased_always_on"
+	SamplerParentBasedAlwaysOff    = "parentbased_always_off"
+	SamplerParentBasedTraceIDRatio = "parentbased_traceidratio"
+)
+
+func newSampler(config *OTLConfig) (sdktrace.Sampler, error) {
+	if config.Sampler != nil {
+		return config.Sampler, nil
+	}
+	ratio := 1.0
+	if config.SamplerRatio != nil {
+		ratio = *config.SamplerRatio
+	}
+	switch strings.ToLower(config.SamplerName) {
+	case "":
+		return sdktrace.ParentBased(sdktrace.AlwaysSample()), nil
+	case SamplerAlwaysOn:
+		return sdktrace.AlwaysSample(), nil
+	case SamplerAlwaysOff:
+		return sdktrace.NeverSample(), nil
+	case SamplerTraceIDRatio:
+		return sdktrace.TraceIDRatioBased(ratio), nil
+	case SamplerParentBasedAlwaysOn:
+		return sdktrace.ParentBased(sdktrace.AlwaysSample()), nil
+	case SamplerParentBasedAlwaysOff:
+		return sdktrace.ParentBased(sdktrace.NeverSample()), nil
+	case SamplerParentBasedTraceIDRatio:
+		return sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio)), nil
+	default:
+		return nil, fmt.Errorf("unsupported sampler: %s", config.SamplerName)
+	}
+}
@@ -0,0 +1,134 @@
+// Package tracingtest provides the in-memory span recorder and assertions
+// to verify the spans which were produced in tests.
+package tracingtest
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/SyntSugar/ss-infra-go/tracing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// Recorder records the ended spans in memory.
+type Recorder struct {
+	exporter *tracetest.InMemoryExporter
+	provider *sdktrace.TracerProvider
+}
+
+// NewRecorder creates the recorder with its own tracer provider which samples all spans.
+func NewRecorder() *Recorder {
+	exporter := tracetest.NewInMemoryExporter()
+	return &Recorder{
+		exporter: exporter,
+		provider: sdktrace.NewTracerProvider(
+			sdktrace.WithSampler(sdktrace.AlwaysSample()),
+			sdktrace.WithSyncer(exporter),
+		),
+	}
+}
+
+// Install creates the recorder and sets its tracer provider as the global one,
+// the previous global tracer provider and propagator would be restored after the test.
+func Install(t testing.TB) *Recorder {
+	recorder := NewRecorder()
+	prevProvider := otel.GetTracerProvider()
+	prevPropagator := otel.GetTextMapPropagator()
+	otel.SetTracerProvider(recorder.provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{}, propagation.Baggage{}))
+	t.Cleanup(func() {
+		_ = recorder.provider.Shutdown(context.Background())
+		otel.SetTracerProvider(prevProvider)
+		otel.SetTextMapPropagator(prevPropagator)
+	})
+	return recorder
+}
+
+// TracerProvider returns the tracer provider of the recorder.
+func (r *Recorder) TracerProvider() *sdktrace.TracerProvider {
+	return r.provider
+}
+
+// Config returns the OTLConfig which uses the memory exporter of the recorder,
+// it's used to verify the spans of the provider initialized by tracing.InitOTLProvider.
+func (r *Recorder) Config() *tracing.OTLConfig {
+	return &tracing.OTLConfig{
+		IsExport:      true,
+		SamplerName:   tracing.SamplerAlwaysOn,
+		LocalExporter: tracing.LocalExporterMemory,
+		SpanExporter:  r.exporter,
+	}
+}
+
+// Spans returns the ended spans in the order of ending.
+func (r *Recorder) Spans() tracetest.SpanStubs {
+	return r.exporter.GetSpans()
+}
+
+// Reset clears the recorded spans.
+func (r *Recorder) Reset() {
+	r.exporter.Reset()
+}
+
+// FindSpan returns the first recorded span with the name.
+func (r *Recorder) FindSpan(name string) (tracetest.SpanStub, bool) {
+	for _, span := range r.Spans() {
+		if span.Name == name {
+			return span, true
+		}
+	}
+	return tracetest.SpanStub{}, false
+}
+
+// SpanNames returns the names of recorded spans in the order of ending.
+func (r *Recorder) SpanNames() []string {
+	spans := r.Spans()
+	names := make([]string, 0, len(spans))
+	for _, span := range spans {
+		names = append(names, span.Name)
+	}
+	return names
+}
+
+// AssertSpanNames asserts the recorded span names equal to names regardless of the order.
+func (r *Recorder) AssertSpanNames(t testing.TB, names ...string) bool {
+	t.Helper()
+	return assert.ElementsMatch(t, names, r.SpanNames())
+}
+
+// AssertAttribute asserts the span with the name has the attribute key with value.
+func (r *Recorder) AssertAttribute(t testing.TB, name, key string, value any) bool {
+	t.Helper()
+	span, ok := r.FindSpan(name)
+	if !ok {
+		return assert.Fail(t, fmt.Sprintf("span %q was not found in %v", name, r.SpanNames()))
+	}
+	for _, attr := range span.Attributes {
+		if string(attr.Key) == key {
+			return assert.Equal(t, value, attr.Value.AsInterface(), "attribute %q of span %q", key, name)
+		}
+	}
+	return assert.Fail(t, fmt.Sprintf("attribute %q was not found in span %q", key, name))
+}
+
+// AssertParent asserts the span with the parent name was the parent of the span with the child name.
+func (r *Recorder) AssertParent(t testing.TB, parentName, childName string) bool {
+	t.Helper()
+	parent, ok := r.FindSpan(parentName)
+	if !ok {
+		return assert.Fail(t, fmt.Sprintf("span %q was not found in %v", parentName, r.SpanNames()))
+	}
+	child, ok := r.FindSpan(childName)
+	if !ok {
+		return assert.Fail(t, fmt.Sprintf("span %q was not found in %v", childName, r.SpanNames()))
+	}
+	return assert.Equal(t, parent.SpanContext.TraceID(), child.Parent.TraceID(), "trace id of %q", childName) &&
+		assert.Equal(t, parent.SpanContext.SpanID(), child.Parent.SpanID(), "parent of %q", childName)
+}
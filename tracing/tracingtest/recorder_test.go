@@ -0,0 +1,46 @@
+package tracingtest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/SyntSugar/ss-infra-go/tracing"
+)
+
+func TestRecorder(t *testing.T) {
+	recorder := Install(t)
+	tracer := otel.Tracer("test")
+
+	ctx, parent := tracer.Start(context.Background(), "parent")
+	_, child := tracer.Start(ctx, "child")
+	child.SetAttributes(attribute.String("key", "value"), attribute.Int("count", 3))
+	child.End()
+	parent.End()
+
+	recorder.AssertSpanNames(t, "parent", "child")
+	recorder.AssertAttribute(t, "child", "key", "value")
+	recorder.AssertAttribute(t, "child", "count", int64(3))
+	recorder.AssertParent(t, "parent", "child")
+
+	recorder.Reset()
+	assert.Empty(t, recorder.Spans())
+}
+
+func TestRecorderConfig(t *testing.T) {
+	prevProvider := otel.GetTracerProvider()
+	defer otel.SetTracerProvider(prevProvider)
+
+	recorder := NewRecorder()
+	shutdown, err := tracing.InitOTLProvider(recorder.Config())
+	require.Nil(t, err)
+	defer shutdown()
+
+	_, span := otel.Tracer("test").Start(context.Background(), "span")
+	span.End()
+	recorder.AssertSpanNames(t, "span")
+}